@@ -0,0 +1,153 @@
+package cache
+
+import (
+	"bilinovel-downloader/imageopt"
+	"bilinovel-downloader/model"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Cache 按 小说/卷/章节 ID 将已下载的章节保存到磁盘，图片按 URL 单独保存，
+// 重新运行时只需下载缺失的部分
+type Cache struct {
+	dir     string
+	refresh bool
+}
+
+// New 创建以 dir 为根目录的缓存，refresh 为 true 时忽略已有缓存，但仍写入新下载的内容
+func New(dir string, refresh bool) *Cache {
+	return &Cache{dir: dir, refresh: refresh}
+}
+
+// ImageFilename 返回图片在章节 HTML、章节 Images 与缓存中使用的文件名
+func ImageFilename(url string) string {
+	imageHash := sha256.Sum256([]byte(url))
+	return fmt.Sprintf("%x%s", string(imageHash[:]), path.Ext(url))
+}
+
+// Dir 返回缓存根目录
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+func (c *Cache) chapterPath(novelId, volumeId, chapterId int) string {
+	return filepath.Join(c.dir, fmt.Sprint(novelId), fmt.Sprint(volumeId), fmt.Sprintf("chapter-%d.json", chapterId))
+}
+
+func (c *Cache) imagePath(filename string) string {
+	return filepath.Join(c.dir, "images", filename)
+}
+
+// LoadChapter 读取缓存的章节，章节 HTML 引用的图片缺任何一张都视为未命中
+func (c *Cache) LoadChapter(novelId, volumeId, chapterId int) (*model.Chapter, bool) {
+	if c.refresh {
+		return nil, false
+	}
+	data, err := os.ReadFile(c.chapterPath(novelId, volumeId, chapterId))
+	if err != nil {
+		return nil, false
+	}
+	chapter := &model.Chapter{}
+	if err := json.Unmarshal(data, chapter); err != nil || chapter.Content == nil {
+		return nil, false
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(chapter.Content.Html))
+	if err != nil {
+		return nil, false
+	}
+	images := make(map[string][]byte)
+	missing := false
+	doc.Find("img").EachWithBreak(func(i int, s *goquery.Selection) bool {
+		src := s.AttrOr("src", "")
+		if src == "" {
+			return true
+		}
+		img, ok := c.LoadImage(src)
+		if !ok {
+			missing = true
+			return false
+		}
+		images[src] = img
+		return true
+	})
+	if missing {
+		return nil, false
+	}
+	if len(images) > 0 {
+		chapter.Content.Images = images
+	}
+	return chapter, true
+}
+
+// SaveChapter 保存章节，图片数据单独写入图片缓存
+func (c *Cache) SaveChapter(chapter *model.Chapter) error {
+	stored := *chapter
+	if chapter.Content != nil {
+		content := *chapter.Content
+		content.Images = nil
+		stored.Content = &content
+		for filename, img := range chapter.Content.Images {
+			if err := c.SaveImage(filename, img); err != nil {
+				return err
+			}
+		}
+	}
+	data, err := json.Marshal(&stored)
+	if err != nil {
+		return fmt.Errorf("failed to encode chapter: %v", err)
+	}
+	return writeFile(c.chapterPath(chapter.NovelId, chapter.VolumeId, chapter.Id), data)
+}
+
+// LoadImage 按 ImageFilename 返回的文件名读取缓存的图片，无法识别为图片的文件视为未命中
+func (c *Cache) LoadImage(filename string) ([]byte, bool) {
+	if c.refresh {
+		return nil, false
+	}
+	data, err := os.ReadFile(c.imagePath(filename))
+	if err != nil || imageopt.Sniff(data) == "" {
+		return nil, false
+	}
+	return data, true
+}
+
+// SaveImage 按 ImageFilename 返回的文件名保存图片，拒绝无法识别为图片的数据
+func (c *Cache) SaveImage(filename string, data []byte) error {
+	if imageopt.Sniff(data) == "" {
+		return fmt.Errorf("failed to cache image %v: unexpected content type %v", filename, http.DetectContentType(data))
+	}
+	return writeFile(c.imagePath(filename), data)
+}
+
+// writeFile 先写临时文件再重命名，中断时不会留下不完整的缓存
+func writeFile(filePath string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %v", err)
+	}
+	tmpFile, err := os.CreateTemp(filepath.Dir(filePath), filepath.Base(filePath)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create cache file: %v", err)
+	}
+	_, err = tmpFile.Write(data)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpFile.Name())
+		return fmt.Errorf("failed to write cache file: %v", err)
+	}
+	if err := os.Rename(tmpFile.Name(), filePath); err != nil {
+		os.Remove(tmpFile.Name())
+		return fmt.Errorf("failed to rename cache file: %v", err)
+	}
+	return nil
+}
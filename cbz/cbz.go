@@ -0,0 +1,155 @@
+package cbz
+
+import (
+	"archive/zip"
+	"bilinovel-downloader/imageopt"
+	"bilinovel-downloader/model"
+	"bilinovel-downloader/utils"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// ComicInfo 是漫画阅读器通用的 ComicInfo.xml（v2.0）中用到的字段
+type ComicInfo struct {
+	XMLName     xml.Name `xml:"ComicInfo"`
+	XSI         string   `xml:"xmlns:xsi,attr"`
+	XSD         string   `xml:"xmlns:xsd,attr"`
+	Title       string   `xml:"Title,omitempty"`
+	Series      string   `xml:"Series,omitempty"`
+	Number      string   `xml:"Number,omitempty"`
+	Volume      int      `xml:"Volume,omitempty"`
+	Summary     string   `xml:"Summary,omitempty"`
+	Writer      string   `xml:"Writer,omitempty"`
+	PageCount   int      `xml:"PageCount"`
+	LanguageISO string   `xml:"LanguageISO,omitempty"`
+	Pages       []Page   `xml:"Pages>Page"`
+}
+
+type Page struct {
+	Image int    `xml:"Image,attr"`
+	Type  string `xml:"Type,attr,omitempty"`
+}
+
+type image struct {
+	data []byte
+	// name 为图片地址或文件名，无法按内容识别格式时用于推断扩展名
+	name  string
+	cover bool
+}
+
+// PackVolumeToCBZ 将卷封面和插图章节中的图片按阅读顺序打包为 <卷名>.cbz
+func PackVolumeToCBZ(volume *model.Volume, outputPath string) error {
+	images := volumeImages(volume)
+	info := &ComicInfo{
+		Title:       volume.Title,
+		Series:      volume.NovelTitle,
+		Number:      fmt.Sprint(volume.SeriesIdx),
+		Volume:      volume.SeriesIdx,
+		Summary:     volume.Description,
+		Writer:      strings.Join(utils.Unique(volume.Authors), ", "),
+		LanguageISO: volume.Lang(),
+	}
+	return writeCBZ(filepath.Join(outputPath, utils.CleanDirName(volume.Title)+".cbz"), info, images)
+}
+
+// PackNovelToCBZ 将所有卷的封面和插图按卷序打包为 <书名>.cbz
+func PackNovelToCBZ(novel *model.Novel, outputPath string) error {
+	var images []image
+	for _, volume := range novel.Volumes {
+		images = append(images, volumeImages(volume)...)
+	}
+	info := &ComicInfo{
+		Title:   novel.Title,
+		Series:  novel.Title,
+		Summary: novel.Description,
+		Writer:  strings.Join(utils.Unique(novel.Authors), ", "),
+	}
+	// 合集的各卷使用相同的简繁转换
+	if len(novel.Volumes) > 0 {
+		info.LanguageISO = novel.Volumes[0].Lang()
+	}
+	return writeCBZ(filepath.Join(outputPath, utils.CleanDirName(novel.Title)+".cbz"), info, images)
+}
+
+// volumeImages 返回卷封面以及插图章节中按 HTML 顺序出现的图片
+func volumeImages(volume *model.Volume) []image {
+	var images []image
+	if len(volume.Cover) > 0 {
+		images = append(images, image{data: volume.Cover, name: volume.CoverUrl, cover: true})
+	}
+	for _, chapter := range volume.Chapters {
+		if chapter == nil || chapter.Content == nil || !utils.IsIllustration(chapter.Title) {
+			continue
+		}
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(chapter.Content.Html))
+		if err != nil {
+			continue
+		}
+		doc.Find("img").Each(func(i int, s *goquery.Selection) {
+			src := s.AttrOr("src", "")
+			if data, ok := chapter.Content.Images[src]; ok && len(data) > 0 {
+				images = append(images, image{data: data, name: src})
+			}
+		})
+	}
+	return images
+}
+
+func writeCBZ(cbzPath string, info *ComicInfo, images []image) error {
+	if len(images) == 0 {
+		return fmt.Errorf("no illustrations found")
+	}
+
+	var buf bytes.Buffer
+	zipWriter := zip.NewWriter(&buf)
+	info.XSI = "http://www.w3.org/2001/XMLSchema-instance"
+	info.XSD = "http://www.w3.org/2001/XMLSchema"
+	info.PageCount = len(images)
+	info.Pages = make([]Page, 0, len(images))
+	width := len(fmt.Sprint(len(images)))
+	for i, img := range images {
+		// 扩展名按图片内容确定，站点图片地址的扩展名不一定可靠；图片本身已压缩，直接存储
+		name := fmt.Sprintf("%0*d.%s", width, i+1, imageopt.FileExtension(img.data, img.name))
+		w, err := zipWriter.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+		if err != nil {
+			return fmt.Errorf("failed to create zip entry: %v", err)
+		}
+		if _, err := w.Write(img.data); err != nil {
+			return fmt.Errorf("failed to write image: %v", err)
+		}
+		page := Page{Image: i}
+		if img.cover {
+			page.Type = "FrontCover"
+		}
+		info.Pages = append(info.Pages, page)
+	}
+
+	data, err := xml.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode ComicInfo.xml: %v", err)
+	}
+	w, err := zipWriter.Create("ComicInfo.xml")
+	if err != nil {
+		return fmt.Errorf("failed to create zip entry: %v", err)
+	}
+	if _, err := w.Write(append([]byte(xml.Header), data...)); err != nil {
+		return fmt.Errorf("failed to write ComicInfo.xml: %v", err)
+	}
+	if err := zipWriter.Close(); err != nil {
+		return fmt.Errorf("failed to close zip writer: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cbzPath), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %v", err)
+	}
+	if err := os.WriteFile(cbzPath, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write cbz: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"bilinovel-downloader/library"
+	"bilinovel-downloader/model"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var checkUpdatesCmd = &cobra.Command{
+	Use:   "check-updates [novel-id...]",
+	Short: "Check followed novels for new volumes and chapters",
+	Long:  "Check followed novels for new volumes and chapters since they were last downloaded",
+	// 报告已经输出，失败时只打印错误
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err := runCheckUpdates(cmd.Context(), cmd.OutOrStdout(), args)
+		if errors.Is(err, context.Canceled) {
+			return fmt.Errorf("check canceled")
+		} else if err != nil {
+			return fmt.Errorf("failed to check updates: %v", err)
+		}
+		return nil
+	},
+}
+
+type checkUpdatesCmdArgs struct {
+	listPath string
+	format   string
+	download bool
+}
+
+var (
+	checkUpdatesArgs checkUpdatesCmdArgs
+)
+
+func init() {
+	checkUpdatesCmd.Flags().StringVarP(&checkUpdatesArgs.listPath, "list", "l", "", "file of followed novel ids, one per line, # starts a comment")
+	checkUpdatesCmd.Flags().StringVar(&checkUpdatesArgs.format, "format", "table", "report format, table or json")
+	checkUpdatesCmd.Flags().BoolVar(&checkUpdatesArgs.download, "download", false, "download new volumes and volumes with new chapters")
+	addDownloadFlags(checkUpdatesCmd)
+	RootCmd.AddCommand(checkUpdatesCmd)
+}
+
+func runCheckUpdates(ctx context.Context, out io.Writer, args []string) error {
+	if checkUpdatesArgs.format != "table" && checkUpdatesArgs.format != "json" {
+		return fmt.Errorf("unknown format: %q", checkUpdatesArgs.format)
+	}
+	var novelIds []int
+	if checkUpdatesArgs.listPath != "" {
+		ids, err := library.ReadList(checkUpdatesArgs.listPath)
+		if err != nil {
+			return err
+		}
+		novelIds = append(novelIds, ids...)
+	}
+	for _, arg := range args {
+		id, err := strconv.Atoi(arg)
+		if err != nil {
+			return fmt.Errorf("invalid novel id: %q", arg)
+		}
+		novelIds = append(novelIds, id)
+	}
+	if len(novelIds) == 0 {
+		return fmt.Errorf("no novel ids given, use --list or pass them as arguments")
+	}
+
+	downloader, err := newDownloader()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := downloader.Close(); closeErr != nil {
+			log.Printf("Failed to close downloader: %v", closeErr)
+		}
+	}()
+
+	reports := make([]*library.Report, 0, len(novelIds))
+	failed := 0
+	for _, novelId := range novelIds {
+		report, err := checkNovel(ctx, downloader, novelId)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			failed++
+			report = &library.Report{NovelId: novelId, Changes: []library.Change{}, Error: err.Error()}
+		}
+		reports = append(reports, report)
+	}
+
+	if err := writeReports(out, reports); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to check %d of %d novels", failed, len(novelIds))
+	}
+	return nil
+}
+
+// checkNovel 比较小说当前目录与上次下载的快照，--download 时下载更新并刷新快照
+func checkNovel(ctx context.Context, downloader model.Downloader, novelId int) (*library.Report, error) {
+	novel, err := downloader.GetNovelContext(ctx, novelId, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get novel: %w", err)
+	}
+	snapshot, err := library.LoadSnapshot(downloadArgs.outputPath, novelId)
+	if err != nil {
+		return nil, err
+	}
+	if snapshot == nil {
+		snapshot, err = library.SnapshotFromVolumes(downloadArgs.outputPath, novelId)
+		if err != nil {
+			return nil, err
+		}
+	}
+	report := library.Diff(snapshot, library.NewSnapshot(novel))
+	if !checkUpdatesArgs.download {
+		return report, nil
+	}
+
+	for _, volumeId := range report.UpdatedVolumes() {
+		// 卷缓存中的章节列表已过期，必须重新获取；已下载的章节仍从章节缓存读取
+		_, err = downloadVolume(ctx, downloader, novelId, volumeId, true)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download volume: %w", err)
+		}
+	}
+	if err := library.SaveSnapshot(downloadArgs.outputPath, library.NewSnapshot(novel)); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func writeReports(out io.Writer, reports []*library.Report) error {
+	if checkUpdatesArgs.format == "json" {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(reports)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NOVEL\tVOLUME\tCHANGE\tDETAIL")
+	for _, report := range reports {
+		novel := fmt.Sprintf("%v %v", report.NovelId, report.Title)
+		if report.Error != "" {
+			fmt.Fprintf(w, "%v\t\terror\t%v\n", novel, report.Error)
+			continue
+		}
+		if len(report.Changes) == 0 {
+			fmt.Fprintf(w, "%v\t\tup-to-date\t\n", novel)
+			continue
+		}
+		for _, change := range report.Changes {
+			volume := ""
+			if change.VolumeId != 0 {
+				volume = fmt.Sprintf("%v %v", change.VolumeId, change.VolumeTitle)
+			}
+			detail := change.New
+			switch change.Kind {
+			case library.NewVolume:
+				detail = fmt.Sprintf("%v chapters", change.Chapters)
+			case library.NovelTitle, library.VolumeTitle, library.ChapterTitle:
+				detail = fmt.Sprintf("%v -> %v", change.Old, change.New)
+			}
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", novel, volume, change.Kind, detail)
+		}
+	}
+	return w.Flush()
+}
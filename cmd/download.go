@@ -1,36 +1,90 @@
 package cmd
 
 import (
+	"bilinovel-downloader/cache"
+	"bilinovel-downloader/cbz"
+	"bilinovel-downloader/docx"
 	"bilinovel-downloader/downloader/bilinovel"
 	"bilinovel-downloader/epub"
+	"bilinovel-downloader/fb2"
+	"bilinovel-downloader/fontembed"
+	"bilinovel-downloader/htmlbook"
+	"bilinovel-downloader/imageopt"
+	"bilinovel-downloader/kepub"
+	"bilinovel-downloader/library"
+	"bilinovel-downloader/markdown"
 	"bilinovel-downloader/model"
+	"bilinovel-downloader/pdf"
+	"bilinovel-downloader/processor"
 	"bilinovel-downloader/text"
+	"bilinovel-downloader/theme"
+	"bilinovel-downloader/utils"
+	"bilinovel-downloader/zhconv"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
 
 var downloadCmd = &cobra.Command{
-	Use:   "download",
-	Short: "Download a novel or volume",
-	Long:  "Download a novel or volume",
-	Run: func(cmd *cobra.Command, args []string) {
-		err := runDownloadNovel()
-		if err != nil {
-			log.Printf("failed to download novel: %v", err)
+	Use:          "download",
+	Short:        "Download a novel or volume",
+	Long:         "Download a novel or volume",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err := runDownloadNovel(cmd.Context())
+		if errors.Is(err, context.Canceled) {
+			return fmt.Errorf("download canceled")
+		} else if err != nil {
+			return fmt.Errorf("failed to download novel: %v", err)
 		}
+		return nil
 	},
 }
 
 type downloadCmdArgs struct {
-	NovelId    int `validate:"required"`
-	VolumeId   int `validate:"required"`
-	outputPath string
-	outputType string
+	NovelId       int `validate:"required"`
+	VolumeId      int `validate:"required"`
+	outputPath    string
+	outputType    string
+	baseURLs      []string
+	hostOverrides map[string]string
+
+	concurrency        int
+	browserConcurrency int
+
+	cacheDir string
+	refresh  bool
+
+	noBrowser bool
+
+	processorsConfig string
+
+	omnibus bool
+
+	chinese string
+
+	imageOptions imageopt.Options
+	imageMaxSize string
+
+	epubOptions epub.Options
+	theme       string
+	embedFont   string
+	validate    bool
+
+	singleFile  bool
+	textOptions text.Options
+
+	fb2Zip bool
+
+	pdfPageSize string
+	pdfMargins  string
 }
 
 var (
@@ -40,15 +94,139 @@ var (
 func init() {
 	downloadCmd.Flags().IntVarP(&downloadArgs.NovelId, "novel-id", "n", 0, "novel id")
 	downloadCmd.Flags().IntVarP(&downloadArgs.VolumeId, "volume-id", "v", 0, "volume id")
-	downloadCmd.Flags().StringVarP(&downloadArgs.outputPath, "output-path", "o", "novels", "output path")
-	downloadCmd.Flags().StringVarP(&downloadArgs.outputType, "output-type", "t", "epub", "output type, epub or text")
+	addDownloadFlags(downloadCmd)
+	downloadCmd.Flags().BoolVar(&downloadArgs.omnibus, "omnibus", false, "pack the whole novel into a single epub or text file when no volume id is given")
 	RootCmd.AddCommand(downloadCmd)
 }
 
-func runDownloadNovel() error {
-	downloader, err := bilinovel.New()
+// addDownloadFlags 注册下载与输出相关的参数，download 与 check-updates 共用 downloadArgs
+func addDownloadFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&downloadArgs.outputPath, "output-path", "o", "novels", "output path")
+	cmd.Flags().StringVarP(&downloadArgs.outputType, "output-type", "t", "epub", "output type, epub, kepub, text, markdown, html, fb2, cbz, docx or pdf")
+	cmd.Flags().StringSliceVar(&downloadArgs.baseURLs, "base-url", []string{bilinovel.DefaultBaseURL}, "site base url, repeat or separate with commas to add fallback mirrors")
+	cmd.Flags().StringToStringVar(&downloadArgs.hostOverrides, "host-override", bilinovel.DefaultHostOverrides, "connect to host via a fixed ip, e.g. www.bilinovel.com=64.140.161.52, leave ip empty to disable")
+	cmd.Flags().IntVar(&downloadArgs.concurrency, "concurrency", bilinovel.DefaultConcurrency, "number of chapters and images fetched concurrently")
+	cmd.Flags().IntVar(&downloadArgs.browserConcurrency, "browser-concurrency", bilinovel.DefaultBrowserConcurrency, "number of browser tabs used to de-shuffle chapters concurrently")
+	cmd.Flags().StringVar(&downloadArgs.cacheDir, "cache-dir", "", "chapter and image cache directory (default <output-path>/.cache)")
+	cmd.Flags().BoolVar(&downloadArgs.refresh, "refresh", false, "ignore cached volumes, chapters and images and fetch them again")
+	cmd.Flags().BoolVar(&downloadArgs.noBrowser, "no-browser", false, "restore chapter content in pure go without starting chrome, falling back to chrome when available if the site changes its rules")
+	cmd.Flags().StringVar(&downloadArgs.processorsConfig, "processors", "", "json file of extra content processors run after the built-in ones")
+
+	cmd.Flags().StringVar(&downloadArgs.chinese, "chinese", "", "convert titles, metadata and chapter text before packing, "+strings.Join(zhconv.Variants, " or ")+", and set the book language to zh-CN or zh-TW")
+	cmd.Flags().StringVar(&downloadArgs.imageOptions.Format, "image-format", "", "convert images before packing, auto (webp and gif to jpeg, or png when transparent), jpeg or png, empty keeps the original format")
+	cmd.Flags().StringVar(&downloadArgs.imageMaxSize, "image-max-size", "", "downscale images to fit <width>x<height> pixels, e.g. 1264x1680 for e-ink readers, 0 leaves a side unlimited")
+	cmd.Flags().BoolVar(&downloadArgs.imageOptions.Grayscale, "image-grayscale", false, "convert images to grayscale")
+	cmd.Flags().IntVar(&downloadArgs.imageOptions.Quality, "image-quality", 0, "recompress jpeg images at this quality (1-100), keeping the original when it is smaller")
+	cmd.Flags().BoolVar(&downloadArgs.validate, "validate", false, "check epub and kepub output against common epubcheck rules and fail on problems")
+	cmd.Flags().BoolVar(&downloadArgs.epubOptions.EPUB2Compat, "epub2-compat", false, "also write toc.ncx and the opf guide for epub 2 readers and converters, applies to epub and kepub output")
+	cmd.Flags().StringVar(&downloadArgs.theme, "theme", "default", "epub style theme, "+strings.Join(theme.Names, ", ")+", a css file or a directory with style.css and fonts, applies to epub and kepub output")
+	cmd.Flags().StringVar(&downloadArgs.embedFont, "embed-font", "", "embed a font subset to the used characters, \"bundled\" for MI LANTING or a ttf/otf path, applies to epub and kepub output")
+	cmd.Flags().Lookup("embed-font").NoOptDefVal = "bundled"
+	cmd.Flags().BoolVar(&downloadArgs.epubOptions.Vertical, "vertical", false, "use vertical right-to-left layout (tategaki), applies to epub and kepub output")
+
+	defaultText := text.DefaultOptions()
+	cmd.Flags().BoolVar(&downloadArgs.singleFile, "single-file", false, "write each volume as a single text file instead of one file per chapter")
+	cmd.Flags().StringVar(&downloadArgs.textOptions.Indent, "text-indent", defaultText.Indent, "indent at the start of each paragraph in text output")
+	cmd.Flags().StringVar(&downloadArgs.textOptions.ImagePlaceholder, "text-image-placeholder", defaultText.ImagePlaceholder, "text that replaces images in text output, empty to drop them")
+	cmd.Flags().StringVar(&downloadArgs.textOptions.Separator, "text-separator", defaultText.Separator, "line between chapters in single-file text output, empty for a blank line only")
+	cmd.Flags().BoolVar(&downloadArgs.fb2Zip, "fb2-zip", false, "write fb2 output as .fb2.zip")
+	cmd.Flags().StringVar(&downloadArgs.pdfPageSize, "pdf-page-size", "a5", "pdf page size, a4, a5, a6, b5, b6, letter or <width>x<height> in mm")
+	cmd.Flags().StringVar(&downloadArgs.pdfMargins, "pdf-margin", "15", "pdf page margins in mm, one value or top,right,bottom,left")
+}
+
+// pdfOptions 解析 --pdf-page-size 与 --pdf-margin
+func pdfOptions() (pdf.Options, error) {
+	opts := pdf.DefaultOptions()
+	var err error
+	opts.PageSize, err = pdf.ParsePageSize(downloadArgs.pdfPageSize)
+	if err != nil {
+		return opts, err
+	}
+	opts.Margins, err = pdf.ParseMargins(downloadArgs.pdfMargins)
+	if err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// epubStyle 按 --theme 与 --embed-font 返回 EPUB 使用的样式，以及下载器、主题与字体需要一起打包的文件
+func epubStyle(downloader model.Downloader, volumes ...*model.Volume) (string, []model.ExtraFile, error) {
+	t, err := theme.Load(downloadArgs.theme, downloader.GetStyleCSS())
+	if err != nil {
+		return "", nil, err
+	}
+	styleCSS := t.CSS
+	extraFiles := append(downloader.GetExtraFiles(), t.ExtraFiles...)
+	if downloadArgs.embedFont == "" {
+		return styleCSS, extraFiles, nil
+	}
+
+	fontData := fontembed.Bundled()
+	if downloadArgs.embedFont != "bundled" {
+		fontData, err = os.ReadFile(downloadArgs.embedFont)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to read font: %v", err)
+		}
+	}
+	fontCSS, fontFiles, err := fontembed.Embed(fontData, volumes...)
+	if err != nil {
+		return "", nil, err
+	}
+	return fontCSS + styleCSS, append(extraFiles, fontFiles...), nil
+}
+
+// chineseConverter 按 --chinese 创建简繁转换器，未指定时返回 nil
+func chineseConverter() (*zhconv.Converter, error) {
+	if downloadArgs.chinese == "" {
+		return nil, nil
+	}
+	return zhconv.New(downloadArgs.chinese)
+}
+
+// imageOptions 解析 --image-* 参数，返回的选项未启用时不处理图片
+func imageOptions() (imageopt.Options, error) {
+	opts := downloadArgs.imageOptions
+	var err error
+	opts.MaxWidth, opts.MaxHeight, err = imageopt.ParseSize(downloadArgs.imageMaxSize)
+	if err != nil {
+		return opts, err
+	}
+	return opts, opts.Validate()
+}
+
+// newDownloader 按 downloadArgs 创建下载器
+func newDownloader() (*bilinovel.Bilinovel, error) {
+	cacheDir := downloadArgs.cacheDir
+	if cacheDir == "" {
+		cacheDir = filepath.Join(downloadArgs.outputPath, ".cache")
+	}
+	var processors []processor.Processor
+	if downloadArgs.processorsConfig != "" {
+		var err error
+		processors, err = processor.LoadConfig(downloadArgs.processorsConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load processors: %v", err)
+		}
+	}
+	downloader, err := bilinovel.New(
+		bilinovel.WithCache(cache.New(cacheDir, downloadArgs.refresh)),
+		bilinovel.WithProcessors(processors...),
+		bilinovel.WithMirrors(downloadArgs.baseURLs...),
+		bilinovel.WithHostOverrides(downloadArgs.hostOverrides),
+		bilinovel.WithConcurrency(downloadArgs.concurrency),
+		bilinovel.WithBrowserConcurrency(downloadArgs.browserConcurrency),
+		bilinovel.WithNoBrowser(downloadArgs.noBrowser),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create downloader: %v", err)
+	}
+	return downloader, nil
+}
+
+func runDownloadNovel(ctx context.Context) error {
+	downloader, err := newDownloader()
 	if err != nil {
-		return fmt.Errorf("failed to create downloader: %v", err)
+		return err
 	}
 	// 确保在函数结束时关闭资源
 	defer func() {
@@ -63,75 +241,261 @@ func runDownloadNovel() error {
 
 	if downloadArgs.VolumeId == 0 {
 		// 下载整本小说
-		novel, err := downloader.GetNovel(downloadArgs.NovelId, true)
+		novel, err := downloader.GetNovelContext(ctx, downloadArgs.NovelId, true)
 		if err != nil {
-			return fmt.Errorf("failed to get novel: %v", err)
+			return fmt.Errorf("failed to get novel: %w", err)
 		}
-		for _, volume := range novel.Volumes {
-			err = downloadVolume(downloader, volume.Id)
+		if downloadArgs.omnibus {
+			err = downloadOmnibus(ctx, downloader, novel)
 			if err != nil {
-				return fmt.Errorf("failed to download volume: %v", err)
+				return err
+			}
+		} else {
+			for _, volume := range novel.Volumes {
+				_, err = downloadVolume(ctx, downloader, novel.Id, volume.Id, downloadArgs.refresh)
+				if err != nil {
+					return fmt.Errorf("failed to download volume: %w", err)
+				}
 			}
 		}
-	} else {
-		// 下载单卷
-		err = downloadVolume(downloader, downloadArgs.VolumeId)
+		return library.SaveSnapshot(downloadArgs.outputPath, library.NewSnapshot(novel))
+	}
+
+	// 下载单卷
+	volume, err := downloadVolume(ctx, downloader, downloadArgs.NovelId, downloadArgs.VolumeId, downloadArgs.refresh)
+	if err != nil {
+		return fmt.Errorf("failed to download volume: %w", err)
+	}
+	return mergeSnapshot(volume)
+}
+
+// mergeSnapshot 将单卷记入 check-updates 使用的快照
+func mergeSnapshot(volume *model.Volume) error {
+	snapshot, err := library.LoadSnapshot(downloadArgs.outputPath, volume.NovelId)
+	if err != nil {
+		return err
+	}
+	if snapshot == nil {
+		snapshot = &library.Snapshot{Id: volume.NovelId}
+	}
+	snapshot.MergeVolume(volume)
+	return library.SaveSnapshot(downloadArgs.outputPath, snapshot)
+}
+
+// downloadVolume 下载并按 --output-type 打包单卷，refresh 为 true 时不使用卷缓存
+func downloadVolume(ctx context.Context, downloader model.Downloader, novelId int, volumeId int, refresh bool) (*model.Volume, error) {
+	converter, err := chineseConverter()
+	if err != nil {
+		return nil, err
+	}
+	imageOpts, err := imageOptions()
+	if err != nil {
+		return nil, err
+	}
+	original, err := loadVolume(ctx, downloader, novelId, volumeId, refresh)
+	if err != nil {
+		return nil, err
+	}
+	// 转换与图片处理只作用于打包的副本，缓存与快照保留原文
+	volume := original
+	if converter != nil {
+		volume = converter.ConvertVolume(volume)
+	}
+	if imageOpts.Enabled() {
+		volume = imageopt.ProcessVolume(volume, imageOpts)
+	}
+
+	switch downloadArgs.outputType {
+	case "epub":
+		styleCSS, extraFiles, err := epubStyle(downloader, volume)
+		if err != nil {
+			return nil, err
+		}
+		err = epub.PackVolumeToEpub(volume, downloadArgs.outputPath, styleCSS, extraFiles, downloadArgs.epubOptions)
+		if err != nil {
+			return nil, fmt.Errorf("failed to pack volume: %v", err)
+		}
+		if err := validateOutput(filepath.Join(downloadArgs.outputPath, utils.CleanDirName(volume.Title)+".epub")); err != nil {
+			return nil, err
+		}
+	case "kepub":
+		styleCSS, extraFiles, err := epubStyle(downloader, volume)
+		if err != nil {
+			return nil, err
+		}
+		err = kepub.PackVolumeToKepub(volume, downloadArgs.outputPath, styleCSS, extraFiles, downloadArgs.epubOptions)
+		if err != nil {
+			return nil, fmt.Errorf("failed to pack volume: %v", err)
+		}
+		if err := validateOutput(filepath.Join(downloadArgs.outputPath, utils.CleanDirName(volume.Title)+".kepub.epub")); err != nil {
+			return nil, err
+		}
+	case "text":
+		if downloadArgs.singleFile {
+			err = text.PackVolumeToSingleText(volume, downloadArgs.outputPath, downloadArgs.textOptions)
+		} else {
+			err = text.PackVolumeToText(volume, downloadArgs.outputPath, downloadArgs.textOptions)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to pack volume: %v", err)
+		}
+	case "markdown":
+		err = markdown.PackVolumeToMarkdown(volume, downloadArgs.outputPath)
 		if err != nil {
-			return fmt.Errorf("failed to download volume: %v", err)
+			return nil, fmt.Errorf("failed to pack volume: %v", err)
 		}
+	case "html":
+		err = htmlbook.PackVolumeToHTML(volume, downloadArgs.outputPath, downloader.GetStyleCSS())
+		if err != nil {
+			return nil, fmt.Errorf("failed to pack volume: %v", err)
+		}
+	case "fb2":
+		err = fb2.PackVolumeToFB2(volume, downloadArgs.outputPath, downloadArgs.fb2Zip)
+		if err != nil {
+			return nil, fmt.Errorf("failed to pack volume: %v", err)
+		}
+	case "cbz":
+		err = cbz.PackVolumeToCBZ(volume, downloadArgs.outputPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to pack volume: %v", err)
+		}
+	case "docx":
+		err = docx.PackVolumeToDOCX(volume, downloadArgs.outputPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to pack volume: %v", err)
+		}
+	case "pdf":
+		opts, err := pdfOptions()
+		if err != nil {
+			return nil, err
+		}
+		err = pdf.PackVolumeToPDF(volume, downloadArgs.outputPath, fontembed.Bundled(), opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to pack volume: %v", err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown output type: %q", downloadArgs.outputType)
 	}
+	return original, nil
+}
 
+// downloadOmnibus 下载所有卷后合并为一本 EPUB/KEPUB、一个 txt 文件或一个插图 CBZ
+func downloadOmnibus(ctx context.Context, downloader model.Downloader, novel *model.Novel) error {
+	switch downloadArgs.outputType {
+	case "epub", "kepub", "text", "cbz":
+	default:
+		return fmt.Errorf("omnibus only supports epub, kepub, text and cbz output")
+	}
+	for i, volume := range novel.Volumes {
+		volume, err := loadVolume(ctx, downloader, novel.Id, volume.Id, downloadArgs.refresh)
+		if err != nil {
+			return err
+		}
+		novel.Volumes[i] = volume
+	}
+	converter, err := chineseConverter()
+	if err != nil {
+		return err
+	}
+	imageOpts, err := imageOptions()
+	if err != nil {
+		return err
+	}
+	// 转换与图片处理只作用于打包的副本，快照保留原文
+	if converter != nil {
+		novel = converter.ConvertNovel(novel)
+	}
+	if imageOpts.Enabled() {
+		novel = imageopt.ProcessNovel(novel, imageOpts)
+	}
+	var styleCSS string
+	var extraFiles []model.ExtraFile
+	if downloadArgs.outputType == "epub" || downloadArgs.outputType == "kepub" {
+		styleCSS, extraFiles, err = epubStyle(downloader, novel.Volumes...)
+		if err != nil {
+			return err
+		}
+	}
+	switch downloadArgs.outputType {
+	case "text":
+		err = text.PackNovelToText(novel, downloadArgs.outputPath, downloadArgs.textOptions)
+	case "kepub":
+		err = kepub.PackNovelToKepub(novel, downloadArgs.outputPath, styleCSS, extraFiles, downloadArgs.epubOptions)
+	case "cbz":
+		err = cbz.PackNovelToCBZ(novel, downloadArgs.outputPath)
+	default:
+		err = epub.PackNovelToEpub(novel, downloadArgs.outputPath, styleCSS, extraFiles, downloadArgs.epubOptions)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to pack novel: %v", err)
+	}
+	switch downloadArgs.outputType {
+	case "epub":
+		return validateOutput(filepath.Join(downloadArgs.outputPath, utils.CleanDirName(novel.Title)+".epub"))
+	case "kepub":
+		return validateOutput(filepath.Join(downloadArgs.outputPath, utils.CleanDirName(novel.Title)+".kepub.epub"))
+	}
 	return nil
 }
 
-func downloadVolume(downloader model.Downloader, volumeId int) error {
-	jsonPath := filepath.Join(downloadArgs.outputPath, fmt.Sprintf("volume-%d-%d.json", downloadArgs.NovelId, volumeId))
+// loadVolume 优先读取 volume-<novel>-<volume>.json 缓存，不存在时下载并写入缓存
+func loadVolume(ctx context.Context, downloader model.Downloader, novelId int, volumeId int, refresh bool) (*model.Volume, error) {
+	jsonPath := filepath.Join(downloadArgs.outputPath, fmt.Sprintf("volume-%d-%d.json", novelId, volumeId))
 	err := os.MkdirAll(filepath.Dir(jsonPath), 0755)
 	if err != nil {
-		return fmt.Errorf("failed to create directory: %v", err)
+		return nil, fmt.Errorf("failed to create directory: %v", err)
 	}
 	_, err = os.Stat(jsonPath)
+	if err == nil && refresh {
+		err = os.ErrNotExist
+	}
 	volume := &model.Volume{}
 	if err != nil {
-		if os.IsNotExist(err) {
-			volume, err = downloader.GetVolume(downloadArgs.NovelId, volumeId, false)
-			if err != nil {
-				return fmt.Errorf("failed to get volume: %v", err)
-			}
-			jsonFile, err := os.Create(jsonPath)
+		if errors.Is(err, os.ErrNotExist) {
+			volume, err = downloader.GetVolumeContext(ctx, novelId, volumeId, false)
 			if err != nil {
-				return fmt.Errorf("failed to create json file: %v", err)
+				return nil, fmt.Errorf("failed to get volume: %w", err)
 			}
-			err = json.NewEncoder(jsonFile).Encode(volume)
+			err = writeVolumeJSON(jsonPath, volume)
 			if err != nil {
-				return fmt.Errorf("failed to encode json file: %v", err)
+				return nil, err
 			}
 		} else {
-			return fmt.Errorf("failed to get volume: %v", err)
+			return nil, fmt.Errorf("failed to get volume: %v", err)
 		}
 	} else {
 		jsonFile, err := os.Open(jsonPath)
 		if err != nil {
-			return fmt.Errorf("failed to open json file: %v", err)
+			return nil, fmt.Errorf("failed to open json file: %v", err)
 		}
 		defer jsonFile.Close()
 		err = json.NewDecoder(jsonFile).Decode(volume)
 		if err != nil {
-			return fmt.Errorf("failed to decode json file: %v", err)
+			return nil, fmt.Errorf("failed to decode json file: %v", err)
 		}
 	}
 
-	switch downloadArgs.outputType {
-	case "epub":
-		err = epub.PackVolumeToEpub(volume, downloadArgs.outputPath, downloader.GetStyleCSS(), downloader.GetExtraFiles())
-		if err != nil {
-			return fmt.Errorf("failed to pack volume: %v", err)
-		}
-	case "text":
-		err = text.PackVolumeToText(volume, downloadArgs.outputPath)
-		if err != nil {
-			return fmt.Errorf("failed to pack volume: %v", err)
-		}
+	return volume, nil
+}
+
+// writeVolumeJSON 先写临时文件再重命名，中途取消不会留下半截的缓存
+func writeVolumeJSON(jsonPath string, volume *model.Volume) error {
+	tmpPath := jsonPath + ".tmp"
+	jsonFile, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create json file: %v", err)
+	}
+	err = json.NewEncoder(jsonFile).Encode(volume)
+	if closeErr := jsonFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to encode json file: %v", err)
+	}
+	if err := os.Rename(tmpPath, jsonPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to rename json file: %v", err)
 	}
 	return nil
 }
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"bilinovel-downloader/epub"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate <file.epub>...",
+	Short: "Check epub files against common epubcheck rules",
+	Long:  "Check epub files against common epubcheck rules: mimetype, container, manifest and spine references, media types, unique ids, well-formed xhtml and the nav document",
+	Args:  cobra.MinimumNArgs(1),
+	// 报告已经输出，失败时只打印错误
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := runValidate(cmd.OutOrStdout(), args); err != nil {
+			return fmt.Errorf("validation failed: %v", err)
+		}
+		return nil
+	},
+}
+
+type validateCmdArgs struct {
+	format string
+}
+
+var (
+	validateArgs validateCmdArgs
+)
+
+func init() {
+	validateCmd.Flags().StringVar(&validateArgs.format, "format", "table", "report format, table or json")
+	RootCmd.AddCommand(validateCmd)
+}
+
+// validationReport 是一个文件的校验结果
+type validationReport struct {
+	File   string       `json:"file"`
+	Issues []epub.Issue `json:"issues"`
+	Error  string       `json:"error,omitempty"`
+}
+
+func runValidate(out io.Writer, files []string) error {
+	if validateArgs.format != "table" && validateArgs.format != "json" {
+		return fmt.Errorf("unknown format: %q", validateArgs.format)
+	}
+	reports := make([]validationReport, 0, len(files))
+	failed := 0
+	for _, file := range files {
+		report := validationReport{File: file, Issues: []epub.Issue{}}
+		issues, err := epub.Validate(file)
+		if err != nil {
+			report.Error = err.Error()
+		} else if issues != nil {
+			report.Issues = issues
+		}
+		if report.Error != "" || len(report.Issues) > 0 {
+			failed++
+		}
+		reports = append(reports, report)
+	}
+
+	if err := writeValidationReports(out, reports); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files have problems", failed, len(files))
+	}
+	return nil
+}
+
+func writeValidationReports(out io.Writer, reports []validationReport) error {
+	if validateArgs.format == "json" {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(reports)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tRULE\tPATH\tMESSAGE")
+	for _, report := range reports {
+		if report.Error != "" {
+			fmt.Fprintf(w, "%v\terror\t\t%v\n", report.File, report.Error)
+			continue
+		}
+		if len(report.Issues) == 0 {
+			fmt.Fprintf(w, "%v\tok\t\t\n", report.File)
+			continue
+		}
+		for _, issue := range report.Issues {
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", report.File, issue.Rule, issue.Path, issue.Message)
+		}
+	}
+	return w.Flush()
+}
+
+// validateOutput 在 --validate 时校验刚生成的 EPUB，发现问题时逐条记录并返回错误
+func validateOutput(epubPath string) error {
+	if !downloadArgs.validate {
+		return nil
+	}
+	issues, err := epub.Validate(epubPath)
+	if err != nil {
+		return err
+	}
+	for _, issue := range issues {
+		log.Printf("Validation issue in %v: %v", epubPath, issue)
+	}
+	if len(issues) > 0 {
+		return fmt.Errorf("%v has %d validation issues", epubPath, len(issues))
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package docx
+
+import (
+	"bilinovel-downloader/htmltext"
+	"strings"
+)
+
+// run 是一段格式相同的文字、一个换行或一张图片
+type run struct {
+	text   string
+	bold   bool
+	italic bool
+	br     bool
+	// image 为 <img src>，即章节 Images 中的文件名
+	image string
+}
+
+type paragraph struct {
+	style string
+	runs  []run
+}
+
+// converter 将章节 HTML 转为段落：块级元素结束一段，<br> 为段内换行，图片单独成段
+type converter struct {
+	paragraphs []paragraph
+	runs       []run
+	bold       int
+	italic     int
+	style      string
+}
+
+func (c *converter) flush() {
+	runs := trimRuns(c.runs)
+	c.runs = nil
+	if len(runs) > 0 {
+		c.paragraphs = append(c.paragraphs, paragraph{style: c.style, runs: runs})
+	}
+}
+
+func (c *converter) Text(text string) {
+	if n := len(c.runs); n > 0 && !c.runs[n-1].br && c.runs[n-1].image == "" &&
+		c.runs[n-1].bold == (c.bold > 0) && c.runs[n-1].italic == (c.italic > 0) {
+		c.runs[n-1].text += text
+		return
+	}
+	c.runs = append(c.runs, run{text: text, bold: c.bold > 0, italic: c.italic > 0})
+}
+
+func (c *converter) LineBreak() {
+	c.runs = append(c.runs, run{br: true})
+}
+
+func (c *converter) Image(src, alt string) {
+	if src == "" {
+		return
+	}
+	c.flush()
+	c.paragraphs = append(c.paragraphs, paragraph{style: "Image", runs: []run{{image: src}}})
+}
+
+func (c *converter) StartBlock(tag string) {
+	c.flush()
+	if htmltext.HeadingLevel(tag) > 0 {
+		c.style = "Heading2"
+	}
+}
+
+func (c *converter) EndBlock(tag string) {
+	c.flush()
+	c.style = ""
+}
+
+func (c *converter) StartStyle(style htmltext.Style) {
+	if style == htmltext.Strong {
+		c.bold++
+	} else {
+		c.italic++
+	}
+}
+
+func (c *converter) EndStyle(style htmltext.Style) {
+	if style == htmltext.Strong {
+		c.bold--
+	} else {
+		c.italic--
+	}
+}
+
+// trimRuns 去掉段首段尾的空白与换行（缩进由段落样式提供），整段为空时返回 nil
+func trimRuns(runs []run) []run {
+	for len(runs) > 0 {
+		if !runs[0].br {
+			runs[0].text = strings.TrimLeft(runs[0].text, htmltext.SpaceChars)
+			if runs[0].text != "" {
+				break
+			}
+		}
+		runs = runs[1:]
+	}
+	for len(runs) > 0 {
+		last := &runs[len(runs)-1]
+		if !last.br {
+			last.text = strings.TrimRight(last.text, htmltext.SpaceChars)
+			if last.text != "" {
+				break
+			}
+		}
+		runs = runs[:len(runs)-1]
+	}
+	return runs
+}
+
+// convertHTML 将章节 HTML 转为段落列表
+func convertHTML(htmlContent string) ([]paragraph, error) {
+	c := &converter{}
+	if err := htmltext.Walk(htmlContent, c); err != nil {
+		return nil, err
+	}
+	c.flush()
+	return c.paragraphs, nil
+}
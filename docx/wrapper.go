@@ -0,0 +1,345 @@
+package docx
+
+import (
+	"archive/zip"
+	"bilinovel-downloader/imageopt"
+	"bilinovel-downloader/model"
+	"bilinovel-downloader/utils"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	// emuPerPixel 按 96 DPI 换算，1 英寸 = 914400 EMU
+	emuPerPixel = 9525
+	// A4 纸减去 2.54cm 页边距后的版心大小（EMU）
+	maxImageWidth  = 5731200
+	maxImageHeight = 8863200
+)
+
+// media 是写入 word/media 的图片
+type media struct {
+	rId    string
+	name   string
+	data   []byte
+	width  int64
+	height int64
+}
+
+// document 记录生成 document.xml 过程中的图片与绘图编号
+type document struct {
+	body     strings.Builder
+	media    []*media
+	byData   map[string]*media
+	drawings int
+}
+
+// PackVolumeToDOCX 将整卷输出为 <卷名>.docx：每章以标题 1 开始，段落转为带格式的文字，
+// 图片嵌入正文，文档属性取自卷信息
+func PackVolumeToDOCX(volume *model.Volume, outputPath string) error {
+	data, err := Marshal(volume)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(outputPath, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create output directory: %v", err)
+	}
+	err = os.WriteFile(filepath.Join(outputPath, utils.CleanDirName(volume.Title)+".docx"), data, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write docx file: %v", err)
+	}
+	return nil
+}
+
+// Marshal 将卷转换为 Office Open XML 文档包
+func Marshal(volume *model.Volume) ([]byte, error) {
+	d := &document{byData: make(map[string]*media)}
+
+	d.paragraph("Title", []run{{text: volume.Title}}, nil)
+	if len(volume.Cover) > 0 {
+		d.paragraph("Image", []run{{image: "cover"}}, map[string][]byte{"cover": volume.Cover})
+	}
+	for _, chapter := range volume.Chapters {
+		if chapter.Content == nil {
+			return nil, fmt.Errorf("chapter %v has no content", chapter.Title)
+		}
+		paragraphs, err := convertHTML(chapter.Content.Html)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert chapter %v: %v", chapter.Title, err)
+		}
+		d.paragraph("Heading1", []run{{text: chapter.Title}}, nil)
+		for _, p := range paragraphs {
+			d.paragraph(p.style, p.runs, chapter.Content.Images)
+		}
+	}
+
+	var buf bytes.Buffer
+	zipWriter := zip.NewWriter(&buf)
+	files := []struct {
+		name string
+		data string
+	}{
+		{"[Content_Types].xml", d.contentTypes()},
+		{"_rels/.rels", rootRels},
+		{"docProps/core.xml", coreProperties(volume)},
+		{"docProps/app.xml", appProperties},
+		{"word/document.xml", documentHeader + d.body.String() + documentFooter},
+		{"word/styles.xml", styles},
+		{"word/_rels/document.xml.rels", d.documentRels()},
+	}
+	for _, file := range files {
+		if err := writeZipEntry(zipWriter, file.name, []byte(file.data)); err != nil {
+			return nil, err
+		}
+	}
+	for _, m := range d.media {
+		if err := writeZipEntry(zipWriter, "word/media/"+m.name, m.data); err != nil {
+			return nil, err
+		}
+	}
+	if err := zipWriter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close zip writer: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func writeZipEntry(zipWriter *zip.Writer, name string, data []byte) error {
+	w, err := zipWriter.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to create zip entry: %v", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("failed to write %v: %v", name, err)
+	}
+	return nil
+}
+
+// paragraph 写入一个段落，images 用于查找图片 run 引用的数据，找不到的图片被跳过
+func (d *document) paragraph(style string, runs []run, images map[string][]byte) {
+	var content strings.Builder
+	for _, r := range runs {
+		switch {
+		case r.br:
+			content.WriteString("<w:r><w:br/></w:r>")
+		case r.image != "":
+			data, ok := images[r.image]
+			if !ok || len(data) == 0 {
+				continue
+			}
+			content.WriteString(d.drawing(d.addMedia(data)))
+		default:
+			content.WriteString("<w:r>")
+			if r.bold || r.italic {
+				content.WriteString("<w:rPr>")
+				if r.bold {
+					content.WriteString("<w:b/>")
+				}
+				if r.italic {
+					content.WriteString("<w:i/>")
+				}
+				content.WriteString("</w:rPr>")
+			}
+			content.WriteString(`<w:t xml:space="preserve">` + escape(r.text) + "</w:t></w:r>")
+		}
+	}
+	if content.Len() == 0 && style == "Image" {
+		return
+	}
+	d.body.WriteString("<w:p>")
+	if style != "" {
+		d.body.WriteString(`<w:pPr><w:pStyle w:val="` + style + `"/></w:pPr>`)
+	}
+	d.body.WriteString(content.String())
+	d.body.WriteString("</w:p>")
+}
+
+// addMedia 登记图片，相同内容只保存一份
+func (d *document) addMedia(data []byte) *media {
+	if m, ok := d.byData[string(data)]; ok {
+		return m
+	}
+	embedded := data
+	// Word 不支持 WebP，嵌入前转为 JPEG（带透明度时为 PNG），转换失败时原样保存
+	if imageopt.Sniff(data) == "webp" {
+		if converted, err := imageopt.Process(data, imageopt.Options{Format: "auto"}); err == nil {
+			embedded = converted
+		}
+	}
+	n := len(d.media) + 1
+	m := &media{
+		rId:  fmt.Sprintf("rIdImage%d", n),
+		name: fmt.Sprintf("image%d.%s", n, imageExt(embedded)),
+		data: embedded,
+	}
+	m.width, m.height = imageSize(embedded)
+	d.media = append(d.media, m)
+	d.byData[string(data)] = m
+	return m
+}
+
+// drawing 返回嵌入式图片的 run，docPr 的 id 在文档内必须唯一
+func (d *document) drawing(m *media) string {
+	d.drawings++
+	id := d.drawings
+	return fmt.Sprintf(`<w:r><w:drawing><wp:inline distT="0" distB="0" distL="0" distR="0">`+
+		`<wp:extent cx="%[1]d" cy="%[2]d"/><wp:docPr id="%[3]d" name="Picture %[3]d"/>`+
+		`<wp:cNvGraphicFramePr><a:graphicFrameLocks xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main" noChangeAspect="1"/></wp:cNvGraphicFramePr>`+
+		`<a:graphic xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main">`+
+		`<a:graphicData uri="http://schemas.openxmlformats.org/drawingml/2006/picture">`+
+		`<pic:pic xmlns:pic="http://schemas.openxmlformats.org/drawingml/2006/picture">`+
+		`<pic:nvPicPr><pic:cNvPr id="%[3]d" name="%[4]s"/><pic:cNvPicPr/></pic:nvPicPr>`+
+		`<pic:blipFill><a:blip r:embed="%[5]s"/><a:stretch><a:fillRect/></a:stretch></pic:blipFill>`+
+		`<pic:spPr><a:xfrm><a:off x="0" y="0"/><a:ext cx="%[1]d" cy="%[2]d"/></a:xfrm><a:prstGeom prst="rect"><a:avLst/></a:prstGeom></pic:spPr>`+
+		`</pic:pic></a:graphicData></a:graphic></wp:inline></w:drawing></w:r>`,
+		m.width, m.height, id, m.name, m.rId)
+}
+
+func imageExt(data []byte) string {
+	ext := strings.TrimPrefix(http.DetectContentType(data), "image/")
+	if ext == "jpeg" {
+		return "jpg"
+	}
+	if strings.Contains(ext, "/") {
+		return "bin"
+	}
+	return ext
+}
+
+// imageSize 返回按 96 DPI 换算并缩放到版心内的图片大小（EMU），无法解码时按 3:4 竖图处理
+func imageSize(data []byte) (int64, int64) {
+	width, height := int64(maxImageWidth), int64(maxImageWidth)*4/3
+	if config, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil && config.Width > 0 && config.Height > 0 {
+		width, height = int64(config.Width)*emuPerPixel, int64(config.Height)*emuPerPixel
+	}
+	if width > maxImageWidth {
+		width, height = maxImageWidth, height*maxImageWidth/width
+	}
+	if height > maxImageHeight {
+		width, height = width*maxImageHeight/height, maxImageHeight
+	}
+	return width, height
+}
+
+func (d *document) contentTypes() string {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">`)
+	b.WriteString(`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>`)
+	b.WriteString(`<Default Extension="xml" ContentType="application/xml"/>`)
+	seen := make(map[string]bool)
+	for _, m := range d.media {
+		ext := filepath.Ext(m.name)[1:]
+		if seen[ext] {
+			continue
+		}
+		seen[ext] = true
+		contentType := http.DetectContentType(m.data)
+		if ext == "bin" {
+			contentType = "application/octet-stream"
+		}
+		fmt.Fprintf(&b, `<Default Extension="%s" ContentType="%s"/>`, ext, contentType)
+	}
+	b.WriteString(`<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>`)
+	b.WriteString(`<Override PartName="/word/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.styles+xml"/>`)
+	b.WriteString(`<Override PartName="/docProps/core.xml" ContentType="application/vnd.openxmlformats-package.core-properties+xml"/>`)
+	b.WriteString(`<Override PartName="/docProps/app.xml" ContentType="application/vnd.openxmlformats-officedocument.extended-properties+xml"/>`)
+	b.WriteString(`</Types>`)
+	return b.String()
+}
+
+func (d *document) documentRels() string {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	b.WriteString(`<Relationship Id="rIdStyles" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`)
+	for _, m := range d.media {
+		fmt.Fprintf(&b, `<Relationship Id="%s" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/image" Target="media/%s"/>`, m.rId, m.name)
+	}
+	b.WriteString(`</Relationships>`)
+	return b.String()
+}
+
+// coreProperties 生成文档属性：标题、作者、简介，系列名写入主题
+func coreProperties(volume *model.Volume) string {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" ` +
+		`xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:dcterms="http://purl.org/dc/terms/" ` +
+		`xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">`)
+	b.WriteString("<dc:title>" + escape(volume.Title) + "</dc:title>")
+	if volume.NovelTitle != "" {
+		b.WriteString("<dc:subject>" + escape(volume.NovelTitle) + "</dc:subject>")
+	}
+	b.WriteString("<dc:creator>" + escape(strings.Join(utils.Unique(volume.Authors), "; ")) + "</dc:creator>")
+	if volume.Description != "" {
+		b.WriteString("<dc:description>" + escape(volume.Description) + "</dc:description>")
+	}
+	b.WriteString("<dc:language>" + volume.Lang() + "</dc:language>")
+	now := utils.BuildTime().Format(time.RFC3339)
+	b.WriteString(`<dcterms:created xsi:type="dcterms:W3CDTF">` + now + "</dcterms:created>")
+	b.WriteString(`<dcterms:modified xsi:type="dcterms:W3CDTF">` + now + "</dcterms:modified>")
+	b.WriteString("</cp:coreProperties>")
+	return b.String()
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+const rootRels = xml.Header +
+	`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/>` +
+	`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/package/2006/relationships/metadata/core-properties" Target="docProps/core.xml"/>` +
+	`<Relationship Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/extended-properties" Target="docProps/app.xml"/>` +
+	`</Relationships>`
+
+const appProperties = xml.Header +
+	`<Properties xmlns="http://schemas.openxmlformats.org/officeDocument/2006/extended-properties">` +
+	`<Application>bilinovel-downloader</Application>` +
+	`</Properties>`
+
+const documentHeader = xml.Header +
+	`<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" ` +
+	`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships" ` +
+	`xmlns:wp="http://schemas.openxmlformats.org/drawingml/2006/wordprocessingDrawing">` +
+	`<w:body>`
+
+// documentFooter 设置 A4 纸张与 2.54cm 页边距（单位为 1/20 磅）
+const documentFooter = `<w:sectPr><w:pgSz w:w="11906" w:h="16838"/>` +
+	`<w:pgMar w:top="1440" w:right="1440" w:bottom="1440" w:left="1440" w:header="851" w:footer="992" w:gutter="0"/>` +
+	`</w:sectPr></w:body></w:document>`
+
+// styles 定义正文首行缩进两字，标题 1 每章另起一页
+const styles = xml.Header +
+	`<w:styles xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">` +
+	`<w:docDefaults><w:rPrDefault><w:rPr><w:sz w:val="24"/><w:lang w:val="en-US" w:eastAsia="zh-CN"/></w:rPr></w:rPrDefault>` +
+	`<w:pPrDefault><w:pPr><w:spacing w:after="120" w:line="360" w:lineRule="auto"/></w:pPr></w:pPrDefault></w:docDefaults>` +
+	`<w:style w:type="paragraph" w:default="1" w:styleId="Normal"><w:name w:val="Normal"/><w:qFormat/>` +
+	`<w:pPr><w:ind w:firstLineChars="200" w:firstLine="480"/><w:jc w:val="both"/></w:pPr></w:style>` +
+	`<w:style w:type="paragraph" w:styleId="Title"><w:name w:val="Title"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/>` +
+	`<w:pPr><w:spacing w:before="240" w:after="240"/><w:ind w:firstLineChars="0" w:firstLine="0"/><w:jc w:val="center"/></w:pPr>` +
+	`<w:rPr><w:b/><w:sz w:val="44"/></w:rPr></w:style>` +
+	`<w:style w:type="paragraph" w:styleId="Heading1"><w:name w:val="heading 1"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/>` +
+	`<w:pPr><w:keepNext/><w:pageBreakBefore/><w:spacing w:before="240" w:after="240"/><w:ind w:firstLineChars="0" w:firstLine="0"/><w:outlineLvl w:val="0"/></w:pPr>` +
+	`<w:rPr><w:b/><w:sz w:val="32"/></w:rPr></w:style>` +
+	`<w:style w:type="paragraph" w:styleId="Heading2"><w:name w:val="heading 2"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/>` +
+	`<w:pPr><w:keepNext/><w:spacing w:before="240" w:after="120"/><w:ind w:firstLineChars="0" w:firstLine="0"/><w:outlineLvl w:val="1"/></w:pPr>` +
+	`<w:rPr><w:b/><w:sz w:val="28"/></w:rPr></w:style>` +
+	`<w:style w:type="paragraph" w:customStyle="1" w:styleId="Image"><w:name w:val="Image"/><w:basedOn w:val="Normal"/>` +
+	`<w:pPr><w:spacing w:line="240" w:lineRule="auto"/><w:ind w:firstLineChars="0" w:firstLine="0"/><w:jc w:val="center"/></w:pPr></w:style>` +
+	`</w:styles>`
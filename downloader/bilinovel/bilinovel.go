@@ -1,65 +1,180 @@
 package bilinovel
 
 import (
+	"bilinovel-downloader/cache"
+	"bilinovel-downloader/fontembed"
+	"bilinovel-downloader/imageopt"
 	"bilinovel-downloader/model"
+	"bilinovel-downloader/processor"
 	"bilinovel-downloader/utils"
 	"bytes"
 	"context"
-	"crypto/sha256"
 	_ "embed"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
-	"path"
 	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	mapper "github.com/bestnite/font-mapper"
 	"github.com/chromedp/cdproto/network"
 	"github.com/chromedp/chromedp"
+	"github.com/go-resty/resty/v2"
 )
 
 //go:embed read.ttf
 var readTTF []byte
 
-//go:embed "MI LANTING.ttf"
-var miLantingTTF []byte
+const DefaultBaseURL = "https://www.bilinovel.com"
 
-type Bilinovel struct {
-	fontMapper  *mapper.GlyphOutlineMapper
-	textOnly    bool
-	restyClient *utils.RestyClient
+const (
+	DefaultConcurrency        = 4
+	DefaultBrowserConcurrency = 2
+)
+
+// DefaultHostOverrides 默认将主站直连到固定 IP，避免 DNS 污染
+var DefaultHostOverrides = map[string]string{
+	"www.bilinovel.com": "64.140.161.52",
+}
 
-	// 浏览器实例复用
+type Bilinovel struct {
+	fontMapper    *mapper.GlyphOutlineMapper
+	textOnly      bool
+	restyClient   *utils.RestyClient
+	cache         *cache.Cache
+	hostOverrides map[string]string
+
+	// 站点镜像，当前镜像出错时依次切换到下一个
+	mirrorMu  sync.Mutex
+	mirrors   []string
+	mirrorIdx int
+
+	// 章节每一页的后处理流水线
+	processors []processor.Processor
+
+	// 不启动浏览器，用纯 Go 还原章节内容
+	noBrowser      bool
+	shuffleRulesMu sync.Mutex
+	shuffleRules   map[string]*shuffleRule
+
+	// 章节与图片的并发数，以及同时打开的浏览器标签页数
+	concurrency        int
+	browserConcurrency int
+	browserSem         chan struct{}
+
+	// 浏览器实例复用，首次需要时才启动
+	browserMu     sync.Mutex
 	allocCtx      context.Context
 	allocCancel   context.CancelFunc
 	browserCtx    context.Context
 	browserCancel context.CancelFunc
 }
 
-func New() (*Bilinovel, error) {
-	fontMapper, err := mapper.NewGlyphOutlineMapper(readTTF, miLantingTTF)
+type Option func(*Bilinovel)
+
+// WithBaseURL 设置站点根地址，默认为 DefaultBaseURL
+func WithBaseURL(baseURL string) Option {
+	return WithMirrors(baseURL)
+}
+
+// WithMirrors 设置站点镜像列表，按顺序使用，出错时切换到下一个
+func WithMirrors(baseURLs ...string) Option {
+	return func(b *Bilinovel) {
+		b.mirrors = make([]string, 0, len(baseURLs))
+		for _, baseURL := range baseURLs {
+			baseURL = strings.TrimSuffix(strings.TrimSpace(baseURL), "/")
+			if baseURL != "" {
+				b.mirrors = append(b.mirrors, baseURL)
+			}
+		}
+		b.mirrorIdx = 0
+	}
+}
+
+// WithConcurrency 设置同时下载的章节数和每页同时下载的图片数，默认为 DefaultConcurrency
+func WithConcurrency(n int) Option {
+	return func(b *Bilinovel) {
+		b.concurrency = n
+	}
+}
+
+// WithBrowserConcurrency 设置同时用于去乱序的浏览器标签页数，默认为 DefaultBrowserConcurrency
+func WithBrowserConcurrency(n int) Option {
+	return func(b *Bilinovel) {
+		b.browserConcurrency = n
+	}
+}
+
+// WithCache 启用章节与图片的磁盘缓存，已缓存的内容不再重复下载
+func WithCache(c *cache.Cache) Option {
+	return func(b *Bilinovel) {
+		b.cache = c
+	}
+}
+
+// WithProcessors 在默认处理器之后追加自定义处理器
+func WithProcessors(processors ...processor.Processor) Option {
+	return func(b *Bilinovel) {
+		b.processors = append(b.processors, processors...)
+	}
+}
+
+// WithNoBrowser 为 true 时不启动 Chrome，直接解析页面样式和 chapterlog.js 还原章节内容，
+// 无法解析时仍会尝试使用 Chrome
+func WithNoBrowser(noBrowser bool) Option {
+	return func(b *Bilinovel) {
+		b.noBrowser = noBrowser
+	}
+}
+
+// WithHostOverrides 设置域名到 IP 的直连映射，默认为 DefaultHostOverrides
+func WithHostOverrides(overrides map[string]string) Option {
+	return func(b *Bilinovel) {
+		b.hostOverrides = overrides
+	}
+}
+
+// WithRestyClient 替换默认的 HTTP 客户端，测试时可传入开启了 Replay 的客户端
+func WithRestyClient(client *utils.RestyClient) Option {
+	return func(b *Bilinovel) {
+		b.restyClient = client
+	}
+}
+
+func New(opts ...Option) (*Bilinovel, error) {
+	fontMapper, err := mapper.NewGlyphOutlineMapper(readTTF, fontembed.Bundled())
 	if err != nil {
 		return nil, fmt.Errorf("failed to create font mapper: %v", err)
 	}
-	restyClient := utils.NewRestyClient(50)
 
 	b := &Bilinovel{
-		fontMapper:  fontMapper,
-		textOnly:    false,
-		restyClient: restyClient,
+		fontMapper:         fontMapper,
+		textOnly:           false,
+		hostOverrides:      DefaultHostOverrides,
+		mirrors:            []string{DefaultBaseURL},
+		concurrency:        DefaultConcurrency,
+		browserConcurrency: DefaultBrowserConcurrency,
+		shuffleRules:       make(map[string]*shuffleRule),
 	}
-
-	// 初始化浏览器实例
-	err = b.initBrowser()
-	if err != nil {
-		return nil, fmt.Errorf("failed to init browser: %v", err)
+	b.processors = b.DefaultProcessors()
+	for _, opt := range opts {
+		opt(b)
 	}
+	if len(b.mirrors) == 0 {
+		return nil, fmt.Errorf("no mirror configured")
+	}
+	if b.restyClient == nil {
+		b.restyClient = utils.NewRestyClient(50)
+	}
+	b.restyClient.SetHostOverrides(b.hostOverrides)
+	b.concurrency = max(b.concurrency, 1)
+	b.browserSem = make(chan struct{}, max(b.browserConcurrency, 1))
 
 	return b, nil
 }
@@ -68,10 +183,70 @@ func (b *Bilinovel) SetTextOnly(textOnly bool) {
 	b.textOnly = textOnly
 }
 
+// Processors 返回当前的处理器流水线，默认为 DefaultProcessors
+func (b *Bilinovel) Processors() []processor.Processor {
+	return b.processors
+}
+
+// SetProcessors 替换整条处理器流水线，可用于调整默认处理器的顺序或去掉其中某些
+func (b *Bilinovel) SetProcessors(processors []processor.Processor) {
+	b.processors = processors
+}
+
 func (b *Bilinovel) GetExtraFiles() []model.ExtraFile {
 	return nil
 }
 
+// baseURL 返回当前使用的镜像地址
+func (b *Bilinovel) baseURL() string {
+	b.mirrorMu.Lock()
+	defer b.mirrorMu.Unlock()
+	return b.mirrors[b.mirrorIdx]
+}
+
+// get 从当前镜像获取站内路径 p，网络错误、被拦截或服务端错误时依次切换到下一个镜像重试
+func (b *Bilinovel) get(ctx context.Context, p string, headers map[string]string) (*resty.Response, error) {
+	b.mirrorMu.Lock()
+	start := b.mirrorIdx
+	b.mirrorMu.Unlock()
+
+	var resp *resty.Response
+	var err error
+	for i := 0; i < len(b.mirrors); i++ {
+		idx := (start + i) % len(b.mirrors)
+		resp, err = b.restyClient.R().SetContext(ctx).SetHeaders(headers).Get(b.mirrors[idx] + p)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err == nil && resp.StatusCode() != http.StatusForbidden && resp.StatusCode() < http.StatusInternalServerError {
+			b.mirrorMu.Lock()
+			b.mirrorIdx = idx
+			b.mirrorMu.Unlock()
+			return resp, nil
+		}
+		if i < len(b.mirrors)-1 {
+			if err != nil {
+				log.Printf("Mirror %v failed: %v, switching to next mirror", b.mirrors[idx], err)
+			} else {
+				log.Printf("Mirror %v failed: %v, switching to next mirror", b.mirrors[idx], resp.Status())
+			}
+		}
+	}
+	return resp, err
+}
+
+// ensureBrowser 在首次处理章节时启动浏览器，之后复用同一实例
+func (b *Bilinovel) ensureBrowser() (context.Context, error) {
+	b.browserMu.Lock()
+	defer b.browserMu.Unlock()
+	if b.browserCtx == nil {
+		if err := b.initBrowser(); err != nil {
+			return nil, err
+		}
+	}
+	return b.browserCtx, nil
+}
+
 // initBrowser 初始化浏览器实例
 func (b *Bilinovel) initBrowser() error {
 	// 创建chromedp选项
@@ -109,10 +284,14 @@ func (b *Bilinovel) closeBrowser() {
 	if b.allocCancel != nil {
 		b.allocCancel()
 	}
+	b.browserCtx, b.browserCancel = nil, nil
+	b.allocCtx, b.allocCancel = nil, nil
 }
 
 // Close 关闭下载器时清理资源
 func (b *Bilinovel) Close() error {
+	b.browserMu.Lock()
+	defer b.browserMu.Unlock()
 	b.closeBrowser()
 	return nil
 }
@@ -125,10 +304,13 @@ func (b *Bilinovel) GetStyleCSS() string {
 }
 
 func (b *Bilinovel) GetNovel(novelId int, skipChapter bool) (*model.Novel, error) {
+	return b.GetNovelContext(context.Background(), novelId, skipChapter)
+}
+
+func (b *Bilinovel) GetNovelContext(ctx context.Context, novelId int, skipChapter bool) (*model.Novel, error) {
 	log.Printf("Getting novel %v\n", novelId)
 
-	novelUrl := fmt.Sprintf("https://www.bilinovel.com/novel/%v.html", novelId)
-	resp, err := b.restyClient.R().Get(novelUrl)
+	resp, err := b.get(ctx, fmt.Sprintf("/novel/%v.html", novelId), nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get novel info: %w", err)
 	}
@@ -154,7 +336,7 @@ func (b *Bilinovel) GetNovel(novelId int, skipChapter bool) (*model.Novel, error
 		novel.Authors = append(novel.Authors, strings.TrimSpace(s.Text()))
 	})
 
-	volumes, err := b.getAllVolumes(novelId, skipChapter)
+	volumes, err := b.getAllVolumes(ctx, novelId, skipChapter)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get novel volumes: %v", err)
 	}
@@ -164,10 +346,13 @@ func (b *Bilinovel) GetNovel(novelId int, skipChapter bool) (*model.Novel, error
 }
 
 func (b *Bilinovel) GetVolume(novelId int, volumeId int, skipChapter bool) (*model.Volume, error) {
+	return b.GetVolumeContext(context.Background(), novelId, volumeId, skipChapter)
+}
+
+func (b *Bilinovel) GetVolumeContext(ctx context.Context, novelId int, volumeId int, skipChapter bool) (*model.Volume, error) {
 	log.Printf("Getting volume %v of novel %v\n", volumeId, novelId)
 
-	novelUrl := fmt.Sprintf("https://www.bilinovel.com/novel/%v/catalog", novelId)
-	resp, err := b.restyClient.R().Get(novelUrl)
+	resp, err := b.get(ctx, fmt.Sprintf("/novel/%v/catalog", novelId), nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get novel info: %w", err)
 	}
@@ -193,10 +378,9 @@ func (b *Bilinovel) GetVolume(novelId int, volumeId int, skipChapter bool) (*mod
 		return nil, fmt.Errorf("volume not found: %v", volumeId)
 	}
 
-	volumeUrl := fmt.Sprintf("https://www.bilinovel.com/novel/%v/vol_%v.html", novelId, volumeId)
-	resp, err = b.restyClient.R().Get(volumeUrl)
+	resp, err = b.get(ctx, fmt.Sprintf("/novel/%v/vol_%v.html", novelId, volumeId), nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get novel info: %v", err)
+		return nil, fmt.Errorf("failed to get novel info: %w", err)
 	}
 	if resp.StatusCode() != http.StatusOK {
 		return nil, fmt.Errorf("failed to get novel info: %v", resp.Status())
@@ -214,12 +398,13 @@ func (b *Bilinovel) GetVolume(novelId int, volumeId int, skipChapter bool) (*mod
 	volume.SeriesIdx = seriesIdx
 	volume.Title = strings.TrimSpace(doc.Find(".book-title").First().Text())
 	volume.Description = strings.TrimSpace(doc.Find(".book-summary>content").First().Text())
-	volume.Url = volumeUrl
+	volume.Url = resp.Request.URL
 	volume.Chapters = make([]*model.Chapter, 0)
+	baseURL := strings.TrimSuffix(volume.Url, fmt.Sprintf("/novel/%v/vol_%v.html", novelId, volumeId))
 	volume.CoverUrl = doc.Find(".book-cover").First().AttrOr("src", "")
-	cover, err := b.getImg(volume.CoverUrl)
+	cover, err := b.getImg(ctx, volume.CoverUrl)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get cover: %v", err)
+		return nil, fmt.Errorf("failed to get cover: %w", err)
 	}
 	volume.Cover = cover
 
@@ -232,13 +417,14 @@ func (b *Bilinovel) GetVolume(novelId int, volumeId int, skipChapter bool) (*mod
 	doc.Find(".chapter-li.jsChapter").Each(func(i int, s *goquery.Selection) {
 		volume.Chapters = append(volume.Chapters, &model.Chapter{
 			Title: s.Find("a").Text(),
-			Url:   fmt.Sprintf("https://www.bilinovel.com%v", s.Find("a").AttrOr("href", "")),
+			Url:   fmt.Sprintf("%v%v", baseURL, s.Find("a").AttrOr("href", "")),
 		})
 	})
 
 	idRegexp := regexp.MustCompile(`/novel/(\d+)/(\d+).html`)
 
 	if !skipChapter {
+		chapterIds := make([]int, len(volume.Chapters))
 		for i := range volume.Chapters {
 			matches := idRegexp.FindStringSubmatch(volume.Chapters[i].Url)
 			if len(matches) > 0 {
@@ -246,28 +432,36 @@ func (b *Bilinovel) GetVolume(novelId int, volumeId int, skipChapter bool) (*mod
 				if err != nil {
 					return nil, fmt.Errorf("failed to convert chapter id: %v", err)
 				}
-				chapter, err := b.GetChapter(novelId, volumeId, chapterId)
-				if err != nil {
-					return nil, fmt.Errorf("failed to get chapter: %v", err)
-				}
-				chapter.Id = chapterId
-				volume.Chapters[i] = chapter
+				chapterIds[i] = chapterId
 			} else {
 				return nil, fmt.Errorf("failed to get chapter id: %v", volume.Chapters[i].Url)
 			}
 		}
+
+		// 并发下载章节，按下标写回以保持章节顺序
+		err = utils.ParallelFor(ctx, b.concurrency, len(chapterIds), func(ctx context.Context, i int) error {
+			chapter, err := b.GetChapterContext(ctx, novelId, volumeId, chapterIds[i])
+			if err != nil {
+				return fmt.Errorf("failed to get chapter: %w", err)
+			}
+			chapter.Id = chapterIds[i]
+			volume.Chapters[i] = chapter
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return volume, nil
 }
 
-func (b *Bilinovel) getAllVolumes(novelId int, skipChapter bool) ([]*model.Volume, error) {
+func (b *Bilinovel) getAllVolumes(ctx context.Context, novelId int, skipChapter bool) ([]*model.Volume, error) {
 	log.Printf("Getting all volumes of novel %v\n", novelId)
 
-	catelogUrl := fmt.Sprintf("https://www.bilinovel.com/novel/%v/catalog", novelId)
-	resp, err := b.restyClient.R().Get(catelogUrl)
+	resp, err := b.get(ctx, fmt.Sprintf("/novel/%v/catalog", novelId), nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get catelog: %v", err)
+		return nil, fmt.Errorf("failed to get catelog: %w", err)
 	}
 	if resp.StatusCode() != http.StatusOK {
 		return nil, fmt.Errorf("failed to get catelog: %v", resp.Status())
@@ -295,9 +489,9 @@ func (b *Bilinovel) getAllVolumes(novelId int, skipChapter bool) ([]*model.Volum
 		if err != nil {
 			return nil, fmt.Errorf("failed to convert volume id: %v", err)
 		}
-		volume, err := b.GetVolume(novelId, volumeId, skipChapter)
+		volume, err := b.GetVolumeContext(ctx, novelId, volumeId, skipChapter)
 		if err != nil {
-			return nil, fmt.Errorf("failed to get volume info: %v", err)
+			return nil, fmt.Errorf("failed to get volume info: %w", err)
 		}
 		volume.SeriesIdx = i
 		volumes = append(volumes, volume)
@@ -307,6 +501,19 @@ func (b *Bilinovel) getAllVolumes(novelId int, skipChapter bool) ([]*model.Volum
 }
 
 func (b *Bilinovel) GetChapter(novelId int, volumeId int, chapterId int) (*model.Chapter, error) {
+	return b.GetChapterContext(context.Background(), novelId, volumeId, chapterId)
+}
+
+func (b *Bilinovel) GetChapterContext(ctx context.Context, novelId int, volumeId int, chapterId int) (*model.Chapter, error) {
+	// 纯文本模式下的章节不含图片，不读写缓存
+	useCache := b.cache != nil && !b.textOnly
+	if useCache {
+		if chapter, ok := b.cache.LoadChapter(novelId, volumeId, chapterId); ok {
+			log.Printf("Using cached chapter %v of novel %v\n", chapterId, novelId)
+			return chapter, nil
+		}
+	}
+
 	log.Printf("Getting chapter %v of novel %v\n", chapterId, novelId)
 
 	page := 1
@@ -314,10 +521,10 @@ func (b *Bilinovel) GetChapter(novelId int, volumeId int, chapterId int) (*model
 		Id:       chapterId,
 		NovelId:  novelId,
 		VolumeId: volumeId,
-		Url:      fmt.Sprintf("https://www.bilinovel.com/novel/%v/%v.html", novelId, chapterId),
+		Url:      fmt.Sprintf("%v/novel/%v/%v.html", b.baseURL(), novelId, chapterId),
 	}
 	for {
-		hasNext, err := b.getChapterByPage(chapter, page)
+		hasNext, err := b.getChapterByPage(ctx, chapter, page)
 		if err != nil {
 			return nil, fmt.Errorf("failed to download chapter: %w", err)
 		}
@@ -326,21 +533,24 @@ func (b *Bilinovel) GetChapter(novelId int, volumeId int, chapterId int) (*model
 		}
 		page++
 	}
+	if useCache {
+		if err := b.cache.SaveChapter(chapter); err != nil {
+			log.Printf("Failed to cache chapter %v: %v", chapterId, err)
+		}
+	}
 	return chapter, nil
 }
 
-func (b *Bilinovel) getChapterByPage(chapter *model.Chapter, page int) (bool, error) {
+func (b *Bilinovel) getChapterByPage(ctx context.Context, chapter *model.Chapter, page int) (bool, error) {
 	log.Printf("Getting chapter %v by page %v\n", chapter.Id, page)
 
-	Url := strings.TrimSuffix(chapter.Url, ".html") + fmt.Sprintf("_%v.html", page)
-
 	hasNext := false
 	headers := map[string]string{
 		"Accept":          "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7",
 		"Accept-Language": "zh-CN,zh;q=0.9,en-GB;q=0.8,en;q=0.7,zh-TW;q=0.6",
 		"Cookie":          "night=1;",
 	}
-	resp, err := b.restyClient.R().SetHeaders(headers).Get(Url)
+	resp, err := b.get(ctx, fmt.Sprintf("/novel/%v/%v_%v.html", chapter.NovelId, chapter.Id, page), headers)
 	if err != nil {
 		return false, fmt.Errorf("failed to get chapter: %w", err)
 	}
@@ -354,7 +564,22 @@ func (b *Bilinovel) getChapterByPage(chapter *model.Chapter, page int) (bool, er
 
 	html := resp.Body()
 	// 解决乱序问题
-	resortedHtml, err := b.processContentWithChromedp(string(html))
+	var resortedHtml string
+	if b.noBrowser {
+		resortedHtml, err = b.processContentWithoutBrowser(ctx, resp.Request.URL, chapter.Id, string(html))
+		// 站点更换了脚本格式时改用浏览器，只有浏览器也不可用时才报错
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Failed to process chapter %v without browser, falling back to browser: %v", chapter.Id, err)
+			var browserErr error
+			if resortedHtml, browserErr = b.processContentWithChromedp(ctx, string(html)); browserErr == nil {
+				err = nil
+			} else {
+				err = fmt.Errorf("%w (browser fallback: %v)", err, browserErr)
+			}
+		}
+	} else {
+		resortedHtml, err = b.processContentWithChromedp(ctx, string(html))
+	}
 	if err != nil {
 		return false, fmt.Errorf("failed to process html: %w", err)
 	}
@@ -367,53 +592,16 @@ func (b *Bilinovel) getChapterByPage(chapter *model.Chapter, page int) (bool, er
 		chapter.Title = doc.Find("#atitle").Text()
 	}
 	content := doc.Find("#acontent").First()
-	content.Find(".cgo").Remove()
-	content.Find("center").Remove()
-	content.Find(".google-auto-placed").Remove()
-
-	if strings.Contains(resp.String(), `font-family: "read"`) {
-		html, err := content.Find("p").Last().Html()
-		if err != nil {
-			return false, fmt.Errorf("failed to get html: %v", err)
-		}
-		builder := strings.Builder{}
-		for _, r := range html {
-			_, newRune, ok := b.fontMapper.MappingRune(r)
-			if ok {
-				builder.WriteRune(newRune)
-			}
+	p := &processor.Page{
+		Chapter: chapter,
+		Number:  page,
+		Html:    resp.String(),
+		Content: content,
+	}
+	for _, proc := range b.processors {
+		if err := proc.Process(ctx, p); err != nil {
+			return false, fmt.Errorf("processor %v failed: %w", proc.Name(), err)
 		}
-		content.Find("p").Last().SetHtml(builder.String())
-	}
-
-	if b.textOnly {
-		content.Find("img").Remove()
-	} else {
-		content.Find("img").Each(func(i int, s *goquery.Selection) {
-			imgUrl := s.AttrOr("data-src", "")
-			if imgUrl == "" {
-				imgUrl = s.AttrOr("src", "")
-				if imgUrl == "" {
-					return
-				}
-			}
-
-			imageHash := sha256.Sum256([]byte(imgUrl))
-			imageFilename := fmt.Sprintf("%x%s", string(imageHash[:]), path.Ext(imgUrl))
-			s.SetAttr("src", imageFilename)
-			s.SetAttr("alt", imgUrl)
-			img, err := b.getImg(imgUrl)
-			if err != nil {
-				return
-			}
-			if chapter.Content == nil {
-				chapter.Content = &model.ChaperContent{}
-			}
-			if chapter.Content.Images == nil {
-				chapter.Content.Images = make(map[string][]byte)
-			}
-			chapter.Content.Images[imageFilename] = img
-		})
 	}
 
 	htmlStr, err := content.Html()
@@ -429,18 +617,49 @@ func (b *Bilinovel) getChapterByPage(chapter *model.Chapter, page int) (bool, er
 	return hasNext, nil
 }
 
-func (b *Bilinovel) getImg(url string) ([]byte, error) {
+func (b *Bilinovel) getImg(ctx context.Context, url string) ([]byte, error) {
+	imageFilename := cache.ImageFilename(url)
+	if b.cache != nil {
+		if img, ok := b.cache.LoadImage(imageFilename); ok {
+			return img, nil
+		}
+	}
+
 	log.Printf("Getting img %v\n", url)
-	resp, err := b.restyClient.R().SetHeader("Referer", "https://www.bilinovel.com").Get(url)
+	resp, err := b.restyClient.R().SetContext(ctx).SetHeader("Referer", b.baseURL()).Get(url)
 	if err != nil {
 		return nil, err
 	}
+	if resp.StatusCode() != http.StatusOK {
+		return nil, fmt.Errorf("failed to get img %v: unexpected status %v", url, resp.Status())
+	}
+	// 站点出错时可能以 200 返回 HTML 页面，不能当作图片保存
+	if imageopt.Sniff(resp.Body()) == "" {
+		return nil, fmt.Errorf("failed to get img %v: unexpected content type %v", url, http.DetectContentType(resp.Body()))
+	}
 
+	if b.cache != nil {
+		if err := b.cache.SaveImage(imageFilename, resp.Body()); err != nil {
+			log.Printf("Failed to cache img %v: %v", url, err)
+		}
+	}
 	return resp.Body(), nil
 }
 
 // processContentWithChromedp 使用复用的浏览器实例处理内容
-func (b *Bilinovel) processContentWithChromedp(htmlContent string) (string, error) {
+func (b *Bilinovel) processContentWithChromedp(parent context.Context, htmlContent string) (string, error) {
+	browserCtx, err := b.ensureBrowser()
+	if err != nil {
+		return "", fmt.Errorf("failed to init browser: %w", err)
+	}
+
+	select {
+	case b.browserSem <- struct{}{}:
+	case <-parent.Done():
+		return "", parent.Err()
+	}
+	defer func() { <-b.browserSem }()
+
 	tempFile, err := os.CreateTemp("", "bilinovel-temp-*.html")
 	if err != nil {
 		return "", fmt.Errorf("failed to create temp file: %w", err)
@@ -454,9 +673,14 @@ func (b *Bilinovel) processContentWithChromedp(htmlContent string) (string, erro
 	tempFile.Close()
 	tempFilePath := tempFile.Name()
 
-	// 为当前任务创建子上下文
-	ctx, cancel := context.WithTimeout(b.browserCtx, 30*time.Second)
+	// 为当前任务新开一个标签页，以便多个章节并发处理
+	// chromedp 的上下文必须派生自浏览器上下文，调用方取消时一并取消
+	tabCtx, tabCancel := chromedp.NewContext(browserCtx)
+	defer tabCancel()
+	ctx, cancel := context.WithTimeout(tabCtx, 30*time.Second)
 	defer cancel()
+	stop := context.AfterFunc(parent, cancel)
+	defer stop()
 
 	var processedHTML string
 
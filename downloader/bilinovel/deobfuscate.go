@@ -0,0 +1,191 @@
+package bilinovel
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// shuffleRule 描述 chapterlog.js 打乱段落的方式：
+// 以 chapterId*seedMul+seedAdd 为种子，用线性同余生成器对前 fixed 段之后的段落做 Fisher-Yates 洗牌
+type shuffleRule struct {
+	seedMul, seedAdd       int64
+	lcgMul, lcgAdd, lcgMod int64
+	fixed                  int
+}
+
+// 站点脚本经过压缩，数字可能写作十六进制，变量声明可能以逗号分隔
+const jsNumber = `(0[xX][0-9a-fA-F]+|\d+)`
+
+var (
+	lcgRegexp   = regexp.MustCompile(`\(\s*[\w$.]+\s*\*\s*` + jsNumber + `\s*\+\s*` + jsNumber + `\s*\)\s*%\s*` + jsNumber)
+	seedRegexp  = regexp.MustCompile(`=\s*[\w$.]+\s*\*\s*` + jsNumber + `\s*\+\s*` + jsNumber + `\s*[;,]`)
+	fixedRegexp = regexp.MustCompile(`\.slice\(\s*(?:0|0[xX]0+)\s*,\s*` + jsNumber + `\s*\)`)
+
+	cssRuleRegexp     = regexp.MustCompile(`([^{}]+)\{([^{}]*)\}`)
+	cssCommentRegexp  = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	displayNoneRegexp = regexp.MustCompile(`(?i)(^|;)\s*display\s*:\s*none\s*(!important\s*)?(;|$)`)
+	pseudoRegexp      = regexp.MustCompile(`(?i)::|:(before|after|hover|focus|active|visited|first-line|first-letter)`)
+)
+
+// parseShuffleRule 从 chapterlog.js 源码中提取洗牌参数
+func parseShuffleRule(script string) (*shuffleRule, error) {
+	lcg := lcgRegexp.FindStringSubmatch(script)
+	seed := seedRegexp.FindStringSubmatch(script)
+	fixed := fixedRegexp.FindStringSubmatch(script)
+	if lcg == nil || seed == nil || fixed == nil {
+		return nil, fmt.Errorf("unrecognized chapterlog script")
+	}
+
+	rule := &shuffleRule{}
+	var err error
+	for dst, src := range map[*int64]string{
+		&rule.seedMul: seed[1], &rule.seedAdd: seed[2],
+		&rule.lcgMul: lcg[1], &rule.lcgAdd: lcg[2], &rule.lcgMod: lcg[3],
+	} {
+		if *dst, err = strconv.ParseInt(src, 0, 64); err != nil {
+			return nil, fmt.Errorf("failed to parse chapterlog script: %v", err)
+		}
+	}
+	fixedN, err := strconv.ParseInt(fixed[1], 0, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse chapterlog script: %v", err)
+	}
+	rule.fixed = int(fixedN)
+	if rule.lcgMod == 0 {
+		return nil, fmt.Errorf("unrecognized chapterlog script")
+	}
+	return rule, nil
+}
+
+// order 返回第 i 个页面段落在原文中的位置，与 chapterlog.js 的计算完全一致（包括浮点运算）
+func (r *shuffleRule) order(chapterId int, n int) []int {
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	if n <= r.fixed {
+		return order
+	}
+
+	rest := order[r.fixed:]
+	seed := int64(chapterId)*r.seedMul + r.seedAdd
+	for i := len(rest) - 1; i > 0; i-- {
+		seed = (seed*r.lcgMul + r.lcgAdd) % r.lcgMod
+		j := int(math.Floor(float64(seed) / float64(r.lcgMod) * float64(i+1)))
+		rest[i], rest[j] = rest[j], rest[i]
+	}
+	return order
+}
+
+// getShuffleRule 下载并解析 chapterlog.js，同一地址只解析一次
+func (b *Bilinovel) getShuffleRule(ctx context.Context, scriptUrl string) (*shuffleRule, error) {
+	b.shuffleRulesMu.Lock()
+	rule, ok := b.shuffleRules[scriptUrl]
+	b.shuffleRulesMu.Unlock()
+	if ok {
+		return rule, nil
+	}
+
+	resp, err := b.restyClient.R().SetContext(ctx).SetHeader("Referer", b.baseURL()).Get(scriptUrl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chapterlog script: %w", err)
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return nil, fmt.Errorf("failed to get chapterlog script: %v", resp.Status())
+	}
+	rule, err = parseShuffleRule(resp.String())
+	if err != nil {
+		return nil, err
+	}
+
+	b.shuffleRulesMu.Lock()
+	b.shuffleRules[scriptUrl] = rule
+	b.shuffleRulesMu.Unlock()
+	return rule, nil
+}
+
+// processContentWithoutBrowser 不启动浏览器完成与 processContentWithChromedp 相同的工作：
+// 按 chapterlog.js 的规则还原 #acontent 段落顺序，再删除内联样式与 <style> 中 display:none 的元素
+func (b *Bilinovel) processContentWithoutBrowser(ctx context.Context, pageUrl string, chapterId int, htmlContent string) (string, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(htmlContent))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse html: %w", err)
+	}
+	content := doc.Find("#acontent").First()
+	if content.Length() == 0 {
+		return "", fmt.Errorf("acontent element not found")
+	}
+
+	// 与浏览器中的执行顺序一致：先由脚本还原顺序（隐藏段落也参与计算），再删除隐藏元素。
+	// 找不到脚本说明站点改了加载方式，此时无法判断段落是否被打乱，不能原样输出
+	scriptSrc := doc.Find(`script[src*="chapterlog"]`).First().AttrOr("src", "")
+	if scriptSrc == "" {
+		return "", fmt.Errorf("chapterlog script not found")
+	}
+	base, err := url.Parse(pageUrl)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse page url: %w", err)
+	}
+	scriptUrl, err := base.Parse(scriptSrc)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse chapterlog url: %w", err)
+	}
+	rule, err := b.getShuffleRule(ctx, scriptUrl.String())
+	if err != nil {
+		return "", err
+	}
+
+	// 段落数不超过 fixed 时脚本直接返回，不会移动任何元素
+	paragraphs := content.Find("p")
+	if paragraphs.Length() > rule.fixed {
+		order := rule.order(chapterId, paragraphs.Length())
+		sorted := make([]*goquery.Selection, paragraphs.Length())
+		paragraphs.Each(func(i int, s *goquery.Selection) {
+			sorted[order[i]] = s
+		})
+		for _, s := range sorted {
+			content.AppendSelection(s)
+		}
+	}
+
+	removed := removeHiddenElements(doc, content)
+	log.Printf("Hidden elements removal result: Removed %v hidden elements", removed)
+
+	return goquery.OuterHtml(doc.Selection)
+}
+
+// removeHiddenElements 删除 content 内 display:none 的元素，规则来自页面内的 <style> 与 style 属性
+func removeHiddenElements(doc *goquery.Document, content *goquery.Selection) int {
+	hidden := content.Find("[style]").FilterFunction(func(i int, s *goquery.Selection) bool {
+		return displayNoneRegexp.MatchString(s.AttrOr("style", ""))
+	})
+
+	doc.Find("style").Each(func(i int, s *goquery.Selection) {
+		css := cssCommentRegexp.ReplaceAllString(s.Text(), "")
+		for _, rule := range cssRuleRegexp.FindAllStringSubmatch(css, -1) {
+			if !displayNoneRegexp.MatchString(strings.TrimSpace(rule[2])) {
+				continue
+			}
+			for _, selector := range strings.Split(rule[1], ",") {
+				// 伪元素和交互伪类在静态页面中不会生效；无法解析的选择器 Find 返回空
+				if pseudoRegexp.MatchString(selector) {
+					continue
+				}
+				hidden = hidden.AddSelection(content.Find(strings.TrimSpace(selector)))
+			}
+		}
+	})
+
+	removed := hidden.Length()
+	hidden.Remove()
+	return removed
+}
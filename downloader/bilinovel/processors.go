@@ -0,0 +1,99 @@
+package bilinovel
+
+import (
+	"bilinovel-downloader/cache"
+	"bilinovel-downloader/model"
+	"bilinovel-downloader/processor"
+	"bilinovel-downloader/utils"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// DefaultProcessors 返回内置的处理器：去广告、还原 "read" 字体、下载并改写图片
+func (b *Bilinovel) DefaultProcessors() []processor.Processor {
+	return []processor.Processor{
+		&processor.RemoveElements{Selectors: []string{".cgo", "center", ".google-auto-placed"}},
+		processor.Func("read-font", b.remapReadFont),
+		processor.Func("images", b.processImages),
+	}
+}
+
+// remapReadFont 页面使用 "read" 字体时，最后一段的字形被替换过，需要映射回正常字符
+func (b *Bilinovel) remapReadFont(ctx context.Context, page *processor.Page) error {
+	if !strings.Contains(page.Html, `font-family: "read"`) {
+		return nil
+	}
+	html, err := page.Content.Find("p").Last().Html()
+	if err != nil {
+		return fmt.Errorf("failed to get html: %v", err)
+	}
+	builder := strings.Builder{}
+	for _, r := range html {
+		_, newRune, ok := b.fontMapper.MappingRune(r)
+		if ok {
+			builder.WriteRune(newRune)
+		}
+	}
+	page.Content.Find("p").Last().SetHtml(builder.String())
+	return nil
+}
+
+// processImages 纯文本模式下删除图片，否则并发下载图片并把 src 改写为本地文件名
+func (b *Bilinovel) processImages(ctx context.Context, page *processor.Page) error {
+	if b.textOnly {
+		page.Content.Find("img").Remove()
+		return nil
+	}
+
+	type pageImage struct {
+		url      string
+		filename string
+		data     []byte
+	}
+	images := make([]*pageImage, 0)
+	page.Content.Find("img").Each(func(i int, s *goquery.Selection) {
+		imgUrl := s.AttrOr("data-src", "")
+		if imgUrl == "" {
+			imgUrl = s.AttrOr("src", "")
+			if imgUrl == "" {
+				return
+			}
+		}
+
+		imageFilename := cache.ImageFilename(imgUrl)
+		s.SetAttr("src", imageFilename)
+		s.SetAttr("alt", imgUrl)
+		images = append(images, &pageImage{url: imgUrl, filename: imageFilename})
+	})
+
+	// 单张图片失败不影响章节，因此不返回错误
+	_ = utils.ParallelFor(ctx, b.concurrency, len(images), func(ctx context.Context, i int) error {
+		img, err := b.getImg(ctx, images[i].url)
+		if err == nil {
+			images[i].data = img
+		}
+		return nil
+	})
+	// 取消时图片会静默失败，避免把不完整的章节当作成功返回
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	chapter := page.Chapter
+	for _, img := range images {
+		if img.data == nil {
+			continue
+		}
+		if chapter.Content == nil {
+			chapter.Content = &model.ChaperContent{}
+		}
+		if chapter.Content.Images == nil {
+			chapter.Content.Images = make(map[string][]byte)
+		}
+		chapter.Content.Images[img.filename] = img.data
+	}
+	return nil
+}
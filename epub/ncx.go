@@ -0,0 +1,68 @@
+package epub
+
+import (
+	"bilinovel-downloader/model"
+	"bilinovel-downloader/template"
+	"fmt"
+	"path/filepath"
+)
+
+// Options 控制 EPUB 的生成方式
+type Options struct {
+	// EPUB2Compat 额外生成 toc.ncx 并填写 <guide>，供只认 EPUB 2 目录的阅读器与转换工具使用
+	EPUB2Compat bool
+	// Vertical 使用竖排（vertical-rl）样式，翻页方向为从右到左
+	Vertical bool
+}
+
+var ncxItem = model.ManifestItem{ID: "ncx", Link: "toc.ncx", Media: "application/x-dtbncx+xml"}
+
+// addCompat 在清单中加入 toc.ncx、在 spine 上引用它，并返回指向封面、目录与正文首页的 guide
+func addCompat(manifest *model.Manifest, spine *model.Spine, firstText string) *model.Guide {
+	manifest.Items = append(manifest.Items, ncxItem)
+	spine.Toc = ncxItem.ID
+	guide := &model.Guide{Items: []model.GuideItem{
+		{Title: "封面", Type: "cover", Link: "OEBPS/Text/cover.xhtml"},
+		{Title: "目录", Type: "toc", Link: "OEBPS/Text/contents.xhtml"},
+	}}
+	if firstText != "" {
+		guide.Items = append(guide.Items, model.GuideItem{Title: "正文", Type: "text", Link: firstText})
+	}
+	return guide
+}
+
+// writeNCX 写出 toc.ncx，navPoints 按阅读顺序排列，playOrder 在此统一编号
+func writeNCX(outputPath string, uuid string, title string, navPoints []model.NavPoint) error {
+	depth := 1
+	order := 0
+	var number func(points []model.NavPoint, level int)
+	number = func(points []model.NavPoint, level int) {
+		if len(points) > 0 {
+			depth = max(depth, level)
+		}
+		for i := range points {
+			order++
+			points[i].ID = fmt.Sprintf("navpoint-%d", order)
+			points[i].PlayOrder = order
+			number(points[i].Children, level+1)
+		}
+	}
+	number(navPoints, 1)
+
+	ncx := &model.NCX{
+		Xmlns:   "http://www.daisy.org/z3986/2005/ncx/",
+		Version: "2005-1",
+		Metas: []model.NCXMeta{
+			{Name: "dtb:uid", Content: fmt.Sprintf("urn:uuid:%s", uuid)},
+			{Name: "dtb:depth", Content: fmt.Sprint(depth)},
+			{Name: "dtb:totalPageCount", Content: "0"},
+			{Name: "dtb:maxPageNumber", Content: "0"},
+		},
+		DocTitle:  title,
+		NavPoints: navPoints,
+	}
+	if err := renderToFile(filepath.Join(outputPath, ncxItem.Link), template.TocNCX(ncx)); err != nil {
+		return fmt.Errorf("failed to render toc.ncx: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,246 @@
+package epub
+
+import (
+	"bilinovel-downloader/model"
+	"bilinovel-downloader/template"
+	"bilinovel-downloader/utils"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PackNovelToEpub 将整部小说打包为一本合集 EPUB：目录按 卷 → 章节 两级嵌套，
+// 每卷前插入该卷封面页，书籍元数据取自小说本身。novel.Volumes 需已包含章节内容
+func PackNovelToEpub(novel *model.Novel, outputPath string, styleCSS string, extraFiles []model.ExtraFile, opts Options) error {
+	if len(novel.Volumes) == 0 {
+		return fmt.Errorf("novel has no volumes")
+	}
+
+	outputPath = filepath.Join(outputPath, utils.CleanDirName(novel.Title))
+	if err := os.RemoveAll(outputPath); err != nil {
+		return fmt.Errorf("failed to remove output directory: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(outputPath, "OEBPS/Text"), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %v", err)
+	}
+
+	for vi, volume := range novel.Volumes {
+		chooseCover(volume)
+
+		// 卷封面 OEBPS/Images/volume-%03v-cover.<ext> 与封面页 OEBPS/Text/volume-%03v.xhtml
+		coverName := fmt.Sprintf("volume-%03v-cover.%s", vi, imageExtension(volume.Cover, volume.CoverUrl))
+		coverPath := filepath.Join(outputPath, "OEBPS/Images", coverName)
+		if err := os.MkdirAll(filepath.Dir(coverPath), 0755); err != nil {
+			return fmt.Errorf("failed to create image directory: %v", err)
+		}
+		if err := os.WriteFile(coverPath, volume.Cover, 0644); err != nil {
+			return fmt.Errorf("failed to write volume cover: %v", err)
+		}
+		if err := renderToFile(filepath.Join(outputPath, fmt.Sprintf("OEBPS/Text/volume-%03v.xhtml", vi)),
+			template.CoverXHTML(volume.Lang(), fmt.Sprintf("../Images/%s", coverName))); err != nil {
+			return fmt.Errorf("failed to render volume cover XHTML: %v", err)
+		}
+
+		for ci, chapter := range volume.Chapters {
+			if chapter == nil {
+				continue
+			}
+			name := fmt.Sprintf("volume-%03v-chapter-%03v", vi, ci)
+
+			text := chapter.Content.Html
+			for _, imgName := range sortedImageNames(chapter.Content.Images) {
+				imgData := chapter.Content.Images[imgName]
+				imgFile := imageFileName(imgName, imgData)
+				imgPath := filepath.Join(outputPath, "OEBPS/Images", name, imgFile)
+				if err := os.MkdirAll(filepath.Dir(imgPath), 0755); err != nil {
+					return fmt.Errorf("failed to create image directory: %v", err)
+				}
+				if err := os.WriteFile(imgPath, imgData, 0644); err != nil {
+					return fmt.Errorf("failed to write image: %v", err)
+				}
+				text = strings.ReplaceAll(text, imgName, fmt.Sprintf("../Images/%s/%s", name, imgFile))
+			}
+
+			text, err := opts.chapterHTML(text)
+			if err != nil {
+				return err
+			}
+			if err := renderToFile(filepath.Join(outputPath, fmt.Sprintf("OEBPS/Text/%s.xhtml", name)),
+				template.ContentXHTML(volume.Lang(), chapter.Title, text)); err != nil {
+				return fmt.Errorf("failed to write chapter: %v", err)
+			}
+		}
+	}
+
+	// 整本书的封面沿用第一卷
+	firstVolume := novel.Volumes[0]
+	coverPath := filepath.Join(outputPath, fmt.Sprintf("cover.%s", imageExtension(firstVolume.Cover, firstVolume.CoverUrl)))
+	if err := os.WriteFile(coverPath, firstVolume.Cover, 0644); err != nil {
+		return fmt.Errorf("failed to write cover: %v", err)
+	}
+	if err := renderToFile(filepath.Join(outputPath, "OEBPS/Text/cover.xhtml"),
+		template.CoverXHTML(firstVolume.Lang(), fmt.Sprintf("../../%s", filepath.Base(coverPath)))); err != nil {
+		return fmt.Errorf("failed to render cover XHTML: %v", err)
+	}
+
+	// 两级目录
+	var contents strings.Builder
+	contents.WriteString(`<nav epub:type="toc" id="toc">`)
+	contents.WriteString(`<ol>`)
+	for vi, volume := range novel.Volumes {
+		contents.WriteString(fmt.Sprintf(`<li><a href="volume-%03v.xhtml">%s</a>`, vi, html.EscapeString(volume.Title)))
+		contents.WriteString(`<ol>`)
+		for ci, chapter := range volume.Chapters {
+			if chapter == nil {
+				continue
+			}
+			contents.WriteString(fmt.Sprintf(`<li><a href="volume-%03v-chapter-%03v.xhtml">%s</a></li>`, vi, ci, html.EscapeString(chapter.Title)))
+		}
+		contents.WriteString(`</ol>`)
+		contents.WriteString(`</li>`)
+	}
+	contents.WriteString(`</ol>`)
+	contents.WriteString(`</nav>`)
+	if err := renderToFile(filepath.Join(outputPath, "OEBPS/Text/contents.xhtml"),
+		template.ContentXHTML(firstVolume.Lang(), "目录", contents.String())); err != nil {
+		return fmt.Errorf("failed to render contents XHTML: %v", err)
+	}
+
+	containerPath := filepath.Join(outputPath, "META-INF/container.xml")
+	if err := os.MkdirAll(filepath.Dir(containerPath), 0755); err != nil {
+		return fmt.Errorf("failed to create container directory: %v", err)
+	}
+	if err := renderToFile(containerPath, template.ContainerXML()); err != nil {
+		return fmt.Errorf("failed to render container: %v", err)
+	}
+
+	u := utils.NovelUUID(novel.Id)
+	if err := createNovelContentOPF(outputPath, u, novel, extraFiles, opts); err != nil {
+		return fmt.Errorf("failed to create content OPF: %v", err)
+	}
+	if opts.EPUB2Compat {
+		navPoints := make([]model.NavPoint, 0, len(novel.Volumes))
+		for vi, volume := range novel.Volumes {
+			point := model.NavPoint{
+				Label:   volume.Title,
+				Content: model.NavContent{Src: fmt.Sprintf("OEBPS/Text/volume-%03v.xhtml", vi)},
+			}
+			for ci, chapter := range volume.Chapters {
+				if chapter == nil {
+					continue
+				}
+				point.Children = append(point.Children, model.NavPoint{
+					Label:   chapter.Title,
+					Content: model.NavContent{Src: fmt.Sprintf("OEBPS/Text/volume-%03v-chapter-%03v.xhtml", vi, ci)},
+				})
+			}
+			navPoints = append(navPoints, point)
+		}
+		if err := writeNCX(outputPath, u, novel.Title, navPoints); err != nil {
+			return err
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(outputPath, "style.css"), []byte(opts.styleSheet(styleCSS)), 0644); err != nil {
+		return fmt.Errorf("failed to write CSS: %v", err)
+	}
+	for _, ef := range extraFiles {
+		extraFilePath := filepath.Join(outputPath, ef.Path)
+		if err := os.MkdirAll(filepath.Dir(extraFilePath), 0755); err != nil {
+			return fmt.Errorf("failed to create extra file directory: %v", err)
+		}
+		if err := os.WriteFile(extraFilePath, ef.Data, 0644); err != nil {
+			return fmt.Errorf("failed to write extra file: %v", err)
+		}
+	}
+
+	if err := PackEpub(outputPath); err != nil {
+		return fmt.Errorf("failed to pack epub: %v", err)
+	}
+	return nil
+}
+
+func createNovelContentOPF(outputPath string, uuid string, novel *model.Novel, extraFiles []model.ExtraFile, opts Options) error {
+	authors := novel.Authors
+	if len(authors) == 0 {
+		for _, volume := range novel.Volumes {
+			authors = append(authors, volume.Authors...)
+		}
+	}
+	creators := make([]model.DCCreator, 0, len(authors))
+	for _, author := range utils.Unique(authors) {
+		creators = append(creators, model.DCCreator{Value: author})
+	}
+	dc := &model.DublinCoreMetadata{
+		Titles: []model.DCTitle{{Value: novel.Title}},
+		Identifiers: []model.DCIdentifier{{
+			Value: fmt.Sprintf("urn:uuid:%s", uuid),
+			ID:    "book-id",
+		}},
+		Languages:    []model.DCLanguage{{Value: novel.Volumes[0].Lang()}},
+		Descriptions: []model.DCDescription{{Value: novel.Description}},
+		Creators:     creators,
+		Metas: []model.DublinCoreMeta{
+			{Name: "cover", Content: "cover"},
+			{Property: "dcterms:modified", Value: utils.BuildTime().Format("2006-01-02T15:04:05Z")},
+		},
+	}
+
+	coverExt := imageExtension(novel.Volumes[0].Cover, novel.Volumes[0].CoverUrl)
+	manifest := &model.Manifest{Items: make([]model.ManifestItem, 0, 256)}
+	manifest.Items = append(manifest.Items,
+		model.ManifestItem{ID: "cover.xhtml", Link: "OEBPS/Text/cover.xhtml", Media: "application/xhtml+xml"},
+		model.ManifestItem{ID: "contents.xhtml", Link: "OEBPS/Text/contents.xhtml", Media: "application/xhtml+xml", Properties: "nav"},
+		model.ManifestItem{ID: "cover", Link: fmt.Sprintf("cover.%s", coverExt), Media: imageMediaType(coverExt), Properties: "cover-image"},
+	)
+	for vi, volume := range novel.Volumes {
+		volumeCoverExt := imageExtension(volume.Cover, volume.CoverUrl)
+		manifest.Items = append(manifest.Items,
+			model.ManifestItem{ID: fmt.Sprintf("volume-%03v.xhtml", vi), Link: fmt.Sprintf("OEBPS/Text/volume-%03v.xhtml", vi), Media: "application/xhtml+xml"},
+			model.ManifestItem{ID: fmt.Sprintf("volume-%03v-cover", vi), Link: fmt.Sprintf("OEBPS/Images/volume-%03v-cover.%s", vi, volumeCoverExt), Media: imageMediaType(volumeCoverExt)},
+		)
+		for ci, chapter := range volume.Chapters {
+			if chapter == nil {
+				continue
+			}
+			name := fmt.Sprintf("volume-%03v-chapter-%03v", vi, ci)
+			manifest.Items = append(manifest.Items, model.ManifestItem{
+				ID:    fmt.Sprintf("%s.xhtml", name),
+				Link:  fmt.Sprintf("OEBPS/Text/%s.xhtml", name),
+				Media: "application/xhtml+xml",
+			})
+			for _, filename := range sortedImageNames(chapter.Content.Images) {
+				data := chapter.Content.Images[filename]
+				filename = imageFileName(filepath.Base(filename), data)
+				manifest.Items = append(manifest.Items, model.ManifestItem{
+					ID:    fmt.Sprintf("%s-%s", name, filename),
+					Link:  fmt.Sprintf("OEBPS/Images/%s/%s", name, filename),
+					Media: imageMediaType(imageExtension(data, filename)),
+				})
+			}
+		}
+	}
+	manifest.Items = append(manifest.Items, model.ManifestItem{
+		ID: "style", Link: "style.css", Media: "text/css",
+	})
+	for _, f := range extraFiles {
+		manifest.Items = append(manifest.Items, f.ManifestItem)
+	}
+
+	// 清单中 XHTML 的顺序即阅读顺序：封面、目录，然后每卷的封面页与章节
+	spine := &model.Spine{Items: make([]model.SpineItem, 0, len(manifest.Items))}
+	for _, item := range manifest.Items {
+		if filepath.Ext(item.Link) == ".xhtml" {
+			spine.Items = append(spine.Items, model.SpineItem{IDref: item.ID})
+		}
+	}
+	opts.applyLayout(spine, dc)
+	var guide *model.Guide
+	if opts.EPUB2Compat {
+		guide = addCompat(manifest, spine, "OEBPS/Text/volume-000.xhtml")
+	}
+
+	return renderToFile(filepath.Join(outputPath, "content.opf"), template.ContentOPF("book-id", dc, manifest, spine, guide))
+}
@@ -0,0 +1,443 @@
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+// 校验规则名，对应 Issue.Rule
+const (
+	RuleMimetype   = "mimetype"
+	RuleContainer  = "container"
+	RulePackage    = "package"
+	RuleMissing    = "missing-resource"
+	RuleMediaType  = "media-type"
+	RuleDuplicate  = "duplicate-id"
+	RuleSpine      = "spine"
+	RuleWellFormed = "well-formed"
+	RuleReference  = "reference"
+	RuleNav        = "nav"
+)
+
+const (
+	opsNamespace   = "http://www.idpf.org/2007/ops"
+	xlinkNamespace = "http://www.w3.org/1999/xlink"
+)
+
+// Issue 是一条校验结果，Path 为出问题的 zip 条目，与整个文件有关时为空
+type Issue struct {
+	Rule    string `json:"rule"`
+	Path    string `json:"path,omitempty"`
+	Message string `json:"message"`
+}
+
+func (i Issue) Error() string {
+	if i.Path == "" {
+		return fmt.Sprintf("%v: %v", i.Rule, i.Message)
+	}
+	return fmt.Sprintf("%v: %v: %v", i.Rule, i.Path, i.Message)
+}
+
+// mediaTypes 为各扩展名可以使用的 media-type，第一个为推荐值
+var mediaTypes = map[string][]string{
+	".xhtml": {"application/xhtml+xml"},
+	".html":  {"application/xhtml+xml"},
+	".css":   {"text/css"},
+	".ncx":   {"application/x-dtbncx+xml"},
+	".jpg":   {"image/jpeg"},
+	".jpeg":  {"image/jpeg"},
+	".png":   {"image/png"},
+	".gif":   {"image/gif"},
+	".webp":  {"image/webp"},
+	".svg":   {"image/svg+xml"},
+	".ttf":   {"font/ttf", "application/font-sfnt", "application/x-font-ttf", "application/x-font-truetype"},
+	".otf":   {"font/otf", "application/font-sfnt", "application/vnd.ms-opentype", "application/x-font-opentype"},
+	".woff":  {"font/woff", "application/font-woff"},
+	".woff2": {"font/woff2"},
+	".js":    {"application/javascript", "text/javascript", "application/ecmascript"},
+}
+
+type opfPackage struct {
+	Version          string `xml:"version,attr"`
+	UniqueIdentifier string `xml:"unique-identifier,attr"`
+	Metadata         struct {
+		Identifiers []struct {
+			ID    string `xml:"id,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"http://purl.org/dc/elements/1.1/ identifier"`
+		Titles    []string `xml:"http://purl.org/dc/elements/1.1/ title"`
+		Languages []string `xml:"http://purl.org/dc/elements/1.1/ language"`
+		Metas     []struct {
+			Property string `xml:"property,attr"`
+		} `xml:"meta"`
+	} `xml:"metadata"`
+	Items []struct {
+		ID         string `xml:"id,attr"`
+		Href       string `xml:"href,attr"`
+		MediaType  string `xml:"media-type,attr"`
+		Properties string `xml:"properties,attr"`
+	} `xml:"manifest>item"`
+	Spine struct {
+		Toc      string `xml:"toc,attr"`
+		ItemRefs []struct {
+			IDref string `xml:"idref,attr"`
+		} `xml:"itemref"`
+	} `xml:"spine"`
+}
+
+// validator 收集一个 EPUB 的校验结果
+type validator struct {
+	files  map[string]*zip.File
+	issues []Issue
+}
+
+func (v *validator) add(rule string, filePath string, format string, args ...any) {
+	v.issues = append(v.issues, Issue{Rule: rule, Path: filePath, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) read(name string) ([]byte, error) {
+	f, ok := v.files[name]
+	if !ok {
+		return nil, fmt.Errorf("file not found")
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// Validate 按 epubcheck 的常用规则检查 EPUB：mimetype 条目、container.xml、OPF 元数据、
+// 清单与 spine 引用、media-type、ID 唯一性、XHTML 格式与资源引用以及 EPUB 3 的导航文档。
+// 只有文件无法打开时返回 error，规则问题以 Issue 列表返回
+func Validate(epubPath string) ([]Issue, error) {
+	r, err := zip.OpenReader(epubPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open epub: %v", err)
+	}
+	defer r.Close()
+
+	v := &validator{files: make(map[string]*zip.File, len(r.File))}
+	for _, f := range r.File {
+		v.files[f.Name] = f
+	}
+	v.checkMimetype(r.File)
+	opfPath := v.checkContainer()
+	if opfPath != "" {
+		v.checkPackage(opfPath)
+	}
+	return v.issues, nil
+}
+
+func (v *validator) checkMimetype(files []*zip.File) {
+	if len(files) == 0 || files[0].Name != "mimetype" {
+		v.add(RuleMimetype, "mimetype", "must be the first entry in the archive")
+	}
+	f, ok := v.files["mimetype"]
+	if !ok {
+		return
+	}
+	if f.Method != zip.Store {
+		v.add(RuleMimetype, "mimetype", "must be stored uncompressed")
+	}
+	if data, err := v.read("mimetype"); err != nil || string(data) != "application/epub+zip" {
+		v.add(RuleMimetype, "mimetype", "content must be exactly application/epub+zip")
+	}
+}
+
+// checkContainer 返回 container.xml 指向的 OPF 路径
+func (v *validator) checkContainer() string {
+	const containerPath = "META-INF/container.xml"
+	data, err := v.read(containerPath)
+	if err != nil {
+		v.add(RuleContainer, containerPath, "%v", err)
+		return ""
+	}
+	if err := checkWellFormed(data); err != nil {
+		v.add(RuleWellFormed, containerPath, "%v", err)
+		return ""
+	}
+	container := struct {
+		Rootfiles []struct {
+			FullPath  string `xml:"full-path,attr"`
+			MediaType string `xml:"media-type,attr"`
+		} `xml:"rootfiles>rootfile"`
+	}{}
+	if err := xml.Unmarshal(data, &container); err != nil || len(container.Rootfiles) == 0 {
+		v.add(RuleContainer, containerPath, "no rootfile declared")
+		return ""
+	}
+	rootfile := container.Rootfiles[0]
+	if rootfile.MediaType != "application/oebps-package+xml" {
+		v.add(RuleMediaType, containerPath, "rootfile media-type is %q, expected application/oebps-package+xml", rootfile.MediaType)
+	}
+	if _, ok := v.files[rootfile.FullPath]; !ok {
+		v.add(RuleMissing, containerPath, "rootfile %v does not exist", rootfile.FullPath)
+		return ""
+	}
+	return rootfile.FullPath
+}
+
+func (v *validator) checkPackage(opfPath string) {
+	data, err := v.read(opfPath)
+	if err != nil {
+		v.add(RulePackage, opfPath, "%v", err)
+		return
+	}
+	if err := checkWellFormed(data); err != nil {
+		v.add(RuleWellFormed, opfPath, "%v", err)
+		return
+	}
+	opf := &opfPackage{}
+	if err := xml.Unmarshal(data, opf); err != nil {
+		v.add(RulePackage, opfPath, "failed to parse package document: %v", err)
+		return
+	}
+	epub3 := strings.HasPrefix(opf.Version, "3")
+
+	// 元数据
+	hasUniqueIdentifier := false
+	for _, identifier := range opf.Metadata.Identifiers {
+		if identifier.ID == opf.UniqueIdentifier && strings.TrimSpace(identifier.Value) != "" {
+			hasUniqueIdentifier = true
+		}
+	}
+	if !hasUniqueIdentifier {
+		v.add(RulePackage, opfPath, "unique-identifier %q does not match a non-empty dc:identifier", opf.UniqueIdentifier)
+	}
+	if len(opf.Metadata.Titles) == 0 {
+		v.add(RulePackage, opfPath, "missing dc:title")
+	}
+	if len(opf.Metadata.Languages) == 0 {
+		v.add(RulePackage, opfPath, "missing dc:language")
+	}
+	if epub3 {
+		modified := false
+		for _, meta := range opf.Metadata.Metas {
+			if meta.Property == "dcterms:modified" {
+				modified = true
+			}
+		}
+		if !modified {
+			v.add(RulePackage, opfPath, "missing dcterms:modified meta")
+		}
+	}
+
+	// 清单：ID 唯一、文件存在、media-type 正确
+	opfDir := path.Dir(opfPath)
+	items := make(map[string]string, len(opf.Items))
+	mediaTypeOf := make(map[string]string, len(opf.Items))
+	manifested := make(map[string]bool, len(opf.Items))
+	var navPaths []string
+	for _, item := range opf.Items {
+		if _, ok := items[item.ID]; ok {
+			v.add(RuleDuplicate, opfPath, "manifest id %q is used more than once", item.ID)
+		}
+		target, ok := resolve(opfDir, item.Href)
+		if !ok {
+			v.add(RuleReference, opfPath, "manifest item %q has an invalid href %q", item.ID, item.Href)
+			continue
+		}
+		items[item.ID] = target
+		mediaTypeOf[target] = item.MediaType
+		manifested[target] = true
+		if _, ok := v.files[target]; !ok {
+			v.add(RuleMissing, opfPath, "manifest item %q refers to missing file %v", item.ID, target)
+			continue
+		}
+		v.checkMediaType(target, item.MediaType)
+		if strings.Contains(" "+item.Properties+" ", " nav ") {
+			navPaths = append(navPaths, target)
+		}
+	}
+
+	// spine
+	if opf.Spine.Toc != "" {
+		if target, ok := items[opf.Spine.Toc]; !ok {
+			v.add(RuleSpine, opfPath, "spine toc %q is not a manifest id", opf.Spine.Toc)
+		} else if mediaTypeOf[target] != "application/x-dtbncx+xml" {
+			v.add(RuleSpine, opfPath, "spine toc %q is not an NCX document", opf.Spine.Toc)
+		}
+	}
+	if len(opf.Spine.ItemRefs) == 0 {
+		v.add(RuleSpine, opfPath, "spine is empty")
+	}
+	for _, itemRef := range opf.Spine.ItemRefs {
+		target, ok := items[itemRef.IDref]
+		if !ok {
+			v.add(RuleSpine, opfPath, "itemref %q is not a manifest id", itemRef.IDref)
+			continue
+		}
+		if mediaType := mediaTypeOf[target]; mediaType != "application/xhtml+xml" && mediaType != "image/svg+xml" {
+			v.add(RuleSpine, opfPath, "itemref %q has media-type %q, expected a content document", itemRef.IDref, mediaType)
+		}
+	}
+
+	// 内容文档
+	targets := make([]string, 0, len(mediaTypeOf))
+	for target := range mediaTypeOf {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+	for _, target := range targets {
+		mediaType := mediaTypeOf[target]
+		if _, ok := v.files[target]; !ok {
+			continue
+		}
+		switch mediaType {
+		case "application/xhtml+xml", "image/svg+xml":
+			v.checkDocument(target, manifested)
+		case "application/x-dtbncx+xml":
+			if data, err := v.read(target); err == nil {
+				if err := checkWellFormed(data); err != nil {
+					v.add(RuleWellFormed, target, "%v", err)
+				}
+			}
+		}
+	}
+
+	if epub3 {
+		switch len(navPaths) {
+		case 0:
+			v.add(RuleNav, opfPath, "no manifest item has the nav property")
+		case 1:
+			v.checkNav(navPaths[0])
+		default:
+			v.add(RuleNav, opfPath, "more than one manifest item has the nav property")
+		}
+	}
+}
+
+// checkMediaType 比较声明的 media-type 与扩展名，图片还要与文件内容一致
+func (v *validator) checkMediaType(target string, mediaType string) {
+	if allowed, ok := mediaTypes[strings.ToLower(path.Ext(target))]; ok {
+		valid := false
+		for _, a := range allowed {
+			if a == mediaType {
+				valid = true
+			}
+		}
+		if !valid {
+			v.add(RuleMediaType, target, "declared as %q, expected %q", mediaType, allowed[0])
+			return
+		}
+	}
+	if !strings.HasPrefix(mediaType, "image/") || mediaType == "image/svg+xml" {
+		return
+	}
+	data, err := v.read(target)
+	if err != nil {
+		return
+	}
+	if sniffed := http.DetectContentType(data); strings.HasPrefix(sniffed, "image/") && sniffed != mediaType {
+		v.add(RuleMediaType, target, "declared as %q but the content is %q", mediaType, sniffed)
+	}
+}
+
+// checkDocument 检查 XHTML 或 SVG 是否为格式良好的 XML、ID 是否唯一，以及引用的本地资源是否在清单中
+func (v *validator) checkDocument(target string, manifested map[string]bool) {
+	data, err := v.read(target)
+	if err != nil {
+		return
+	}
+	dir := path.Dir(target)
+	ids := make(map[string]bool)
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			v.add(RuleWellFormed, target, "%v", err)
+			return
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		for _, attr := range start.Attr {
+			switch {
+			case attr.Name.Local == "id" && attr.Name.Space == "":
+				if ids[attr.Value] {
+					v.add(RuleDuplicate, target, "id %q is used more than once", attr.Value)
+				}
+				ids[attr.Value] = true
+			case attr.Name.Local == "src" || attr.Name.Local == "href" && (attr.Name.Space == "" || attr.Name.Space == xlinkNamespace):
+				ref := attr.Value
+				if strings.HasPrefix(ref, "#") || strings.Contains(ref, ":") {
+					// 页内锚点与外部链接
+					continue
+				}
+				resolved, ok := resolve(dir, ref)
+				if !ok {
+					v.add(RuleReference, target, "invalid reference %q", ref)
+				} else if _, exists := v.files[resolved]; !exists {
+					v.add(RuleReference, target, "%q refers to missing file %v", ref, resolved)
+				} else if !manifested[resolved] {
+					v.add(RuleReference, target, "%q refers to %v which is not in the manifest", ref, resolved)
+				}
+			}
+		}
+	}
+}
+
+// checkNav 要求导航文档中有 epub:type 为 toc 的 <nav>
+func (v *validator) checkNav(target string) {
+	data, err := v.read(target)
+	if err != nil {
+		return
+	}
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "nav" {
+			continue
+		}
+		for _, attr := range start.Attr {
+			if attr.Name.Space == opsNamespace && attr.Name.Local == "type" && strings.Contains(" "+attr.Value+" ", " toc ") {
+				return
+			}
+		}
+	}
+	v.add(RuleNav, target, "navigation document has no <nav epub:type=\"toc\">")
+}
+
+func checkWellFormed(data []byte) error {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		_, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// resolve 将相对 dir 的 href 解析为 zip 条目名，去掉片段并解码百分号转义
+func resolve(dir string, href string) (string, bool) {
+	u, err := url.Parse(href)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" {
+		return "", false
+	}
+	resolved := path.Clean(path.Join(dir, u.Path))
+	if resolved == ".." || strings.HasPrefix(resolved, "../") {
+		return "", false
+	}
+	return resolved, true
+}
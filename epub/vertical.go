@@ -0,0 +1,113 @@
+package epub
+
+import (
+	"bilinovel-downloader/model"
+	_ "embed"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+//go:embed vertical.css
+var verticalCSS string
+
+// 连续的半角数字或问号、感叹号
+var tcyRegexp = regexp.MustCompile(`[0-9]+|[!?！？]+`)
+
+// styleSheet 返回写入 style.css 的样式，竖排时在原样式之后追加竖排规则
+func (o Options) styleSheet(styleCSS string) string {
+	if !o.Vertical {
+		return styleCSS
+	}
+	return styleCSS + "\n" + verticalCSS
+}
+
+// chapterHTML 返回写入章节 XHTML 的正文，竖排时为短数字与 !? 加上纵中横
+func (o Options) chapterHTML(htmlContent string) (string, error) {
+	if !o.Vertical {
+		return htmlContent, nil
+	}
+	return tateChuYoko(htmlContent)
+}
+
+// applyLayout 按排版方向设置 spine 的翻页方向，并为 Kindle 标注书写方向
+func (o Options) applyLayout(spine *model.Spine, dc *model.DublinCoreMetadata) {
+	if !o.Vertical {
+		return
+	}
+	spine.PageProgressionDirection = "rtl"
+	dc.Metas = append(dc.Metas, model.DublinCoreMeta{Name: "primary-writing-mode", Content: "vertical-rl"})
+}
+
+// tateChuYoko 将不超过两位的半角数字、两个连续的 !?，以及单个半角 ! ? 包进 <span class="tcy">
+func tateChuYoko(htmlContent string) (string, error) {
+	nodes, err := html.ParseFragment(strings.NewReader(htmlContent), &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body})
+	if err != nil {
+		return "", fmt.Errorf("failed to parse chapter html: %v", err)
+	}
+	var b strings.Builder
+	for _, node := range nodes {
+		wrapTcy(node)
+		if err := html.Render(&b, node); err != nil {
+			return "", fmt.Errorf("failed to render chapter html: %v", err)
+		}
+	}
+	return b.String(), nil
+}
+
+func wrapTcy(node *html.Node) {
+	for child := node.FirstChild; child != nil; {
+		next := child.NextSibling
+		switch child.Type {
+		case html.TextNode:
+			splitTcy(child)
+		case html.ElementNode:
+			// 注音本身已横向排在旁边，不再处理
+			if child.DataAtom != atom.Rt && child.DataAtom != atom.Script && child.DataAtom != atom.Style {
+				wrapTcy(child)
+			}
+		}
+		child = next
+	}
+}
+
+func splitTcy(text *html.Node) {
+	matches := tcyRegexp.FindAllStringIndex(text.Data, -1)
+	if len(matches) == 0 {
+		return
+	}
+	parent := text.Parent
+	last := 0
+	for _, m := range matches {
+		run := text.Data[m[0]:m[1]]
+		if !isTcy(run) {
+			continue
+		}
+		if m[0] > last {
+			parent.InsertBefore(&html.Node{Type: html.TextNode, Data: text.Data[last:m[0]]}, text)
+		}
+		span := &html.Node{Type: html.ElementNode, Data: "span", DataAtom: atom.Span, Attr: []html.Attribute{{Key: "class", Val: "tcy"}}}
+		span.AppendChild(&html.Node{Type: html.TextNode, Data: run})
+		parent.InsertBefore(span, text)
+		last = m[1]
+	}
+	if last == 0 {
+		return
+	}
+	if last < len(text.Data) {
+		parent.InsertBefore(&html.Node{Type: html.TextNode, Data: text.Data[last:]}, text)
+	}
+	parent.RemoveChild(text)
+}
+
+// isTcy 判断一段数字或标点是否适合纵中横：更长的数字保持横躺，全角的单个标点本身已直立
+func isTcy(run string) bool {
+	n := len([]rune(run))
+	if run[0] >= '0' && run[0] <= '9' {
+		return n <= 2
+	}
+	return n == 2 || run == "!" || run == "?"
+}
@@ -2,6 +2,7 @@ package epub
 
 import (
 	"archive/zip"
+	"bilinovel-downloader/imageopt"
 	"bilinovel-downloader/model"
 	"bilinovel-downloader/template"
 	"bilinovel-downloader/utils"
@@ -15,12 +16,11 @@ import (
 	"sort"
 	"strconv"
 	"strings"
-	"time"
 
-	"github.com/google/uuid"
+	"github.com/a-h/templ"
 )
 
-func PackVolumeToEpub(volume *model.Volume, outputPath string, styleCSS string, extraFiles []model.ExtraFile) error {
+func PackVolumeToEpub(volume *model.Volume, outputPath string, styleCSS string, extraFiles []model.ExtraFile, opts Options) error {
 	// 以“卷名”建立工作目录
 	outputPath = filepath.Join(outputPath, utils.CleanDirName(volume.Title))
 	if st, err := os.Stat(outputPath); err != nil {
@@ -53,10 +53,10 @@ func PackVolumeToEpub(volume *model.Volume, outputPath string, styleCSS string,
 		}
 
 		// 写图片到该章目录
-		imageNames := make([]string, 0, len(chapter.Content.Images))
-		for imgName, imgData := range chapter.Content.Images {
-			imageNames = append(imageNames, imgName)
-			imgPath := filepath.Join(outputPath, fmt.Sprintf("OEBPS/Images/chapter-%03v/%s", i, imgName))
+		imageNames := sortedImageNames(chapter.Content.Images)
+		for _, imgName := range imageNames {
+			imgData := chapter.Content.Images[imgName]
+			imgPath := filepath.Join(outputPath, fmt.Sprintf("OEBPS/Images/chapter-%03v/%s", i, imageFileName(imgName, imgData)))
 			if err := os.MkdirAll(filepath.Dir(imgPath), 0755); err != nil {
 				return fmt.Errorf("failed to create image directory: %v", err)
 			}
@@ -79,19 +79,19 @@ func PackVolumeToEpub(volume *model.Volume, outputPath string, styleCSS string,
 		// 修正 HTML 里图片相对路径
 		text := chapter.Content.Html
 		for _, imgName := range imageNames {
-			text = strings.ReplaceAll(text, imgName, fmt.Sprintf("../Images/chapter-%03v/%s", i, imgName))
+			text = strings.ReplaceAll(text, imgName, fmt.Sprintf("../Images/chapter-%03v/%s", i, imageFileName(imgName, chapter.Content.Images[imgName])))
+		}
+		text, err = opts.chapterHTML(text)
+		if err != nil {
+			return err
 		}
-		if err := template.ContentXHTML(chapter.Title, text).Render(context.Background(), file); err != nil {
+		if err := template.ContentXHTML(volume.Lang(), chapter.Title, text).Render(context.Background(), file); err != nil {
 			return fmt.Errorf("failed to write chapter: %v", err)
 		}
 	}
 
 	// 将 Cover 写入（若上游没提供，Cover/Url 可能为空，此时仍会生成 cover.<ext>；你可按需加判空）
-	coverExt := strings.TrimPrefix(filepath.Ext(volume.CoverUrl), ".")
-	if coverExt == "" {
-		coverExt = "jpg"
-	}
-	coverPath := filepath.Join(outputPath, fmt.Sprintf("cover.%s", strings.ReplaceAll(coverExt, "jpeg", "jpg")))
+	coverPath := filepath.Join(outputPath, fmt.Sprintf("cover.%s", imageExtension(volume.Cover, volume.CoverUrl)))
 	if err := os.WriteFile(coverPath, volume.Cover, 0644); err != nil {
 		return fmt.Errorf("failed to write cover: %v", err)
 	}
@@ -103,7 +103,7 @@ func PackVolumeToEpub(volume *model.Volume, outputPath string, styleCSS string,
 		return fmt.Errorf("failed to create cover XHTML file: %v", err)
 	}
 	defer file.Close()
-	if err := template.CoverXHTML(fmt.Sprintf("../../%s", filepath.Base(coverPath))).Render(context.Background(), file); err != nil {
+	if err := template.CoverXHTML(volume.Lang(), fmt.Sprintf("../../%s", filepath.Base(coverPath))).Render(context.Background(), file); err != nil {
 		return fmt.Errorf("failed to render cover XHTML: %v", err)
 	}
 
@@ -125,7 +125,7 @@ func PackVolumeToEpub(volume *model.Volume, outputPath string, styleCSS string,
 	}
 	contents.WriteString(`</ol>`)
 	contents.WriteString(`</nav>`)
-	if err := template.ContentXHTML("目录", contents.String()).Render(context.Background(), file); err != nil {
+	if err := template.ContentXHTML(volume.Lang(), "目录", contents.String()).Render(context.Background(), file); err != nil {
 		return fmt.Errorf("failed to render contents XHTML: %v", err)
 	}
 
@@ -144,20 +144,38 @@ func PackVolumeToEpub(volume *model.Volume, outputPath string, styleCSS string,
 	}
 
 	// content.opf
-	u := uuid.New()
-	if err := CreateContentOPF(outputPath, u.String(), volume, extraFiles); err != nil {
+	u := utils.VolumeUUID(volume.NovelId, volume.Id)
+	if err := CreateContentOPF(outputPath, u, volume, extraFiles, opts); err != nil {
 		return fmt.Errorf("failed to create content OPF: %v", err)
 	}
+	if opts.EPUB2Compat {
+		navPoints := make([]model.NavPoint, 0, len(volume.Chapters))
+		for i, chapter := range volume.Chapters {
+			if chapter == nil {
+				continue
+			}
+			navPoints = append(navPoints, model.NavPoint{
+				Label:   chapter.Title,
+				Content: model.NavContent{Src: fmt.Sprintf("OEBPS/Text/chapter-%03v.xhtml", i)},
+			})
+		}
+		if err := writeNCX(outputPath, u, volume.Title, navPoints); err != nil {
+			return err
+		}
+	}
 
 	// 写入 CSS
 	cssPath := filepath.Join(outputPath, "style.css")
-	if err := os.WriteFile(cssPath, []byte(styleCSS), 0644); err != nil {
+	if err := os.WriteFile(cssPath, []byte(opts.styleSheet(styleCSS)), 0644); err != nil {
 		return fmt.Errorf("failed to write CSS: %v", err)
 	}
 
 	// 写入 extraFiles
 	for _, ef := range extraFiles {
 		extraFilePath := filepath.Join(outputPath, ef.Path)
+		if err := os.MkdirAll(filepath.Dir(extraFilePath), 0755); err != nil {
+			return fmt.Errorf("failed to create extra file directory: %v", err)
+		}
 		if err := os.WriteFile(extraFilePath, ef.Data, 0644); err != nil {
 			return fmt.Errorf("failed to write extra file: %v", err)
 		}
@@ -173,13 +191,6 @@ func PackVolumeToEpub(volume *model.Volume, outputPath string, styleCSS string,
 // 封面选择：先插图章(HTML顺序第一张)，否则第一个有图的章节(HTML顺序第一张)，兜底为该章Images文件名单序第一张
 func chooseCover(volume *model.Volume) {
 	reImg := regexp.MustCompile(`(?is)<img[^>]+src=['"]([^'"]+)['"][^>]*>`)
-	isIllustration := func(title string) bool {
-		return strings.Contains(title, "插图") ||
-			strings.Contains(title, "插畫") ||
-			strings.Contains(title, "插画") ||
-			strings.Contains(title, "口絵") ||
-			strings.Contains(title, "口绘")
-	}
 
 	// 在一章里按 HTML 顺序匹配第一张
 	pickFromHTML := func(ch *model.Chapter) bool {
@@ -196,8 +207,8 @@ func chooseCover(volume *model.Volume) {
 			}
 			src := mm[1]
 			base := filepath.Base(src)
-			for k, data := range ch.Content.Images {
-				if filepath.Base(k) == base && len(data) > 0 {
+			for _, k := range sortedImageNames(ch.Content.Images) {
+				if data := ch.Content.Images[k]; filepath.Base(k) == base && len(data) > 0 {
 					volume.Cover = data
 					volume.CoverUrl = src // 用于推断扩展名
 					return true
@@ -212,11 +223,7 @@ func chooseCover(volume *model.Volume) {
 		if ch == nil || len(ch.Content.Images) == 0 {
 			return false
 		}
-		keys := make([]string, 0, len(ch.Content.Images))
-		for k := range ch.Content.Images {
-			keys = append(keys, k)
-		}
-		sort.Strings(keys)
+		keys := sortedImageNames(ch.Content.Images)
 		if data := ch.Content.Images[keys[0]]; len(data) > 0 {
 			volume.Cover = data
 			volume.CoverUrl = keys[0]
@@ -228,7 +235,7 @@ func chooseCover(volume *model.Volume) {
 	// 1) 插图章节：HTML 顺序第一张 → 对不上则该章文件名第一张
 	for i := range volume.Chapters {
 		ch := volume.Chapters[i]
-		if ch == nil || !isIllustration(ch.Title) {
+		if ch == nil || !utils.IsIllustration(ch.Title) {
 			continue
 		}
 		if pickFromHTML(ch) || pickFromImagesByName(ch) {
@@ -254,7 +261,8 @@ func chooseCover(volume *model.Volume) {
 	// 3) 最终仍未选到：不强制设置，保留 volume.Cover 现状（若上游已有）
 }
 
-func CreateContentOPF(outputPath string, uuid string, volume *model.Volume, extraFiles []model.ExtraFile) error {
+// CreateContentOPF 生成 EPUB 3 的 content.opf，opts.EPUB2Compat 为 true 时同时引用 toc.ncx 并填写 <guide>
+func CreateContentOPF(outputPath string, uuid string, volume *model.Volume, extraFiles []model.ExtraFile, opts Options) error {
 	// Dublin Core
 	creators := make([]model.DCCreator, 0, len(volume.Authors))
 	for _, author := range volume.Authors {
@@ -266,12 +274,12 @@ func CreateContentOPF(outputPath string, uuid string, volume *model.Volume, extr
 			Value: fmt.Sprintf("urn:uuid:%s", uuid),
 			ID:    "book-id",
 		}},
-		Languages:    []model.DCLanguage{{Value: "zh-CN"}},
+		Languages:    []model.DCLanguage{{Value: volume.Lang()}},
 		Descriptions: []model.DCDescription{{Value: volume.Description}},
 		Creators:     creators,
 		Metas: []model.DublinCoreMeta{
 			{Name: "cover", Content: "cover"},
-			{Property: "dcterms:modified", Value: time.Now().UTC().Format("2006-01-02T15:04:05Z")},
+			{Property: "dcterms:modified", Value: utils.BuildTime().Format("2006-01-02T15:04:05Z")},
 			{Name: "calibre:series", Content: volume.NovelTitle},
 			{Name: "calibre:series_index", Content: strconv.Itoa(volume.SeriesIdx)},
 		},
@@ -284,14 +292,11 @@ func CreateContentOPF(outputPath string, uuid string, volume *model.Volume, extr
 		model.ManifestItem{ID: "contents.xhtml", Link: "OEBPS/Text/contents.xhtml", Media: "application/xhtml+xml", Properties: "nav"},
 	)
 
-	coverExt := strings.TrimPrefix(filepath.Ext(volume.CoverUrl), ".")
-	if coverExt == "" {
-		coverExt = "jpg"
-	}
+	coverExt := imageExtension(volume.Cover, volume.CoverUrl)
 	manifest.Items = append(manifest.Items, model.ManifestItem{
 		ID:         "cover",
-		Link:       fmt.Sprintf("cover.%s", strings.ReplaceAll(coverExt, "jpeg", "jpg")),
-		Media:      fmt.Sprintf("image/%s", strings.ReplaceAll(coverExt, "jpg", "jpeg")),
+		Link:       fmt.Sprintf("cover.%s", coverExt),
+		Media:      imageMediaType(coverExt),
 		Properties: "cover-image",
 	})
 
@@ -304,11 +309,13 @@ func CreateContentOPF(outputPath string, uuid string, volume *model.Volume, extr
 			Link:  fmt.Sprintf("OEBPS/Text/chapter-%03v.xhtml", i),
 			Media: "application/xhtml+xml",
 		})
-		for filename := range chapter.Content.Images {
+		for _, filename := range sortedImageNames(chapter.Content.Images) {
+			data := chapter.Content.Images[filename]
+			filename = imageFileName(filepath.Base(filename), data)
 			item := model.ManifestItem{
-				ID:    fmt.Sprintf("chapter-%03v-%s", i, filepath.Base(filename)),
-				Link:  fmt.Sprintf("OEBPS/Images/chapter-%03v/%s", i, filepath.Base(filename)),
-				Media: fmt.Sprintf("image/%s", strings.ReplaceAll(strings.TrimPrefix(filepath.Ext(filename), "."), "jpg", "jpeg")),
+				ID:    fmt.Sprintf("chapter-%03v-%s", i, filename),
+				Link:  fmt.Sprintf("OEBPS/Images/chapter-%03v/%s", i, filename),
+				Media: imageMediaType(imageExtension(data, filename)),
 			}
 			manifest.Items = append(manifest.Items, item)
 		}
@@ -323,11 +330,20 @@ func CreateContentOPF(outputPath string, uuid string, volume *model.Volume, extr
 
 	// Spine
 	spine := &model.Spine{Items: make([]model.SpineItem, 0, len(manifest.Items))}
+	firstText := ""
 	for _, item := range manifest.Items {
 		if filepath.Ext(item.Link) == ".xhtml" {
 			spine.Items = append(spine.Items, model.SpineItem{IDref: item.ID})
+			if firstText == "" && strings.HasPrefix(item.ID, "chapter-") {
+				firstText = item.Link
+			}
 		}
 	}
+	opts.applyLayout(spine, dc)
+	var guide *model.Guide
+	if opts.EPUB2Compat {
+		guide = addCompat(manifest, spine, firstText)
+	}
 
 	// 写 content.opf
 	contentOPFPath := filepath.Join(outputPath, "content.opf")
@@ -339,12 +355,50 @@ func CreateContentOPF(outputPath string, uuid string, volume *model.Volume, extr
 		return fmt.Errorf("failed to create content file: %v", err)
 	}
 	defer file.Close()
-	if err := template.ContentOPF("book-id", dc, manifest, spine, nil).Render(context.Background(), file); err != nil {
+	if err := template.ContentOPF("book-id", dc, manifest, spine, guide).Render(context.Background(), file); err != nil {
 		return fmt.Errorf("failed to render content: %v", err)
 	}
 	return nil
 }
 
+// renderToFile 将模板渲染到新建的文件
+func renderToFile(filePath string, component templ.Component) error {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	if err := component.Render(context.Background(), file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// imageExtension 按图片内容判断扩展名，规则见 imageopt.FileExtension
+func imageExtension(data []byte, name string) string {
+	return imageopt.FileExtension(data, name)
+}
+
+// imageFileName 将图片文件名的扩展名改为与内容一致，保证清单中的 media-type 与实际格式相符
+func imageFileName(name string, data []byte) string {
+	return strings.TrimSuffix(name, filepath.Ext(name)) + "." + imageExtension(data, name)
+}
+
+// sortedImageNames 返回按名称排序的章节图片名，保证清单与文件内容不随 map 的遍历顺序变化
+func sortedImageNames(images map[string][]byte) []string {
+	names := make([]string, 0, len(images))
+	for name := range images {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// imageMediaType 由扩展名得到图片的 media-type
+func imageMediaType(ext string) string {
+	return fmt.Sprintf("image/%s", strings.ReplaceAll(ext, "jpg", "jpeg"))
+}
+
 func PackEpub(dirPath string) error {
 	savePath := strings.TrimSuffix(dirPath, string(filepath.Separator)) + ".epub"
 	zipFile, err := os.Create(savePath)
@@ -369,8 +423,9 @@ func PackEpub(dirPath string) error {
 
 func addStringToZip(zipWriter *zip.Writer, relPath, content string, method uint16) error {
 	header := &zip.FileHeader{
-		Name:   relPath,
-		Method: method,
+		Name:     relPath,
+		Method:   method,
+		Modified: utils.BuildTime(),
 	}
 	w, err := zipWriter.CreateHeader(header)
 	if err != nil {
@@ -380,9 +435,11 @@ func addStringToZip(zipWriter *zip.Writer, relPath, content string, method uint1
 	return err
 }
 
-// 将目录下所有文件写入 zip，保证条目名使用正斜杠 `/`
+// 将目录下所有文件按路径排序后写入 zip，保证条目名使用正斜杠 `/`。
+// 不复制文件的修改时间与权限，相同的内容总是得到相同的 zip
 func addDirContentToZip(zipWriter *zip.Writer, dirPath string, method uint16) error {
-	return filepath.Walk(dirPath, func(filePath string, info os.FileInfo, err error) error {
+	var relPaths []string
+	err := filepath.Walk(dirPath, func(filePath string, info os.FileInfo, err error) error {
 		if filepath.Base(filePath) == "volume.json" {
 			return nil
 		}
@@ -398,26 +455,38 @@ func addDirContentToZip(zipWriter *zip.Writer, dirPath string, method uint16) er
 		if err != nil {
 			return err
 		}
-		relPath = filepath.ToSlash(relPath)
+		relPaths = append(relPaths, filepath.ToSlash(relPath))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Strings(relPaths)
 
-		f, err := os.Open(filePath)
-		if err != nil {
+	modified := utils.BuildTime()
+	for _, relPath := range relPaths {
+		if err := addFileToZip(zipWriter, filepath.Join(dirPath, filepath.FromSlash(relPath)), &zip.FileHeader{
+			Name:     relPath,
+			Method:   method,
+			Modified: modified,
+		}); err != nil {
 			return err
 		}
-		defer f.Close()
+	}
+	return nil
+}
 
-		header, err := zip.FileInfoHeader(info)
-		if err != nil {
-			return err
-		}
-		header.Name = relPath
-		header.Method = method
+func addFileToZip(zipWriter *zip.Writer, filePath string, header *zip.FileHeader) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
 
-		w, err := zipWriter.CreateHeader(header)
-		if err != nil {
-			return err
-		}
-		_, err = io.Copy(w, f)
+	w, err := zipWriter.CreateHeader(header)
+	if err != nil {
 		return err
-	})
+	}
+	_, err = io.Copy(w, f)
+	return err
 }
@@ -0,0 +1,136 @@
+package fb2
+
+import (
+	"bilinovel-downloader/htmltext"
+	"strings"
+)
+
+const (
+	blockParagraph = iota
+	blockSubtitle
+	blockEmptyLine
+	blockImage
+)
+
+// span 是一段带样式的文本
+type span struct {
+	text     string
+	emphasis bool
+	strong   bool
+}
+
+// block 对应 FB2 <section> 中的一个元素
+type block struct {
+	kind  int
+	spans []span
+	// image 为 <img src>，即章节 Images 中的文件名
+	image string
+}
+
+// converter 将章节 HTML 拆成 FB2 段落：<br> 与块级元素结束一段，图片单独成块
+type converter struct {
+	blocks   []block
+	spans    []span
+	emphasis int
+	strong   int
+	subtitle bool
+}
+
+func (c *converter) flush(keepEmpty bool) {
+	spans := trimSpans(c.spans)
+	c.spans = nil
+	kind := blockParagraph
+	if c.subtitle {
+		kind = blockSubtitle
+	}
+	if len(spans) > 0 {
+		c.blocks = append(c.blocks, block{kind: kind, spans: spans})
+	} else if keepEmpty && len(c.blocks) > 0 && c.blocks[len(c.blocks)-1].kind != blockEmptyLine {
+		c.blocks = append(c.blocks, block{kind: blockEmptyLine})
+	}
+}
+
+func (c *converter) Text(text string) {
+	if c.emphasis == 0 && c.strong == 0 && len(c.spans) > 0 {
+		last := &c.spans[len(c.spans)-1]
+		if !last.emphasis && !last.strong {
+			last.text += text
+			return
+		}
+	}
+	c.spans = append(c.spans, span{text: text, emphasis: c.emphasis > 0, strong: c.strong > 0})
+}
+
+func (c *converter) LineBreak() {
+	c.flush(true)
+}
+
+func (c *converter) Image(src, alt string) {
+	c.flush(false)
+	if src != "" {
+		c.blocks = append(c.blocks, block{kind: blockImage, image: src})
+	}
+}
+
+func (c *converter) StartBlock(tag string) {
+	c.flush(false)
+	if htmltext.HeadingLevel(tag) > 0 {
+		c.subtitle = true
+	}
+}
+
+func (c *converter) EndBlock(tag string) {
+	c.flush(false)
+	if htmltext.HeadingLevel(tag) > 0 {
+		c.subtitle = false
+	}
+}
+
+func (c *converter) StartStyle(style htmltext.Style) {
+	if style == htmltext.Strong {
+		c.strong++
+	} else {
+		c.emphasis++
+	}
+}
+
+func (c *converter) EndStyle(style htmltext.Style) {
+	if style == htmltext.Strong {
+		c.strong--
+	} else {
+		c.emphasis--
+	}
+}
+
+// trimSpans 去掉首尾空白（FB2 阅读器会自行缩进），整段为空时返回 nil
+func trimSpans(spans []span) []span {
+	for len(spans) > 0 {
+		spans[0].text = strings.TrimLeft(spans[0].text, htmltext.SpaceChars)
+		if spans[0].text != "" {
+			break
+		}
+		spans = spans[1:]
+	}
+	for len(spans) > 0 {
+		last := &spans[len(spans)-1]
+		last.text = strings.TrimRight(last.text, htmltext.SpaceChars)
+		if last.text != "" {
+			break
+		}
+		spans = spans[:len(spans)-1]
+	}
+	return spans
+}
+
+// convertHTML 将章节 HTML 转为 FB2 段落列表，末尾的空行会被去掉
+func convertHTML(htmlContent string) ([]block, error) {
+	c := &converter{}
+	if err := htmltext.Walk(htmlContent, c); err != nil {
+		return nil, err
+	}
+	c.flush(false)
+	for len(c.blocks) > 0 && c.blocks[len(c.blocks)-1].kind == blockEmptyLine {
+		c.blocks = c.blocks[:len(c.blocks)-1]
+	}
+	return c.blocks, nil
+}
@@ -0,0 +1,267 @@
+package fb2
+
+import (
+	"archive/zip"
+	"bilinovel-downloader/model"
+	"bilinovel-downloader/utils"
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	fb2Namespace   = "http://www.gribuser.ru/xml/fictionbook/2.0"
+	xlinkNamespace = "http://www.w3.org/1999/xlink"
+)
+
+// PackVolumeToFB2 将整卷输出为 <卷名>.fb2，zipped 为 true 时输出只包含该文件的 <卷名>.fb2.zip
+func PackVolumeToFB2(volume *model.Volume, outputPath string, zipped bool) error {
+	data, err := Marshal(volume)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(outputPath, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create output directory: %v", err)
+	}
+	name := utils.CleanDirName(volume.Title) + ".fb2"
+	if !zipped {
+		err = os.WriteFile(filepath.Join(outputPath, name), data, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to write fb2 file: %v", err)
+		}
+		return nil
+	}
+
+	var buf bytes.Buffer
+	zipWriter := zip.NewWriter(&buf)
+	writer, err := zipWriter.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to create zip entry: %v", err)
+	}
+	if _, err := writer.Write(data); err != nil {
+		return fmt.Errorf("failed to write zip entry: %v", err)
+	}
+	if err := zipWriter.Close(); err != nil {
+		return fmt.Errorf("failed to close zip writer: %v", err)
+	}
+	err = os.WriteFile(filepath.Join(outputPath, name+".zip"), buf.Bytes(), 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write fb2 zip file: %v", err)
+	}
+	return nil
+}
+
+// Marshal 将卷转换为 FB2 文档：title-info 取自作者、简介与系列，每章一个 <section>，
+// 图片与封面以 base64 写入 <binary>
+func Marshal(volume *model.Volume) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	w := &writer{enc: xml.NewEncoder(&buf)}
+
+	binaries := make(map[string][]byte)
+	coverId := ""
+	if len(volume.Cover) > 0 {
+		coverId = "cover"
+		binaries[coverId] = volume.Cover
+	}
+
+	w.start("FictionBook", "xmlns", fb2Namespace, "xmlns:l", xlinkNamespace)
+	w.writeDescription(volume, coverId)
+
+	w.start("body")
+	w.start("title")
+	w.element("p", volume.Title)
+	w.end()
+	for _, chapter := range volume.Chapters {
+		if chapter.Content == nil {
+			return nil, fmt.Errorf("chapter %v has no content", chapter.Title)
+		}
+		blocks, err := convertHTML(chapter.Content.Html)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert chapter %v: %v", chapter.Title, err)
+		}
+		w.start("section")
+		w.start("title")
+		w.element("p", chapter.Title)
+		w.end()
+		for _, b := range blocks {
+			if b.kind == blockImage {
+				data, ok := chapter.Content.Images[b.image]
+				if !ok {
+					continue
+				}
+				id := binaryId(b.image)
+				binaries[id] = data
+				w.start("image", "l:href", "#"+id)
+				w.end()
+				continue
+			}
+			w.writeBlock(b)
+		}
+		// FB2 要求 <section> 至少包含一个段落
+		if len(blocks) == 0 {
+			w.start("empty-line")
+			w.end()
+		}
+		w.end()
+	}
+	w.end()
+
+	ids := make([]string, 0, len(binaries))
+	for id := range binaries {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		w.start("binary", "id", id, "content-type", http.DetectContentType(binaries[id]))
+		w.text(base64.StdEncoding.EncodeToString(binaries[id]))
+		w.end()
+	}
+	w.end()
+
+	if w.err == nil {
+		w.err = w.enc.Flush()
+	}
+	if w.err != nil {
+		return nil, fmt.Errorf("failed to encode fb2: %v", w.err)
+	}
+	return buf.Bytes(), nil
+}
+
+// binaryId 将图片文件名转为合法的 XML ID（不能以数字开头）
+func binaryId(filename string) string {
+	return "img-" + filepath.Base(filename)
+}
+
+func (w *writer) writeDescription(volume *model.Volume, coverId string) {
+	w.start("description")
+
+	w.start("title-info")
+	w.element("genre", "sf_fantasy")
+	authors := utils.Unique(volume.Authors)
+	if len(authors) == 0 {
+		authors = []string{"Unknown"}
+	}
+	for _, author := range authors {
+		w.start("author")
+		w.element("nickname", author)
+		w.end()
+	}
+	w.element("book-title", volume.Title)
+	if volume.Description != "" {
+		w.start("annotation")
+		for _, line := range strings.Split(volume.Description, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				w.element("p", line)
+			}
+		}
+		w.end()
+	}
+	if coverId != "" {
+		w.start("coverpage")
+		w.start("image", "l:href", "#"+coverId)
+		w.end()
+		w.end()
+	}
+	w.element("lang", volume.Lang())
+	if volume.NovelTitle != "" {
+		w.start("sequence", "name", volume.NovelTitle, "number", strconv.Itoa(volume.SeriesIdx))
+		w.end()
+	}
+	w.end()
+
+	now := utils.BuildTime()
+	w.start("document-info")
+	w.start("author")
+	w.element("nickname", "bilinovel-downloader")
+	w.end()
+	w.element("program-used", "bilinovel-downloader")
+	w.start("date", "value", now.Format("2006-01-02"))
+	w.text(now.Format("2006-01-02"))
+	w.end()
+	w.element("id", utils.VolumeUUID(volume.NovelId, volume.Id))
+	w.element("version", "1.0")
+	w.end()
+
+	w.end()
+}
+
+func (w *writer) writeBlock(b block) {
+	switch b.kind {
+	case blockEmptyLine:
+		w.start("empty-line")
+		w.end()
+	case blockSubtitle, blockParagraph:
+		name := "p"
+		if b.kind == blockSubtitle {
+			name = "subtitle"
+		}
+		w.start(name)
+		for _, s := range b.spans {
+			if s.strong {
+				w.start("strong")
+			}
+			if s.emphasis {
+				w.start("emphasis")
+			}
+			w.text(s.text)
+			if s.emphasis {
+				w.end()
+			}
+			if s.strong {
+				w.end()
+			}
+		}
+		w.end()
+	}
+}
+
+// writer 包装 xml.Encoder，记录第一个错误，避免每次写入都检查
+type writer struct {
+	enc   *xml.Encoder
+	stack []string
+	err   error
+}
+
+func (w *writer) start(name string, attrs ...string) {
+	if w.err != nil {
+		return
+	}
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: attrs[i]}, Value: attrs[i+1]})
+	}
+	w.stack = append(w.stack, name)
+	w.err = w.enc.EncodeToken(start)
+}
+
+func (w *writer) end() {
+	if w.err != nil {
+		return
+	}
+	name := w.stack[len(w.stack)-1]
+	w.stack = w.stack[:len(w.stack)-1]
+	w.err = w.enc.EncodeToken(xml.EndElement{Name: xml.Name{Local: name}})
+}
+
+func (w *writer) text(text string) {
+	if w.err != nil {
+		return
+	}
+	w.err = w.enc.EncodeToken(xml.CharData(text))
+}
+
+func (w *writer) element(name string, text string) {
+	w.start(name)
+	w.text(text)
+	w.end()
+}
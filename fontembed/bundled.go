@@ -0,0 +1,11 @@
+package fontembed
+
+import _ "embed"
+
+//go:embed "MI LANTING.ttf"
+var miLantingTTF []byte
+
+// Bundled 返回内置的 MI LANTING 字体，用于 --embed-font bundled 与 PDF 排版
+func Bundled() []byte {
+	return miLantingTTF
+}
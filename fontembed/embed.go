@@ -0,0 +1,77 @@
+package fontembed
+
+import (
+	"bilinovel-downloader/model"
+	"errors"
+	"fmt"
+	"html"
+	"sort"
+)
+
+// FontFamily 是嵌入字体在样式中使用的名称
+const FontFamily = "Embedded"
+
+// 界面上固定出现的文字，不在卷的内容中
+const fixedText = "目录封面正文"
+
+// Embed 取出 volumes 用到的字形，返回放在样式最前面的 @font-face 规则与要打包的字体文件。
+// CFF 轮廓的 OpenType 字体无法子集化，原样嵌入
+func Embed(fontData []byte, volumes ...*model.Volume) (string, []model.ExtraFile, error) {
+	ext, media := "ttf", "font/ttf"
+	data, err := Subset(fontData, VolumeRunes(volumes...))
+	if errors.Is(err, ErrNotTrueType) {
+		ext, media = "otf", "font/otf"
+		data = fontData
+	} else if err != nil {
+		return "", nil, fmt.Errorf("failed to subset font: %v", err)
+	}
+
+	fontPath := "Fonts/embedded." + ext
+	css := fmt.Sprintf(`@font-face {
+  font-family: "%s";
+  src: url(%s);
+}
+
+body {
+  font-family: "%s", serif;
+}
+
+`, FontFamily, fontPath, FontFamily)
+	return css, []model.ExtraFile{{
+		Data:         data,
+		Path:         fontPath,
+		ManifestItem: model.ManifestItem{ID: "embedded-font", Link: fontPath, Media: media},
+	}}, nil
+}
+
+// VolumeRunes 返回卷名、章节标题与正文用到的字符，并总是包含可打印的 ASCII 字符
+func VolumeRunes(volumes ...*model.Volume) []rune {
+	seen := make(map[rune]bool)
+	add := func(s string) {
+		for _, r := range s {
+			seen[r] = true
+		}
+	}
+	for r := rune(0x20); r < 0x7F; r++ {
+		seen[r] = true
+	}
+	add(fixedText)
+	for _, volume := range volumes {
+		add(volume.Title)
+		add(volume.NovelTitle)
+		for _, chapter := range volume.Chapters {
+			if chapter == nil {
+				continue
+			}
+			add(chapter.Title)
+			// 标签名与属性只含 ASCII，直接按反转义后的整段 HTML 统计
+			add(html.UnescapeString(chapter.Content.Html))
+		}
+	}
+	runes := make([]rune, 0, len(seen))
+	for r := range seen {
+		runes = append(runes, r)
+	}
+	sort.Slice(runes, func(i, j int) bool { return runes[i] < runes[j] })
+	return runes
+}
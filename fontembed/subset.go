@@ -0,0 +1,371 @@
+package fontembed
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+
+	"golang.org/x/image/font/sfnt"
+)
+
+// ErrNotTrueType 表示字体不是 glyf 轮廓的 TrueType 字体（例如 CFF 轮廓的 OpenType），无法子集化
+var ErrNotTrueType = errors.New("font has no truetype outlines")
+
+// 子集中保留的表。字形编号保持不变，hmtx、vmtx 等按字形编号索引的表可以原样保留；
+// GSUB 可能替换为已被清空的字形，与其它表一起丢弃
+var keepTables = map[string]bool{
+	"OS/2": true, "cmap": true, "cvt ": true, "fpgm": true, "gasp": true, "glyf": true, "head": true,
+	"hhea": true, "hmtx": true, "loca": true, "maxp": true, "name": true, "post": true, "prep": true,
+	"vhea": true, "vmtx": true,
+}
+
+// 复合字形的标志位
+const (
+	argsAreWords   = 0x0001
+	haveScale      = 0x0008
+	moreComponents = 0x0020
+	haveXYScale    = 0x0040
+	haveTwoByTwo   = 0x0080
+)
+
+// Subset 返回只保留 runes 中字符字形的 TrueType 字体。
+// 字形编号不变，未用到的字形被清空，cmap 只包含 runes 中字体支持的字符
+func Subset(fontData []byte, runes []rune) ([]byte, error) {
+	tables, err := readTables(fontData)
+	if err != nil {
+		return nil, err
+	}
+	for _, tag := range []string{"head", "maxp", "loca", "glyf", "cmap", "hhea", "hmtx"} {
+		if tables[tag] == nil {
+			if tag == "glyf" || tag == "loca" {
+				return nil, ErrNotTrueType
+			}
+			return nil, fmt.Errorf("missing %q table", tag)
+		}
+	}
+	head := append([]byte(nil), tables["head"]...)
+	if len(head) < 54 || len(tables["maxp"]) < 6 {
+		return nil, fmt.Errorf("invalid head or maxp table")
+	}
+	numGlyphs := int(binary.BigEndian.Uint16(tables["maxp"][4:]))
+	offsets, err := readLoca(tables["loca"], numGlyphs, binary.BigEndian.Uint16(head[50:]) == 1)
+	if err != nil {
+		return nil, err
+	}
+	glyf := tables["glyf"]
+	if int(offsets[numGlyphs]) > len(glyf) {
+		return nil, fmt.Errorf("loca points outside glyf")
+	}
+
+	// 字符到字形编号，沿用原字体的 cmap
+	f, err := sfnt.Parse(fontData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse font: %v", err)
+	}
+	var buf sfnt.Buffer
+	mapping := make(map[rune]uint16)
+	keep := map[uint16]bool{0: true}
+	var queue []uint16
+	for _, r := range runes {
+		gid, err := f.GlyphIndex(&buf, r)
+		if err != nil || gid == 0 || int(gid) >= numGlyphs {
+			continue
+		}
+		mapping[r] = uint16(gid)
+		if !keep[uint16(gid)] {
+			keep[uint16(gid)] = true
+			queue = append(queue, uint16(gid))
+		}
+	}
+	// 复合字形引用的部件也要保留
+	for len(queue) > 0 {
+		gid := queue[0]
+		queue = queue[1:]
+		for _, component := range components(glyf[offsets[gid]:offsets[gid+1]]) {
+			if int(component) < numGlyphs && !keep[component] {
+				keep[component] = true
+				queue = append(queue, component)
+			}
+		}
+	}
+
+	// 重建 glyf 与 loca：保留的字形原样复制并按 4 字节对齐，其余长度为 0
+	newGlyf := make([]byte, 0, len(glyf)/8)
+	newOffsets := make([]uint32, numGlyphs+1)
+	for gid := 0; gid < numGlyphs; gid++ {
+		newOffsets[gid] = uint32(len(newGlyf))
+		if keep[uint16(gid)] {
+			newGlyf = append(newGlyf, glyf[offsets[gid]:offsets[gid+1]]...)
+			for len(newGlyf)%4 != 0 {
+				newGlyf = append(newGlyf, 0)
+			}
+		}
+	}
+	newOffsets[numGlyphs] = uint32(len(newGlyf))
+	longLoca := len(newGlyf) > 0x1FFFE
+	if longLoca {
+		binary.BigEndian.PutUint16(head[50:], 1)
+	} else {
+		binary.BigEndian.PutUint16(head[50:], 0)
+	}
+	// checkSumAdjustment 在写出整个字体后计算
+	binary.BigEndian.PutUint32(head[8:], 0)
+
+	out := make(map[string][]byte)
+	for tag, data := range tables {
+		if keepTables[tag] {
+			out[tag] = data
+		}
+	}
+	out["head"] = head
+	out["glyf"] = newGlyf
+	out["loca"] = writeLoca(newOffsets, longLoca)
+	out["cmap"], err = writeCmap(mapping)
+	if err != nil {
+		return nil, err
+	}
+	// post 3.0 不含字形名
+	if post := tables["post"]; len(post) >= 32 {
+		post = append([]byte(nil), post[:32]...)
+		binary.BigEndian.PutUint32(post, 0x00030000)
+		out["post"] = post
+	}
+	return writeFont(out), nil
+}
+
+func readTables(data []byte) (map[string][]byte, error) {
+	if len(data) < 12 {
+		return nil, fmt.Errorf("font too short")
+	}
+	switch binary.BigEndian.Uint32(data) {
+	case 0x00010000, 0x74727565: // 'true'
+	case 0x4F54544F: // 'OTTO'
+		return nil, ErrNotTrueType
+	default:
+		return nil, fmt.Errorf("unsupported font format")
+	}
+	numTables := int(binary.BigEndian.Uint16(data[4:]))
+	if len(data) < 12+16*numTables {
+		return nil, fmt.Errorf("invalid table directory")
+	}
+	tables := make(map[string][]byte, numTables)
+	for i := 0; i < numTables; i++ {
+		record := data[12+16*i:]
+		offset := binary.BigEndian.Uint32(record[8:])
+		length := binary.BigEndian.Uint32(record[12:])
+		if uint64(offset)+uint64(length) > uint64(len(data)) {
+			return nil, fmt.Errorf("table %q outside font", record[:4])
+		}
+		tables[string(record[:4])] = data[offset : offset+length]
+	}
+	return tables, nil
+}
+
+func readLoca(loca []byte, numGlyphs int, long bool) ([]uint32, error) {
+	offsets := make([]uint32, numGlyphs+1)
+	if long {
+		if len(loca) < 4*(numGlyphs+1) {
+			return nil, fmt.Errorf("loca table too short")
+		}
+		for i := range offsets {
+			offsets[i] = binary.BigEndian.Uint32(loca[4*i:])
+		}
+	} else {
+		if len(loca) < 2*(numGlyphs+1) {
+			return nil, fmt.Errorf("loca table too short")
+		}
+		for i := range offsets {
+			offsets[i] = uint32(binary.BigEndian.Uint16(loca[2*i:])) * 2
+		}
+	}
+	for i := 1; i < len(offsets); i++ {
+		if offsets[i] < offsets[i-1] {
+			return nil, fmt.Errorf("loca offsets are not sorted")
+		}
+	}
+	return offsets, nil
+}
+
+func writeLoca(offsets []uint32, long bool) []byte {
+	if long {
+		loca := make([]byte, 4*len(offsets))
+		for i, offset := range offsets {
+			binary.BigEndian.PutUint32(loca[4*i:], offset)
+		}
+		return loca
+	}
+	loca := make([]byte, 2*len(offsets))
+	for i, offset := range offsets {
+		binary.BigEndian.PutUint16(loca[2*i:], uint16(offset/2))
+	}
+	return loca
+}
+
+// components 返回复合字形引用的字形编号，简单字形返回 nil
+func components(glyph []byte) []uint16 {
+	if len(glyph) < 10 || int16(binary.BigEndian.Uint16(glyph)) >= 0 {
+		return nil
+	}
+	var gids []uint16
+	for p := 10; p+4 <= len(glyph); {
+		flags := binary.BigEndian.Uint16(glyph[p:])
+		gids = append(gids, binary.BigEndian.Uint16(glyph[p+2:]))
+		p += 4
+		if flags&argsAreWords != 0 {
+			p += 4
+		} else {
+			p += 2
+		}
+		switch {
+		case flags&haveScale != 0:
+			p += 2
+		case flags&haveXYScale != 0:
+			p += 4
+		case flags&haveTwoByTwo != 0:
+			p += 8
+		}
+		if flags&moreComponents == 0 {
+			break
+		}
+	}
+	return gids
+}
+
+// writeCmap 写出 (3,1) format 4 子表，以及字符超出 BMP 时的 (3,10) format 12 子表
+func writeCmap(mapping map[rune]uint16) ([]byte, error) {
+	runes := make([]rune, 0, len(mapping))
+	for r := range mapping {
+		runes = append(runes, r)
+	}
+	sort.Slice(runes, func(i, j int) bool { return runes[i] < runes[j] })
+
+	// 字符与字形编号都连续的一段合并为一个区间
+	type segment struct {
+		start, end rune
+		gid        uint16
+	}
+	var bmp, all []segment
+	for _, r := range runes {
+		gid := mapping[r]
+		if n := len(all); n > 0 && all[n-1].end+1 == r && int(all[n-1].gid)+int(r-all[n-1].start) == int(gid) {
+			all[n-1].end = r
+		} else {
+			all = append(all, segment{r, r, gid})
+		}
+		// 0xFFFF 留给 format 4 结尾的区间
+		if r >= 0xFFFF {
+			continue
+		}
+		if n := len(bmp); n > 0 && bmp[n-1].end+1 == r && int(bmp[n-1].gid)+int(r-bmp[n-1].start) == int(gid) {
+			bmp[n-1].end = r
+		} else {
+			bmp = append(bmp, segment{r, r, gid})
+		}
+	}
+	bmp = append(bmp, segment{0xFFFF, 0xFFFF, 0})
+
+	segCount := len(bmp)
+	if 16+8*segCount > 0xFFFF {
+		return nil, fmt.Errorf("too many characters for a format 4 cmap: %d ranges", segCount)
+	}
+	searchRange, entrySelector := 2, 0
+	for searchRange*2 <= segCount*2 {
+		searchRange *= 2
+		entrySelector++
+	}
+	format4 := make([]byte, 16+8*segCount)
+	be := binary.BigEndian
+	be.PutUint16(format4[0:], 4)
+	be.PutUint16(format4[2:], uint16(len(format4)))
+	be.PutUint16(format4[6:], uint16(segCount*2))
+	be.PutUint16(format4[8:], uint16(searchRange))
+	be.PutUint16(format4[10:], uint16(entrySelector))
+	be.PutUint16(format4[12:], uint16(segCount*2-searchRange))
+	for i, seg := range bmp {
+		be.PutUint16(format4[14+2*i:], uint16(seg.end))
+		be.PutUint16(format4[16+2*segCount+2*i:], uint16(seg.start))
+		delta := uint16(1)
+		if seg.gid != 0 {
+			delta = seg.gid - uint16(seg.start)
+		}
+		be.PutUint16(format4[16+4*segCount+2*i:], delta)
+	}
+
+	subtables := [][]byte{format4}
+	if len(runes) > 0 && runes[len(runes)-1] > 0xFFFF {
+		format12 := make([]byte, 16+12*len(all))
+		be.PutUint16(format12[0:], 12)
+		be.PutUint32(format12[4:], uint32(len(format12)))
+		be.PutUint32(format12[12:], uint32(len(all)))
+		for i, seg := range all {
+			be.PutUint32(format12[16+12*i:], uint32(seg.start))
+			be.PutUint32(format12[20+12*i:], uint32(seg.end))
+			be.PutUint32(format12[24+12*i:], uint32(seg.gid))
+		}
+		subtables = append(subtables, format12)
+	}
+
+	cmap := make([]byte, 4+8*len(subtables))
+	be.PutUint16(cmap[2:], uint16(len(subtables)))
+	encodings := []uint16{1, 10}
+	for i, subtable := range subtables {
+		be.PutUint16(cmap[4+8*i:], 3)
+		be.PutUint16(cmap[6+8*i:], encodings[i])
+		be.PutUint32(cmap[8+8*i:], uint32(len(cmap)))
+		cmap = append(cmap, subtable...)
+	}
+	return cmap, nil
+}
+
+// writeFont 按标签排序写出表目录与各表，并填写 head 的 checkSumAdjustment
+func writeFont(tables map[string][]byte) []byte {
+	tags := make([]string, 0, len(tables))
+	for tag := range tables {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	be := binary.BigEndian
+	numTables := len(tags)
+	searchRange, entrySelector := 1, 0
+	for searchRange*2 <= numTables {
+		searchRange *= 2
+		entrySelector++
+	}
+	out := make([]byte, 12+16*numTables)
+	be.PutUint32(out[0:], 0x00010000)
+	be.PutUint16(out[4:], uint16(numTables))
+	be.PutUint16(out[6:], uint16(searchRange*16))
+	be.PutUint16(out[8:], uint16(entrySelector))
+	be.PutUint16(out[10:], uint16(numTables*16-searchRange*16))
+
+	headOffset := 0
+	for i, tag := range tags {
+		data := tables[tag]
+		record := out[12+16*i:]
+		copy(record, tag)
+		be.PutUint32(record[4:], checksum(data))
+		be.PutUint32(record[8:], uint32(len(out)))
+		be.PutUint32(record[12:], uint32(len(data)))
+		if tag == "head" {
+			headOffset = len(out)
+		}
+		out = append(out, data...)
+		for len(out)%4 != 0 {
+			out = append(out, 0)
+		}
+	}
+	be.PutUint32(out[headOffset+8:], 0xB1B0AFBA-checksum(out))
+	return out
+}
+
+func checksum(data []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(data); i += 4 {
+		var word [4]byte
+		copy(word[:], data[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
@@ -0,0 +1,79 @@
+package htmlbook
+
+import (
+	"bilinovel-downloader/model"
+	"bilinovel-downloader/template"
+	"bilinovel-downloader/utils"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// PackVolumeToHTML 将整卷输出为自包含的 <卷名>.html：样式内联、图片与封面转为 data URI，
+// 开头为封面和可点击的目录
+func PackVolumeToHTML(volume *model.Volume, outputPath string, styleCSS string) error {
+	chapters := make([]template.BookChapter, 0, len(volume.Chapters))
+	for i, chapter := range volume.Chapters {
+		if chapter.Content == nil {
+			return fmt.Errorf("chapter %v has no content", chapter.Title)
+		}
+		content, err := inlineImages(chapter.Content)
+		if err != nil {
+			return fmt.Errorf("failed to inline images of chapter %v: %v", chapter.Title, err)
+		}
+		chapters = append(chapters, template.BookChapter{
+			ID:      fmt.Sprintf("chapter-%03v", i),
+			Title:   chapter.Title,
+			Content: content,
+		})
+	}
+
+	coverPath := ""
+	if len(volume.Cover) > 0 {
+		coverPath = dataURI(volume.Cover)
+	}
+
+	err := os.MkdirAll(outputPath, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create output directory: %v", err)
+	}
+	file, err := os.Create(filepath.Join(outputPath, utils.CleanDirName(volume.Title)+".html"))
+	if err != nil {
+		return fmt.Errorf("failed to create html file: %v", err)
+	}
+	err = template.BookHTML(volume.Lang(), volume.Title, styleCSS, coverPath, chapters).Render(context.Background(), file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to render html: %v", err)
+	}
+	return nil
+}
+
+// inlineImages 将章节中引用 Images 的 <img src> 替换为 data URI
+func inlineImages(content *model.ChaperContent) (string, error) {
+	if len(content.Images) == 0 {
+		return content.Html, nil
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content.Html))
+	if err != nil {
+		return "", err
+	}
+	doc.Find("img").Each(func(i int, s *goquery.Selection) {
+		if data, ok := content.Images[s.AttrOr("src", "")]; ok {
+			s.SetAttr("src", dataURI(data))
+		}
+	})
+	return doc.Find("body").Html()
+}
+
+func dataURI(data []byte) string {
+	return fmt.Sprintf("data:%s;base64,%s", http.DetectContentType(data), base64.StdEncoding.EncodeToString(data))
+}
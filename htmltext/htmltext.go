@@ -0,0 +1,125 @@
+// Package htmltext 按文档顺序遍历章节 HTML，把文字、换行、图片、块级元素与强调
+// 交给各输出格式的 Handler，纯文本、Markdown、FB2、DOCX 与 PDF 共用同一套规则
+package htmltext
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// SpaceChars 为段落首尾需要去掉的空白，包括全角空格与不换行空格
+const SpaceChars = " \u3000\u00a0"
+
+// Style 为行内强调的种类
+type Style int
+
+const (
+	// Emphasis 对应 <em> 与 <i>
+	Emphasis Style = iota
+	// Strong 对应 <strong> 与 <b>
+	Strong
+)
+
+var (
+	spaceRegexp = regexp.MustCompile(`[ \t\r\n\f]+`)
+
+	blockElements = map[string]bool{
+		"p": true, "div": true, "section": true, "article": true, "blockquote": true,
+		"ul": true, "ol": true, "li": true, "table": true, "tr": true, "hr": true,
+		"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	}
+	headingLevels = map[string]int{"h1": 1, "h2": 2, "h3": 3, "h4": 4, "h5": 5, "h6": 6}
+)
+
+// Handler 接收遍历产生的事件
+type Handler interface {
+	// Text 为一段文字，连续空白已合并为一个半角空格
+	Text(text string)
+	// LineBreak 对应 <br>
+	LineBreak()
+	// Image 对应 <img>，src 可能为空
+	Image(src, alt string)
+	// StartBlock、EndBlock 包围块级元素与标题（h1-h6），<hr> 没有内容
+	StartBlock(tag string)
+	EndBlock(tag string)
+	// StartStyle、EndStyle 包围强调内容，可以嵌套
+	StartStyle(style Style)
+	EndStyle(style Style)
+}
+
+// HeadingLevel 返回标题元素的级别，不是标题时返回 0
+func HeadingLevel(tag string) int {
+	return headingLevels[tag]
+}
+
+// Walk 解析章节 HTML 片段并遍历。script 与 style 被忽略；
+// 注音按 基字（注音） 输出，供不支持 <ruby> 的格式使用，<rp> 被忽略
+func Walk(htmlContent string, h Handler) error {
+	nodes, err := html.ParseFragment(strings.NewReader(htmlContent), &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body})
+	if err != nil {
+		return fmt.Errorf("failed to parse html: %v", err)
+	}
+	for _, node := range nodes {
+		walk(node, h)
+	}
+	return nil
+}
+
+func walk(node *html.Node, h Handler) {
+	switch node.Type {
+	case html.TextNode:
+		h.Text(spaceRegexp.ReplaceAllString(node.Data, " "))
+		return
+	case html.ElementNode:
+	default:
+		walkChildren(node, h)
+		return
+	}
+
+	switch node.Data {
+	case "script", "style", "rp":
+	case "br":
+		h.LineBreak()
+	case "img":
+		h.Image(attr(node, "src"), attr(node, "alt"))
+	case "em", "i":
+		h.StartStyle(Emphasis)
+		walkChildren(node, h)
+		h.EndStyle(Emphasis)
+	case "strong", "b":
+		h.StartStyle(Strong)
+		walkChildren(node, h)
+		h.EndStyle(Strong)
+	case "rt":
+		h.Text("（")
+		walkChildren(node, h)
+		h.Text("）")
+	default:
+		if !blockElements[node.Data] {
+			walkChildren(node, h)
+			return
+		}
+		h.StartBlock(node.Data)
+		walkChildren(node, h)
+		h.EndBlock(node.Data)
+	}
+}
+
+func walkChildren(node *html.Node, h Handler) {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		walk(child, h)
+	}
+}
+
+func attr(node *html.Node, key string) string {
+	for _, a := range node.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
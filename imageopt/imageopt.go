@@ -0,0 +1,303 @@
+// Package imageopt 按实际内容识别插图格式，并按需转换格式、缩小尺寸、转为灰度或重新压缩，
+// 使旧阅读器与墨水屏设备也能正常显示
+package imageopt
+
+import (
+	"bilinovel-downloader/model"
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"log"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// Formats 是 Options.Format 可用的值
+var Formats = []string{"auto", "jpeg", "png"}
+
+// 未指定 Quality 但需要重新编码 JPEG 时使用的质量
+const defaultQuality = 90
+
+// Options 为图片处理选项，零值表示不做任何处理
+type Options struct {
+	// Format 为输出格式：空字符串保留原格式；auto 将 WebP 与 GIF 转为 JPEG（带透明度时为 PNG）；
+	// jpeg、png 转换所有图片，转为 JPEG 时透明部分填充为白色
+	Format string
+	// MaxWidth、MaxHeight 为最大像素尺寸，超出时等比缩小，0 表示不限制
+	MaxWidth  int
+	MaxHeight int
+	// Grayscale 将图片转为灰度
+	Grayscale bool
+	// Quality 为 JPEG 质量（1-100），大于 0 时会重新压缩 JPEG，结果更大时保留原图
+	Quality int
+}
+
+// Enabled 返回是否需要处理图片
+func (o Options) Enabled() bool {
+	return o.Format != "" || o.MaxWidth > 0 || o.MaxHeight > 0 || o.Grayscale || o.Quality > 0
+}
+
+// Validate 检查选项是否有效
+func (o Options) Validate() error {
+	switch o.Format {
+	case "", "auto", "jpeg", "png":
+	default:
+		return fmt.Errorf("unknown image format %q, use %v", o.Format, strings.Join(Formats, ", "))
+	}
+	if o.MaxWidth < 0 || o.MaxHeight < 0 {
+		return fmt.Errorf("invalid image size %dx%d", o.MaxWidth, o.MaxHeight)
+	}
+	if o.Quality < 0 || o.Quality > 100 {
+		return fmt.Errorf("invalid image quality %d, use 1-100", o.Quality)
+	}
+	return nil
+}
+
+// ParseSize 解析 <宽>x<高> 形式的最大尺寸，某一边为 0 表示不限制
+func ParseSize(s string) (int, int, error) {
+	if s == "" {
+		return 0, 0, nil
+	}
+	var width, height int
+	if n, err := fmt.Sscanf(strings.ToLower(s), "%dx%d", &width, &height); err != nil || n != 2 || width < 0 || height < 0 {
+		return 0, 0, fmt.Errorf("invalid image size %q, use <width>x<height>", s)
+	}
+	return width, height, nil
+}
+
+// Sniff 按内容识别图片格式，返回 jpeg、png、gif、webp，无法识别（如 SVG）时返回空字符串
+func Sniff(data []byte) string {
+	switch http.DetectContentType(data) {
+	case "image/jpeg":
+		return "jpeg"
+	case "image/png":
+		return "png"
+	case "image/gif":
+		return "gif"
+	case "image/webp":
+		return "webp"
+	}
+	return ""
+}
+
+// Extension 返回格式对应的扩展名，jpeg 写作 jpg
+func Extension(format string) string {
+	if format == "jpeg" {
+		return "jpg"
+	}
+	return format
+}
+
+// FileExtension 返回图片文件应使用的扩展名（不含点）：按内容识别，无法识别时由文件名或地址推断，
+// 缺省为 jpg，jpeg 统一写作 jpg
+func FileExtension(data []byte, name string) string {
+	if format := Sniff(data); format != "" {
+		return Extension(format)
+	}
+	ext := strings.ToLower(strings.TrimPrefix(path.Ext(name), "."))
+	if ext == "" {
+		return "jpg"
+	}
+	return strings.ReplaceAll(ext, "jpeg", "jpg")
+}
+
+// Process 按选项处理一张图片，不需要处理或无法识别格式时原样返回
+func Process(data []byte, opts Options) ([]byte, error) {
+	format := Sniff(data)
+	if format == "" {
+		return data, nil
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %v", err)
+	}
+	width, height := fitSize(config.Width, config.Height, opts.MaxWidth, opts.MaxHeight)
+	resize := width != config.Width || height != config.Height
+	recompress := opts.Quality > 0 && format == "jpeg"
+
+	target := opts.Format
+	switch target {
+	case "":
+		// WebP 与 GIF 无法重新编码，需要修改时与 auto 相同
+		target = format
+		if (format == "webp" || format == "gif") && (resize || opts.Grayscale) {
+			target = "auto"
+		}
+	case "auto":
+		if format != "webp" && format != "gif" {
+			target = format
+		}
+	}
+	if target == format && !resize && !opts.Grayscale && !recompress {
+		return data, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %v", err)
+	}
+	if target == "auto" {
+		target = "jpeg"
+		if !opaque(img) {
+			target = "png"
+		}
+	}
+	if resize {
+		scaled := image.NewNRGBA(image.Rect(0, 0, width, height))
+		xdraw.CatmullRom.Scale(scaled, scaled.Bounds(), img, img.Bounds(), draw.Src, nil)
+		img = scaled
+	}
+	if target == "jpeg" {
+		img = flatten(img)
+	}
+	if opts.Grayscale {
+		img = grayscale(img)
+	}
+
+	var buf bytes.Buffer
+	if target == "jpeg" {
+		quality := opts.Quality
+		if quality == 0 {
+			quality = defaultQuality
+		}
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
+	} else {
+		err = (&png.Encoder{CompressionLevel: png.BestCompression}).Encode(&buf, img)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode image: %v", err)
+	}
+	// 只重新压缩时，结果更大则保留原图
+	if target == format && !resize && !opts.Grayscale && buf.Len() >= len(data) {
+		return data, nil
+	}
+	return buf.Bytes(), nil
+}
+
+// ProcessVolume 返回处理图片后的卷副本：章节图片按实际格式改名并同步修改正文中的引用，封面一并处理。
+// 单张图片处理失败时保留原图
+func ProcessVolume(volume *model.Volume, opts Options) *model.Volume {
+	processed := *volume
+	if len(volume.Cover) > 0 {
+		processed.Cover = processImage(volume.CoverUrl, volume.Cover, opts)
+	}
+	processed.Chapters = make([]*model.Chapter, 0, len(volume.Chapters))
+	for _, chapter := range volume.Chapters {
+		if chapter == nil || chapter.Content == nil || len(chapter.Content.Images) == 0 {
+			processed.Chapters = append(processed.Chapters, chapter)
+			continue
+		}
+		ch := *chapter
+		content := *chapter.Content
+		content.Images = make(map[string][]byte, len(chapter.Content.Images))
+		names := make([]string, 0, len(chapter.Content.Images))
+		for name := range chapter.Content.Images {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			data := processImage(name, chapter.Content.Images[name], opts)
+			newName := name
+			if format := Sniff(data); format != "" {
+				newName = strings.TrimSuffix(name, path.Ext(name)) + "." + Extension(format)
+			}
+			if newName != name {
+				content.Html = strings.ReplaceAll(content.Html, name, newName)
+			}
+			content.Images[newName] = data
+		}
+		ch.Content = &content
+		processed.Chapters = append(processed.Chapters, &ch)
+	}
+	return &processed
+}
+
+// ProcessNovel 返回处理图片后的小说副本，各卷按 ProcessVolume 处理
+func ProcessNovel(novel *model.Novel, opts Options) *model.Novel {
+	processed := *novel
+	processed.Volumes = make([]*model.Volume, 0, len(novel.Volumes))
+	for _, volume := range novel.Volumes {
+		processed.Volumes = append(processed.Volumes, ProcessVolume(volume, opts))
+	}
+	return &processed
+}
+
+func processImage(name string, data []byte, opts Options) []byte {
+	processed, err := Process(data, opts)
+	if err != nil {
+		log.Printf("Failed to process image %v: %v", name, err)
+		return data
+	}
+	return processed
+}
+
+// fitSize 返回等比缩小到 maxWidth×maxHeight 以内的尺寸，不放大
+func fitSize(width, height, maxWidth, maxHeight int) (int, int) {
+	scale := 1.0
+	if maxWidth > 0 && width > maxWidth {
+		scale = float64(maxWidth) / float64(width)
+	}
+	if maxHeight > 0 && height > maxHeight {
+		scale = min(scale, float64(maxHeight)/float64(height))
+	}
+	if scale == 1 {
+		return width, height
+	}
+	return max(1, int(float64(width)*scale+0.5)), max(1, int(float64(height)*scale+0.5))
+}
+
+func opaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a != 0xffff {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// flatten 将透明部分合成到白色背景上，JPEG 不支持透明度
+func flatten(img image.Image) image.Image {
+	if opaque(img) {
+		return img
+	}
+	bounds := img.Bounds()
+	flat := image.NewRGBA(bounds)
+	draw.Draw(flat, bounds, image.White, image.Point{}, draw.Src)
+	draw.Draw(flat, bounds, img, bounds.Min, draw.Over)
+	return flat
+}
+
+// grayscale 转为灰度，带透明度的图片保留透明度
+func grayscale(img image.Image) image.Image {
+	bounds := img.Bounds()
+	if opaque(img) {
+		gray := image.NewGray(bounds)
+		draw.Draw(gray, bounds, img, bounds.Min, draw.Src)
+		return gray
+	}
+	gray := image.NewNRGBA(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			g := color.GrayModel.Convert(color.NRGBA{R: c.R, G: c.G, B: c.B, A: 0xff}).(color.Gray)
+			gray.SetNRGBA(x, y, color.NRGBA{R: g.Y, G: g.Y, B: g.Y, A: c.A})
+		}
+	}
+	return gray
+}
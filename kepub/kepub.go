@@ -0,0 +1,237 @@
+package kepub
+
+import (
+	"archive/zip"
+	"bilinovel-downloader/epub"
+	"bilinovel-downloader/model"
+	"bilinovel-downloader/utils"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var (
+	xmlDeclRegexp = regexp.MustCompile(`^\s*<\?xml[^>]*\?>\s*`)
+	// 句末标点，后面可以跟着引号或括号
+	sentenceRegexp = regexp.MustCompile(`[^。！？!?…]*(?:[。！？!?]+|…+)[」』”’）)\]]*|[^。！？!?…]+$`)
+
+	// paragraphElements 中的文字按段编号，其余位置的文字每个文本节点单独成段
+	paragraphElements = map[string]bool{
+		"p": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+		"li": true, "dt": true, "dd": true, "td": true, "th": true, "figcaption": true, "pre": true,
+	}
+)
+
+// PackVolumeToKepub 先用 epub.PackVolumeToEpub 打包，再转换为 <卷名>.kepub.epub
+func PackVolumeToKepub(volume *model.Volume, outputPath string, styleCSS string, extraFiles []model.ExtraFile, opts epub.Options) error {
+	if err := epub.PackVolumeToEpub(volume, outputPath, styleCSS, extraFiles, opts); err != nil {
+		return err
+	}
+	return convertAndRemove(filepath.Join(outputPath, utils.CleanDirName(volume.Title)))
+}
+
+// PackNovelToKepub 先用 epub.PackNovelToEpub 打包合集，再转换为 <书名>.kepub.epub
+func PackNovelToKepub(novel *model.Novel, outputPath string, styleCSS string, extraFiles []model.ExtraFile, opts epub.Options) error {
+	if err := epub.PackNovelToEpub(novel, outputPath, styleCSS, extraFiles, opts); err != nil {
+		return err
+	}
+	return convertAndRemove(filepath.Join(outputPath, utils.CleanDirName(novel.Title)))
+}
+
+func convertAndRemove(basePath string) error {
+	if err := ConvertEpub(basePath+".epub", basePath+".kepub.epub"); err != nil {
+		return err
+	}
+	if err := os.Remove(basePath + ".epub"); err != nil {
+		return fmt.Errorf("failed to remove epub: %v", err)
+	}
+	return nil
+}
+
+// ConvertEpub 读取 EPUB，为正文 XHTML 加上 koboSpan 后写入 kepubPath，其余条目原样复制
+func ConvertEpub(epubPath string, kepubPath string) error {
+	r, err := zip.OpenReader(epubPath)
+	if err != nil {
+		return fmt.Errorf("failed to open epub: %v", err)
+	}
+	defer r.Close()
+
+	var buf bytes.Buffer
+	zipWriter := zip.NewWriter(&buf)
+	// mimetype 必须是第一个条目且不压缩
+	w, err := zipWriter.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store, Modified: utils.BuildTime()})
+	if err != nil {
+		return fmt.Errorf("failed to write mimetype: %v", err)
+	}
+	if _, err := w.Write([]byte("application/epub+zip")); err != nil {
+		return fmt.Errorf("failed to write mimetype: %v", err)
+	}
+
+	for _, f := range r.File {
+		if f.Name == "mimetype" {
+			continue
+		}
+		data, err := readZipFile(f)
+		if err != nil {
+			return fmt.Errorf("failed to read %v: %v", f.Name, err)
+		}
+		if ext := path.Ext(f.Name); ext == ".xhtml" || ext == ".html" {
+			data, err = AddKoboSpans(data)
+			if err != nil {
+				return fmt.Errorf("failed to convert %v: %v", f.Name, err)
+			}
+		}
+		w, err := zipWriter.CreateHeader(&zip.FileHeader{Name: f.Name, Method: f.Method, Modified: utils.BuildTime()})
+		if err != nil {
+			return fmt.Errorf("failed to create zip entry: %v", err)
+		}
+		if _, err := w.Write(data); err != nil {
+			return fmt.Errorf("failed to write zip entry: %v", err)
+		}
+	}
+	if err := zipWriter.Close(); err != nil {
+		return fmt.Errorf("failed to close zip writer: %v", err)
+	}
+	if err := os.WriteFile(kepubPath, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write kepub: %v", err)
+	}
+	return nil
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// AddKoboSpans 将正文按段、按句包裹在 <span class="koboSpan" id="kobo.段.句"> 中，
+// 并把 body 内容放进 book-columns/book-inner 容器。编号只取决于文档结构，重复生成结果相同。
+// 目录页（含 <nav>）和封面页（含 <svg>）保持不变
+func AddKoboSpans(xhtml []byte) ([]byte, error) {
+	decl := xmlDeclRegexp.Find(xhtml)
+	doc, err := html.Parse(bytes.NewReader(xhtml[len(decl):]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse xhtml: %v", err)
+	}
+	body := findElement(doc, atom.Body)
+	if body == nil || findElement(body, atom.Nav) != nil || findElement(body, atom.Svg) != nil {
+		return xhtml, nil
+	}
+
+	s := &spanner{}
+	s.walk(body, false)
+
+	// book-columns/book-inner 是 Kobo 阅读器排版所需的容器
+	inner := &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div, Attr: []html.Attribute{{Key: "id", Val: "book-inner"}}}
+	columns := &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div, Attr: []html.Attribute{{Key: "id", Val: "book-columns"}}}
+	for child := body.FirstChild; child != nil; child = body.FirstChild {
+		body.RemoveChild(child)
+		inner.AppendChild(child)
+	}
+	columns.AppendChild(inner)
+	body.AppendChild(columns)
+
+	var buf bytes.Buffer
+	buf.Write(decl)
+	if err := html.Render(&buf, doc); err != nil {
+		return nil, fmt.Errorf("failed to render xhtml: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func findElement(node *html.Node, a atom.Atom) *html.Node {
+	if node.Type == html.ElementNode && node.DataAtom == a {
+		return node
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if found := findElement(child, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// spanner 按文档顺序分配 koboSpan 编号
+type spanner struct {
+	paragraph int
+	sentence  int
+}
+
+func (s *spanner) next(newParagraph bool) *html.Node {
+	if newParagraph || s.paragraph == 0 {
+		s.paragraph++
+		s.sentence = 0
+	}
+	s.sentence++
+	return &html.Node{
+		Type:     html.ElementNode,
+		Data:     "span",
+		DataAtom: atom.Span,
+		Attr: []html.Attribute{
+			{Key: "class", Val: "koboSpan"},
+			{Key: "id", Val: fmt.Sprintf("kobo.%d.%d", s.paragraph, s.sentence)},
+		},
+	}
+}
+
+// walk 处理 node 的子节点，inParagraph 表示是否位于 paragraphElements 内
+func (s *spanner) walk(node *html.Node, inParagraph bool) {
+	for child := node.FirstChild; child != nil; {
+		next := child.NextSibling
+		switch child.Type {
+		case html.TextNode:
+			if strings.TrimSpace(child.Data) != "" {
+				s.wrapText(child, inParagraph)
+			}
+		case html.ElementNode:
+			switch {
+			case child.DataAtom == atom.Script || child.DataAtom == atom.Style:
+			case child.DataAtom == atom.Img:
+				span := s.next(!inParagraph)
+				node.InsertBefore(span, child)
+				node.RemoveChild(child)
+				span.AppendChild(child)
+			case paragraphElements[child.Data] && !inParagraph:
+				s.paragraph++
+				s.sentence = 0
+				s.walk(child, true)
+			default:
+				s.walk(child, inParagraph)
+			}
+		}
+		child = next
+	}
+}
+
+// wrapText 将文本节点按句拆开，每句放进一个 koboSpan
+func (s *spanner) wrapText(text *html.Node, inParagraph bool) {
+	parent := text.Parent
+	sentences := sentenceRegexp.FindAllString(text.Data, -1)
+	if strings.Join(sentences, "") != text.Data {
+		sentences = []string{text.Data}
+	}
+	var last *html.Node
+	for i, sentence := range sentences {
+		// 句间的空白并入前一句，不单独成句
+		if last != nil && strings.TrimSpace(sentence) == "" {
+			last.Data += sentence
+			continue
+		}
+		span := s.next(!inParagraph && i == 0)
+		last = &html.Node{Type: html.TextNode, Data: sentence}
+		span.AppendChild(last)
+		parent.InsertBefore(span, text)
+	}
+	parent.RemoveChild(text)
+}
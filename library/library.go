@@ -0,0 +1,271 @@
+package library
+
+import (
+	"bilinovel-downloader/model"
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Snapshot 记录上次下载时小说的目录结构，只保留检查更新需要的字段
+type Snapshot struct {
+	Id      int               `json:"id"`
+	Title   string            `json:"title"`
+	Volumes []*VolumeSnapshot `json:"volumes"`
+}
+
+type VolumeSnapshot struct {
+	Id       int                `json:"id"`
+	Title    string             `json:"title"`
+	Chapters []*ChapterSnapshot `json:"chapters"`
+}
+
+type ChapterSnapshot struct {
+	// Key 为章节链接的路径部分，切换镜像不影响比较
+	Key   string `json:"key"`
+	Title string `json:"title"`
+}
+
+// Change 的类型
+const (
+	NewVolume    = "new-volume"
+	NewChapter   = "new-chapter"
+	NovelTitle   = "novel-title"
+	VolumeTitle  = "volume-title"
+	ChapterTitle = "chapter-title"
+)
+
+// Change 是一条更新，Old/New 为标题，Chapters 为新卷的章节数
+type Change struct {
+	Kind        string `json:"kind"`
+	VolumeId    int    `json:"volume_id,omitempty"`
+	VolumeTitle string `json:"volume_title,omitempty"`
+	Old         string `json:"old,omitempty"`
+	New         string `json:"new"`
+	Chapters    int    `json:"chapters,omitempty"`
+}
+
+// Report 是一部小说的检查结果
+type Report struct {
+	NovelId int      `json:"novel_id"`
+	Title   string   `json:"title"`
+	Changes []Change `json:"changes"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// UpdatedVolumes 返回新增或有新章节的卷，按出现顺序排列
+func (r *Report) UpdatedVolumes() []int {
+	var ids []int
+	seen := make(map[int]bool)
+	for _, change := range r.Changes {
+		if change.Kind != NewVolume && change.Kind != NewChapter {
+			continue
+		}
+		if !seen[change.VolumeId] {
+			seen[change.VolumeId] = true
+			ids = append(ids, change.VolumeId)
+		}
+	}
+	return ids
+}
+
+// NewSnapshot 从 GetNovel 的结果生成快照，章节内容可以为空
+func NewSnapshot(novel *model.Novel) *Snapshot {
+	snapshot := &Snapshot{Id: novel.Id, Title: novel.Title, Volumes: make([]*VolumeSnapshot, 0, len(novel.Volumes))}
+	for _, volume := range novel.Volumes {
+		snapshot.Volumes = append(snapshot.Volumes, newVolumeSnapshot(volume))
+	}
+	return snapshot
+}
+
+func newVolumeSnapshot(volume *model.Volume) *VolumeSnapshot {
+	v := &VolumeSnapshot{Id: volume.Id, Title: volume.Title, Chapters: make([]*ChapterSnapshot, 0, len(volume.Chapters))}
+	for _, chapter := range volume.Chapters {
+		if chapter == nil {
+			continue
+		}
+		v.Chapters = append(v.Chapters, &ChapterSnapshot{Key: chapterKey(chapter.Url), Title: chapter.Title})
+	}
+	return v
+}
+
+func chapterKey(chapterUrl string) string {
+	u, err := url.Parse(chapterUrl)
+	if err != nil || u.Path == "" {
+		return chapterUrl
+	}
+	return u.Path
+}
+
+// MergeVolume 用已下载的单卷更新快照，卷的顺序不影响比较
+func (s *Snapshot) MergeVolume(volume *model.Volume) {
+	if s.Title == "" {
+		s.Title = volume.NovelTitle
+	}
+	v := newVolumeSnapshot(volume)
+	for i, old := range s.Volumes {
+		if old.Id == volume.Id {
+			s.Volumes[i] = v
+			return
+		}
+	}
+	s.Volumes = append(s.Volumes, v)
+}
+
+// Diff 比较上次下载的快照与当前目录，old 为 nil 时所有卷都视为新卷
+func Diff(old *Snapshot, current *Snapshot) *Report {
+	report := &Report{NovelId: current.Id, Title: current.Title, Changes: make([]Change, 0)}
+	if old == nil {
+		old = &Snapshot{Id: current.Id, Title: current.Title}
+	}
+	if old.Title != current.Title {
+		report.Changes = append(report.Changes, Change{Kind: NovelTitle, Old: old.Title, New: current.Title})
+	}
+
+	oldVolumes := make(map[int]*VolumeSnapshot, len(old.Volumes))
+	for _, volume := range old.Volumes {
+		oldVolumes[volume.Id] = volume
+	}
+	for _, volume := range current.Volumes {
+		oldVolume, ok := oldVolumes[volume.Id]
+		if !ok {
+			report.Changes = append(report.Changes, Change{
+				Kind: NewVolume, VolumeId: volume.Id, VolumeTitle: volume.Title, New: volume.Title, Chapters: len(volume.Chapters),
+			})
+			continue
+		}
+		if oldVolume.Title != volume.Title {
+			report.Changes = append(report.Changes, Change{
+				Kind: VolumeTitle, VolumeId: volume.Id, VolumeTitle: volume.Title, Old: oldVolume.Title, New: volume.Title,
+			})
+		}
+
+		oldChapters := make(map[string]*ChapterSnapshot, len(oldVolume.Chapters))
+		for _, chapter := range oldVolume.Chapters {
+			oldChapters[chapter.Key] = chapter
+		}
+		for _, chapter := range volume.Chapters {
+			oldChapter, ok := oldChapters[chapter.Key]
+			switch {
+			case !ok:
+				report.Changes = append(report.Changes, Change{
+					Kind: NewChapter, VolumeId: volume.Id, VolumeTitle: volume.Title, New: chapter.Title,
+				})
+			case oldChapter.Title != chapter.Title:
+				report.Changes = append(report.Changes, Change{
+					Kind: ChapterTitle, VolumeId: volume.Id, VolumeTitle: volume.Title, Old: oldChapter.Title, New: chapter.Title,
+				})
+			}
+		}
+	}
+	return report
+}
+
+// SnapshotPath 返回快照在输出目录中的位置
+func SnapshotPath(outputPath string, novelId int) string {
+	return filepath.Join(outputPath, fmt.Sprintf("novel-%d.json", novelId))
+}
+
+// LoadSnapshot 读取快照，不存在时返回 nil, nil
+func LoadSnapshot(outputPath string, novelId int) (*Snapshot, error) {
+	data, err := os.ReadFile(SnapshotPath(outputPath, novelId))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %v", err)
+	}
+	snapshot := &Snapshot{}
+	if err := json.Unmarshal(data, snapshot); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot: %v", err)
+	}
+	return snapshot, nil
+}
+
+// SaveSnapshot 先写临时文件再重命名
+func SaveSnapshot(outputPath string, snapshot *Snapshot) error {
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %v", err)
+	}
+	snapshotPath := SnapshotPath(outputPath, snapshot.Id)
+	if err := os.MkdirAll(filepath.Dir(snapshotPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+	tmpPath := snapshotPath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write snapshot: %v", err)
+	}
+	if err := os.Rename(tmpPath, snapshotPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to rename snapshot: %v", err)
+	}
+	return nil
+}
+
+// SnapshotFromVolumes 在没有快照时由已下载的 volume-<novel>-<volume>.json 重建，
+// 兼容此前版本下载的小说；一卷都没有时返回 nil
+func SnapshotFromVolumes(outputPath string, novelId int) (*Snapshot, error) {
+	matches, err := filepath.Glob(filepath.Join(outputPath, fmt.Sprintf("volume-%d-*.json", novelId)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list volumes: %v", err)
+	}
+	volumes := make([]*model.Volume, 0, len(matches))
+	for _, match := range matches {
+		data, err := os.ReadFile(match)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read volume: %v", err)
+		}
+		volume := &model.Volume{}
+		if err := json.Unmarshal(data, volume); err != nil {
+			return nil, fmt.Errorf("failed to decode %v: %v", filepath.Base(match), err)
+		}
+		volumes = append(volumes, volume)
+	}
+	if len(volumes) == 0 {
+		return nil, nil
+	}
+	sort.Slice(volumes, func(i, j int) bool {
+		return volumes[i].SeriesIdx < volumes[j].SeriesIdx
+	})
+	snapshot := &Snapshot{Id: novelId}
+	for _, volume := range volumes {
+		snapshot.MergeVolume(volume)
+	}
+	return snapshot, nil
+}
+
+// ReadList 读取关注列表：每行一个小说 ID，# 之后为注释，空行忽略
+func ReadList(listPath string) ([]int, error) {
+	file, err := os.Open(listPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open novel list: %v", err)
+	}
+	defer file.Close()
+
+	var ids []int
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		id, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid novel id on line %d: %q", line, fields[0])
+		}
+		ids = append(ids, id)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read novel list: %v", err)
+	}
+	return ids, nil
+}
@@ -2,8 +2,19 @@ package main
 
 import (
 	"bilinovel-downloader/cmd"
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
-	_ = cmd.RootCmd.Execute()
+	// Ctrl-C 时取消 context，让正在进行的下载清理后退出
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := cmd.RootCmd.ExecuteContext(ctx)
+	stop()
+	// 下载失败或校验发现问题时以非零状态退出，便于脚本判断
+	if err != nil {
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,115 @@
+package markdown
+
+import (
+	"bilinovel-downloader/htmltext"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var (
+	// 行首会被解析为标题、引用、列表或分隔线的字符
+	lineStartRegexp = regexp.MustCompile(`^([#>+=-]|\d+[.)])`)
+	inlineEscaper   = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `_`, `\_`, "`", "\\`", `[`, `\[`, `]`, `\]`, `<`, `\<`)
+)
+
+// converter 将章节 HTML 转为 CommonMark 段落列表
+type converter struct {
+	blocks    []string
+	inline    strings.Builder
+	assetsDir string
+	// headingOffset 叠加到正文中的标题级别上，避免与卷、章标题冲突
+	headingOffset int
+	// outer 保存强调开始前的行内内容，强调结束时再拼回
+	outer []string
+}
+
+func (c *converter) flush() {
+	text := strings.Trim(c.inline.String(), htmltext.SpaceChars)
+	c.inline.Reset()
+	text = strings.TrimSuffix(text, "\\\n")
+	if text == "" {
+		return
+	}
+	lines := strings.Split(text, "\n")
+	for i, l := range lines {
+		l = strings.TrimLeft(l, htmltext.SpaceChars)
+		if lineStartRegexp.MatchString(l) {
+			l = `\` + l
+		}
+		lines[i] = l
+	}
+	c.blocks = append(c.blocks, strings.Join(lines, "\n"))
+}
+
+func (c *converter) Text(text string) {
+	c.inline.WriteString(inlineEscaper.Replace(text))
+}
+
+func (c *converter) LineBreak() {
+	if strings.Trim(c.inline.String(), htmltext.SpaceChars) != "" {
+		c.inline.WriteString("\\\n")
+	}
+}
+
+func (c *converter) Image(src, alt string) {
+	if src == "" {
+		return
+	}
+	if !strings.Contains(src, "://") {
+		src = path.Join(c.assetsDir, src)
+	}
+	c.inline.WriteString(fmt.Sprintf("![%s](%s)", inlineEscaper.Replace(alt), src))
+}
+
+func (c *converter) StartBlock(tag string) {
+	c.flush()
+	if tag == "hr" {
+		c.blocks = append(c.blocks, "* * *")
+	}
+}
+
+func (c *converter) EndBlock(tag string) {
+	level := htmltext.HeadingLevel(tag)
+	if level == 0 {
+		c.flush()
+		return
+	}
+	text := strings.TrimSpace(strings.ReplaceAll(c.inline.String(), "\\\n", " "))
+	c.inline.Reset()
+	if text != "" {
+		c.blocks = append(c.blocks, strings.Repeat("#", min(level+c.headingOffset, 6))+" "+text)
+	}
+}
+
+// StartStyle 与 EndStyle 用强调符号包裹其中的内容，内容为空时不输出符号
+func (c *converter) StartStyle(style htmltext.Style) {
+	c.outer = append(c.outer, c.inline.String())
+	c.inline.Reset()
+}
+
+func (c *converter) EndStyle(style htmltext.Style) {
+	mark := "*"
+	if style == htmltext.Strong {
+		mark = "**"
+	}
+	inner := strings.TrimSpace(c.inline.String())
+	c.inline.Reset()
+	c.inline.WriteString(c.outer[len(c.outer)-1])
+	c.outer = c.outer[:len(c.outer)-1]
+	if inner != "" {
+		c.inline.WriteString(mark + inner + mark)
+	}
+}
+
+// HTMLToMarkdown 将章节 HTML 转为 CommonMark，相对路径的图片链接指向 assetsDir，
+// 正文中的标题级别会加上 headingOffset
+func HTMLToMarkdown(htmlContent string, assetsDir string, headingOffset int) (string, error) {
+	c := &converter{assetsDir: assetsDir, headingOffset: headingOffset}
+	if err := htmltext.Walk(htmlContent, c); err != nil {
+		return "", err
+	}
+	c.flush()
+	return strings.Join(c.blocks, "\n\n"), nil
+}
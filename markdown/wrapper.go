@@ -0,0 +1,100 @@
+package markdown
+
+import (
+	"bilinovel-downloader/imageopt"
+	"bilinovel-downloader/model"
+	"bilinovel-downloader/utils"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const assetsDir = "assets"
+
+// PackVolumeToMarkdown 将整卷输出为 <卷名>/<卷名>.md，图片与封面写入同目录下的 assets
+func PackVolumeToMarkdown(volume *model.Volume, outputPath string) error {
+	outputPath = filepath.Join(outputPath, utils.CleanDirName(volume.Title))
+	err := os.RemoveAll(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to remove output directory: %v", err)
+	}
+	err = os.MkdirAll(filepath.Join(outputPath, assetsDir), 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create output directory: %v", err)
+	}
+
+	coverPath := ""
+	if len(volume.Cover) > 0 {
+		coverPath = path.Join(assetsDir, "cover."+imageopt.FileExtension(volume.Cover, volume.CoverUrl))
+		err = os.WriteFile(filepath.Join(outputPath, filepath.FromSlash(coverPath)), volume.Cover, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to write cover: %v", err)
+		}
+	}
+
+	var sb strings.Builder
+	writeFrontMatter(&sb, volume, coverPath)
+	sb.WriteString("# ")
+	sb.WriteString(volume.Title)
+	sb.WriteString("\n")
+
+	for _, chapter := range volume.Chapters {
+		if chapter.Content == nil {
+			return fmt.Errorf("chapter %v has no content", chapter.Title)
+		}
+		body, err := HTMLToMarkdown(chapter.Content.Html, assetsDir, 2)
+		if err != nil {
+			return fmt.Errorf("failed to convert chapter %v: %v", chapter.Title, err)
+		}
+		sb.WriteString("\n## ")
+		sb.WriteString(chapter.Title)
+		sb.WriteString("\n\n")
+		if body != "" {
+			sb.WriteString(body)
+			sb.WriteString("\n")
+		}
+
+		for filename, data := range chapter.Content.Images {
+			err = os.WriteFile(filepath.Join(outputPath, assetsDir, filepath.Base(filename)), data, 0644)
+			if err != nil {
+				return fmt.Errorf("failed to write image: %v", err)
+			}
+		}
+	}
+
+	err = os.WriteFile(filepath.Join(outputPath, utils.CleanDirName(volume.Title)+".md"), []byte(sb.String()), 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write markdown file: %v", err)
+	}
+	return nil
+}
+
+// writeFrontMatter 写入 YAML front matter
+func writeFrontMatter(sb *strings.Builder, volume *model.Volume, coverPath string) {
+	sb.WriteString("---\n")
+	fmt.Fprintf(sb, "title: %s\n", yamlString(volume.Title))
+	if authors := utils.Unique(volume.Authors); len(authors) > 0 {
+		sb.WriteString("authors:\n")
+		for _, author := range authors {
+			fmt.Fprintf(sb, "  - %s\n", yamlString(author))
+		}
+	}
+	fmt.Fprintf(sb, "series: %s\n", yamlString(volume.NovelTitle))
+	fmt.Fprintf(sb, "series_index: %d\n", volume.SeriesIdx)
+	if volume.Description != "" {
+		fmt.Fprintf(sb, "description: %s\n", yamlString(volume.Description))
+	}
+	if coverPath != "" {
+		fmt.Fprintf(sb, "cover: %s\n", yamlString(coverPath))
+	}
+	sb.WriteString("---\n\n")
+}
+
+// yamlString 返回 JSON 编码的字符串，它同时也是合法的 YAML 双引号字符串
+func yamlString(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
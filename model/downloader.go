@@ -1,5 +1,7 @@
 package model
 
+import "context"
+
 type ExtraFile struct {
 	Data         []byte
 	Path         string
@@ -10,6 +12,10 @@ type Downloader interface {
 	GetNovel(novelId int, skipChapter bool) (*Novel, error)
 	GetVolume(novelId int, volumeId int, skipChapter bool) (*Volume, error)
 	GetChapter(novelId int, volumeId int, chapterId int) (*Chapter, error)
+	// 以下方法与上面相同，但在 ctx 取消时尽快中止请求并返回 ctx.Err()
+	GetNovelContext(ctx context.Context, novelId int, skipChapter bool) (*Novel, error)
+	GetVolumeContext(ctx context.Context, novelId int, volumeId int, skipChapter bool) (*Volume, error)
+	GetChapterContext(ctx context.Context, novelId int, volumeId int, chapterId int) (*Chapter, error)
 	GetStyleCSS() string
 	GetExtraFiles() []ExtraFile
 	Close() error
@@ -152,9 +152,10 @@ type ManifestItem struct {
 }
 
 type Spine struct {
-	XMLName xml.Name    `xml:"spine"`
-	Toc     string      `xml:"toc,attr,omitempty"`
-	Items   []SpineItem `xml:"itemref"`
+	XMLName                  xml.Name    `xml:"spine"`
+	Toc                      string      `xml:"toc,attr,omitempty"`
+	PageProgressionDirection string      `xml:"page-progression-direction,attr,omitempty"`
+	Items                    []SpineItem `xml:"itemref"`
 }
 
 func (s *Spine) Marshal() (string, error) {
@@ -187,3 +188,39 @@ type GuideItem struct {
 	Type  string `xml:"type,attr"`
 	Link  string `xml:"href,attr"`
 }
+
+// NCX 是 EPUB 2 的目录文件 toc.ncx
+type NCX struct {
+	XMLName   xml.Name   `xml:"ncx"`
+	Xmlns     string     `xml:"xmlns,attr"`
+	Version   string     `xml:"version,attr"`
+	Metas     []NCXMeta  `xml:"head>meta"`
+	DocTitle  string     `xml:"docTitle>text"`
+	NavPoints []NavPoint `xml:"navMap>navPoint"`
+}
+
+func (n *NCX) Marshal() (string, error) {
+	xmlBytes, err := xml.Marshal(n)
+	if err != nil {
+		return "", err
+	}
+	return string(xmlBytes), nil
+}
+
+type NCXMeta struct {
+	Name    string `xml:"name,attr"`
+	Content string `xml:"content,attr"`
+}
+
+// NavPoint 是 toc.ncx 中的一个目录项，可以嵌套
+type NavPoint struct {
+	ID        string     `xml:"id,attr"`
+	PlayOrder int        `xml:"playOrder,attr"`
+	Label     string     `xml:"navLabel>text"`
+	Content   NavContent `xml:"content"`
+	Children  []NavPoint `xml:"navPoint"`
+}
+
+type NavContent struct {
+	Src string `xml:"src,attr"`
+}
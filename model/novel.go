@@ -26,6 +26,16 @@ type Volume struct {
 	Chapters    []*Chapter
 	NovelId     int
 	NovelTitle  string
+	// Language 为 BCP 47 语言标签，为空时视为 zh-CN
+	Language string `json:",omitempty"`
+}
+
+// Lang 返回卷的语言标签
+func (v *Volume) Lang() string {
+	if v.Language == "" {
+		return "zh-CN"
+	}
+	return v.Language
 }
 
 type Novel struct {
@@ -0,0 +1,82 @@
+package pdf
+
+import (
+	"bilinovel-downloader/htmltext"
+	"strings"
+)
+
+type blockKind int
+
+const (
+	blockParagraph blockKind = iota
+	blockHeading
+	blockImage
+)
+
+// block 为排版的基本单位，段落中的 \n 来自 <br>
+type block struct {
+	kind  blockKind
+	text  string
+	image string
+}
+
+type converter struct {
+	blocks []block
+	text   strings.Builder
+}
+
+func (c *converter) flush(kind blockKind) {
+	lines := strings.Split(c.text.String(), "\n")
+	c.text.Reset()
+	kept := lines[:0]
+	for _, line := range lines {
+		// 只去掉半角空格，全角空格是原文的段首缩进
+		if line = strings.Trim(line, " "); line != "" {
+			kept = append(kept, line)
+		}
+	}
+	if len(kept) > 0 {
+		c.blocks = append(c.blocks, block{kind: kind, text: strings.Join(kept, "\n")})
+	}
+}
+
+func (c *converter) Text(text string) {
+	c.text.WriteString(text)
+}
+
+func (c *converter) LineBreak() {
+	c.text.WriteString("\n")
+}
+
+func (c *converter) Image(src, alt string) {
+	if src == "" {
+		return
+	}
+	c.flush(blockParagraph)
+	c.blocks = append(c.blocks, block{kind: blockImage, image: src})
+}
+
+func (c *converter) StartBlock(tag string) {
+	c.flush(blockParagraph)
+}
+
+func (c *converter) EndBlock(tag string) {
+	if htmltext.HeadingLevel(tag) > 0 {
+		c.flush(blockHeading)
+	} else {
+		c.flush(blockParagraph)
+	}
+}
+
+func (c *converter) StartStyle(style htmltext.Style) {}
+func (c *converter) EndStyle(style htmltext.Style)   {}
+
+// convertHTML 将章节 HTML 转为段落、小标题与图片
+func convertHTML(htmlContent string) ([]block, error) {
+	c := &converter{}
+	if err := htmltext.Walk(htmlContent, c); err != nil {
+		return nil, err
+	}
+	c.flush(blockParagraph)
+	return c.blocks, nil
+}
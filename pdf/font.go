@@ -0,0 +1,175 @@
+package pdf
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+var psNameRegexp = regexp.MustCompile(`[^A-Za-z0-9-]`)
+
+type glyph struct {
+	index sfnt.GlyphIndex
+	// width 为千分之一字号单位的步进宽度
+	width float64
+}
+
+// ttFont 是以 Identity-H 编码嵌入的 TrueType 字体，文本按字形编号写入，
+// 记录用到的字形以生成 /W 宽度表与 ToUnicode 映射
+type ttFont struct {
+	data       []byte
+	font       *sfnt.Font
+	buf        sfnt.Buffer
+	unitsPerEm float64
+	name       string
+
+	glyphs map[rune]glyph
+	used   map[sfnt.GlyphIndex]rune
+}
+
+func parseFont(data []byte) (*ttFont, error) {
+	f, err := sfnt.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse font: %v", err)
+	}
+	t := &ttFont{
+		data:       data,
+		font:       f,
+		unitsPerEm: float64(f.UnitsPerEm()),
+		glyphs:     make(map[rune]glyph),
+		used:       make(map[sfnt.GlyphIndex]rune),
+	}
+	name, err := f.Name(&t.buf, sfnt.NameIDPostScript)
+	if name = psNameRegexp.ReplaceAllString(name, ""); err != nil || name == "" {
+		name = "EmbeddedFont"
+	}
+	t.name = name
+	return t, nil
+}
+
+// ppem 使度量结果以 26.6 定点数表示字体单位
+func (t *ttFont) ppem() fixed.Int26_6 {
+	return fixed.Int26_6(t.font.UnitsPerEm()) << 6
+}
+
+// scale 将 26.6 定点的字体单位换算为千分之一字号单位
+func (t *ttFont) scale(v fixed.Int26_6) float64 {
+	return float64(v) / 64 * 1000 / t.unitsPerEm
+}
+
+func (t *ttFont) glyph(r rune) glyph {
+	if g, ok := t.glyphs[r]; ok {
+		return g
+	}
+	g := glyph{}
+	// 字体中没有的字符使用 0 号字形（.notdef）
+	if index, err := t.font.GlyphIndex(&t.buf, r); err == nil {
+		g.index = index
+	}
+	if advance, err := t.font.GlyphAdvance(&t.buf, g.index, t.ppem(), font.HintingNone); err == nil {
+		g.width = t.scale(advance)
+	}
+	t.glyphs[r] = g
+	return g
+}
+
+// width 返回文本在 size 字号下的宽度（点）
+func (t *ttFont) width(s string, size float64) float64 {
+	w := 0.0
+	for _, r := range s {
+		w += t.glyph(r).width
+	}
+	return w * size / 1000
+}
+
+// encode 将文本编码为字形编号组成的十六进制字符串
+func (t *ttFont) encode(s string) string {
+	var b strings.Builder
+	b.WriteString("<")
+	for _, r := range s {
+		g := t.glyph(r)
+		if _, ok := t.used[g.index]; !ok {
+			t.used[g.index] = r
+		}
+		fmt.Fprintf(&b, "%04X", uint16(g.index))
+	}
+	b.WriteString(">")
+	return b.String()
+}
+
+// ascent 返回基线以上部分占字号的比例
+func (t *ttFont) ascent() float64 {
+	metrics, err := t.font.Metrics(&t.buf, t.ppem(), font.HintingNone)
+	if err != nil || metrics.Ascent+metrics.Descent <= 0 {
+		return 0.88
+	}
+	return float64(metrics.Ascent) / float64(metrics.Ascent+metrics.Descent)
+}
+
+// write 在 fontRef 写出 Type0 字体及其后代字体、字体描述与字体文件
+func (t *ttFont) write(w *writer, fontRef int) error {
+	cidFontRef, descriptorRef, fileRef, toUnicodeRef := w.alloc(), w.alloc(), w.alloc(), w.alloc()
+
+	w.object(fontRef, fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [%s] /ToUnicode %s >>",
+		t.name, ref(cidFontRef), ref(toUnicodeRef)))
+
+	indexes := make([]int, 0, len(t.used))
+	for index := range t.used {
+		indexes = append(indexes, int(index))
+	}
+	sort.Ints(indexes)
+	var widths strings.Builder
+	for _, index := range indexes {
+		g := t.glyph(t.used[sfnt.GlyphIndex(index)])
+		fmt.Fprintf(&widths, "%d [%s] ", index, num(g.width))
+	}
+	w.object(cidFontRef, fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s "+
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> "+
+		"/FontDescriptor %s /DW 1000 /W [%s] /CIDToGIDMap /Identity >>",
+		t.name, ref(descriptorRef), strings.TrimSpace(widths.String())))
+
+	metrics, err := t.font.Metrics(&t.buf, t.ppem(), font.HintingNone)
+	if err != nil {
+		return fmt.Errorf("failed to read font metrics: %v", err)
+	}
+	bounds, err := t.font.Bounds(&t.buf, t.ppem(), font.HintingNone)
+	if err != nil {
+		return fmt.Errorf("failed to read font bounds: %v", err)
+	}
+	// sfnt 的 y 轴向下，PDF 的 y 轴向上
+	w.object(descriptorRef, fmt.Sprintf("<< /Type /FontDescriptor /FontName /%s /Flags 4 /FontBBox [%s %s %s %s] "+
+		"/ItalicAngle 0 /Ascent %s /Descent %s /CapHeight %s /StemV 80 /FontFile2 %s >>",
+		t.name, num(t.scale(bounds.Min.X)), num(-t.scale(bounds.Max.Y)), num(t.scale(bounds.Max.X)), num(-t.scale(bounds.Min.Y)),
+		num(t.scale(metrics.Ascent)), num(-t.scale(metrics.Descent)), num(math.Abs(t.scale(metrics.CapHeight))), ref(fileRef)))
+
+	if err := w.stream(fileRef, fmt.Sprintf("/Length1 %d", len(t.data)), t.data); err != nil {
+		return err
+	}
+	return w.stream(toUnicodeRef, "", t.toUnicode(indexes))
+}
+
+// toUnicode 生成将字形编号映射回 Unicode 的 CMap，使 PDF 中的文字可以复制与搜索
+func (t *ttFont) toUnicode(indexes []int) []byte {
+	var b strings.Builder
+	b.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n" +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n" +
+		"/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n" +
+		"1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	// 每个 bfchar 段最多 100 项
+	for start := 0; start < len(indexes); start += 100 {
+		end := min(start+100, len(indexes))
+		fmt.Fprintf(&b, "%d beginbfchar\n", end-start)
+		for _, index := range indexes[start:end] {
+			fmt.Fprintf(&b, "<%04X> <%s>\n", index, utf16Hex(string(t.used[sfnt.GlyphIndex(index)])))
+		}
+		b.WriteString("endbfchar\n")
+	}
+	b.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n")
+	return []byte(b.String())
+}
@@ -0,0 +1,71 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	_ "golang.org/x/image/webp"
+)
+
+// writeImage 写出图片 XObject，返回对象编号与像素大小。
+// JPEG 原样嵌入，其余格式解码后以 RGB 压缩存储，带透明度时附加 /SMask
+func writeImage(w *writer, data []byte) (int, int, int, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to decode image: %v", err)
+	}
+
+	if format == "jpeg" {
+		colorSpace := "/DeviceRGB"
+		switch config.ColorModel {
+		case color.GrayModel:
+			colorSpace = "/DeviceGray"
+		case color.CMYKModel:
+			// Adobe 生成的 CMYK JPEG 通常是反相存储的
+			colorSpace = "/DeviceCMYK /Decode [1 0 1 0 1 0 1 0]"
+		}
+		imageRef := w.alloc()
+		w.rawStream(imageRef, fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace %s /BitsPerComponent 8 /Filter /DCTDecode",
+			config.Width, config.Height, colorSpace), data)
+		return imageRef, config.Width, config.Height, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to decode image: %v", err)
+	}
+	bounds := img.Bounds()
+	rgb := make([]byte, 0, bounds.Dx()*bounds.Dy()*3)
+	alpha := make([]byte, 0, bounds.Dx()*bounds.Dy())
+	opaque := true
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			rgb = append(rgb, c.R, c.G, c.B)
+			alpha = append(alpha, c.A)
+			if c.A != 0xff {
+				opaque = false
+			}
+		}
+	}
+
+	dict := fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8", bounds.Dx(), bounds.Dy())
+	if !opaque {
+		maskRef := w.alloc()
+		err := w.stream(maskRef, fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceGray /BitsPerComponent 8", bounds.Dx(), bounds.Dy()), alpha)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		dict += " /SMask " + ref(maskRef)
+	}
+	imageRef := w.alloc()
+	if err := w.stream(imageRef, dict, rgb); err != nil {
+		return 0, 0, 0, err
+	}
+	return imageRef, bounds.Dx(), bounds.Dy(), nil
+}
@@ -0,0 +1,88 @@
+package pdf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PageSize 为纸张大小，单位为点（1/72 英寸）
+type PageSize struct {
+	Width  float64
+	Height float64
+}
+
+// Margins 为页边距，单位为点
+type Margins struct {
+	Top    float64
+	Right  float64
+	Bottom float64
+	Left   float64
+}
+
+type Options struct {
+	PageSize PageSize
+	Margins  Margins
+	// FontSize 为正文字号，单位为点
+	FontSize float64
+}
+
+// PageSizes 为 --pdf-page-size 可用的纸张名称
+var PageSizes = map[string]PageSize{
+	"a4":     {mm(210), mm(297)},
+	"a5":     {mm(148), mm(210)},
+	"a6":     {mm(105), mm(148)},
+	"b5":     {mm(176), mm(250)},
+	"b6":     {mm(125), mm(176)},
+	"letter": {612, 792},
+}
+
+// DefaultOptions 返回 A5 纸张、15mm 页边距、11 点字号
+func DefaultOptions() Options {
+	return Options{
+		PageSize: PageSizes["a5"],
+		Margins:  Margins{mm(15), mm(15), mm(15), mm(15)},
+		FontSize: 11,
+	}
+}
+
+func mm(v float64) float64 {
+	return v * 72 / 25.4
+}
+
+// ParsePageSize 解析纸张名称（a4、a5、a6、b5、b6、letter）或以毫米为单位的 <宽>x<高>
+func ParsePageSize(s string) (PageSize, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if size, ok := PageSizes[s]; ok {
+		return size, nil
+	}
+	width, height, ok := strings.Cut(s, "x")
+	if ok {
+		w, errW := strconv.ParseFloat(strings.TrimSpace(width), 64)
+		h, errH := strconv.ParseFloat(strings.TrimSpace(height), 64)
+		if errW == nil && errH == nil && w > 0 && h > 0 {
+			return PageSize{mm(w), mm(h)}, nil
+		}
+	}
+	return PageSize{}, fmt.Errorf("invalid page size %q, expected a4, a5, a6, b5, b6, letter or <width>x<height> in mm", s)
+}
+
+// ParseMargins 解析以毫米为单位的页边距：一个值用于四边，或按 上,右,下,左 给出四个值
+func ParseMargins(s string) (Margins, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 1 && len(parts) != 4 {
+		return Margins{}, fmt.Errorf("invalid margins %q, expected one value or top,right,bottom,left in mm", s)
+	}
+	values := make([]float64, len(parts))
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || v < 0 {
+			return Margins{}, fmt.Errorf("invalid margins %q, expected one value or top,right,bottom,left in mm", s)
+		}
+		values[i] = mm(v)
+	}
+	if len(values) == 1 {
+		return Margins{values[0], values[0], values[0], values[0]}, nil
+	}
+	return Margins{values[0], values[1], values[2], values[3]}, nil
+}
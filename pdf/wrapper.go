@@ -0,0 +1,389 @@
+package pdf
+
+import (
+	"bilinovel-downloader/model"
+	"bilinovel-downloader/utils"
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	lineSpacing      = 1.7
+	paragraphSpacing = 0.3
+	titleScale       = 1.5
+	headingScale     = 1.2
+)
+
+var (
+	// noLineStart 中的标点不能出现在行首，溢出时悬挂在行末
+	noLineStart = "。，、．：；！？）」』】〕〉》”’…‥ー・々ゝゞぁぃぅぇぉっゃゅょァィゥェォッャュョ,.:;!?)]}%"
+	// noLineEnd 中的标点不能出现在行末，移到下一行开头
+	noLineEnd = "（「『【〔〈《“‘([{"
+)
+
+// PackVolumeToPDF 用 fontData 指定的 TrueType 字体排版整卷并输出为 <卷名>.pdf：
+// 封面为第一页，插图各占一页，每章一个书签
+func PackVolumeToPDF(volume *model.Volume, outputPath string, fontData []byte, opts Options) error {
+	data, err := Marshal(volume, fontData, opts)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(outputPath, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create output directory: %v", err)
+	}
+	err = os.WriteFile(filepath.Join(outputPath, utils.CleanDirName(volume.Title)+".pdf"), data, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write pdf file: %v", err)
+	}
+	return nil
+}
+
+type outline struct {
+	title string
+	page  int
+	top   float64
+}
+
+type xobject struct {
+	name   string
+	ref    int
+	width  float64
+	height float64
+}
+
+type page struct {
+	ref      int
+	contents bytes.Buffer
+	// numbered 为 false 的整页图片不加页码
+	numbered bool
+}
+
+// layout 自上而下排版，cursor 为当前行顶部的 y 坐标
+type layout struct {
+	w      *writer
+	font   *ttFont
+	opts   Options
+	ascent float64
+	pages  []*page
+	cursor float64
+	// images 以图片数据为键，相同的图片只写出一次
+	images   map[string]*xobject
+	xobjects []*xobject
+	outlines []outline
+}
+
+// Marshal 排版整卷并返回 PDF 文件内容
+func Marshal(volume *model.Volume, fontData []byte, opts Options) ([]byte, error) {
+	f, err := parseFont(fontData)
+	if err != nil {
+		return nil, err
+	}
+	if opts.FontSize <= 0 {
+		opts.FontSize = DefaultOptions().FontSize
+	}
+	textWidth := opts.PageSize.Width - opts.Margins.Left - opts.Margins.Right
+	textHeight := opts.PageSize.Height - opts.Margins.Top - opts.Margins.Bottom
+	if textWidth < opts.FontSize*titleScale || textHeight < opts.FontSize*titleScale*lineSpacing {
+		return nil, fmt.Errorf("margins leave no room for text on the page")
+	}
+
+	l := &layout{
+		w:      newWriter(),
+		font:   f,
+		opts:   opts,
+		ascent: f.ascent(),
+		images: make(map[string]*xobject),
+	}
+	if len(volume.Cover) > 0 {
+		l.imagePage(volume.Cover)
+	}
+	for _, chapter := range volume.Chapters {
+		if chapter.Content == nil {
+			return nil, fmt.Errorf("chapter %v has no content", chapter.Title)
+		}
+		blocks, err := convertHTML(chapter.Content.Html)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert chapter %v: %v", chapter.Title, err)
+		}
+		l.chapter(chapter, blocks)
+	}
+	if len(l.pages) == 0 {
+		l.newPage()
+	}
+	return l.finish(volume)
+}
+
+// chapter 排版一章：插图章节只输出整页图片，其余章节另起一页并以居中的标题开始
+func (l *layout) chapter(chapter *model.Chapter, blocks []block) {
+	hasImage := false
+	for _, b := range blocks {
+		if b.kind == blockImage {
+			hasImage = true
+		}
+	}
+	l.outlines = append(l.outlines, outline{title: chapter.Title, page: len(l.pages), top: l.opts.PageSize.Height})
+	if !utils.IsIllustration(chapter.Title) || !hasImage {
+		l.newPage()
+		l.cursor -= l.opts.FontSize * 2
+		l.paragraph(chapter.Title, l.opts.FontSize*titleScale, true)
+		l.cursor -= l.opts.FontSize * 2
+	}
+	for _, b := range blocks {
+		switch b.kind {
+		case blockImage:
+			if data, ok := chapter.Content.Images[b.image]; ok {
+				l.imagePage(data)
+			}
+		case blockHeading:
+			l.ensurePage()
+			l.cursor -= l.opts.FontSize
+			l.paragraph(b.text, l.opts.FontSize*headingScale, false)
+		default:
+			l.ensurePage()
+			l.paragraph(b.text, l.opts.FontSize, false)
+		}
+	}
+}
+
+func (l *layout) current() *page {
+	return l.pages[len(l.pages)-1]
+}
+
+func (l *layout) newPage() {
+	l.pages = append(l.pages, &page{ref: l.w.alloc(), numbered: true})
+	l.cursor = l.opts.PageSize.Height - l.opts.Margins.Top
+}
+
+// ensurePage 在整页图片之后继续排正文时另起一页
+func (l *layout) ensurePage() {
+	if len(l.pages) == 0 || !l.current().numbered {
+		l.newPage()
+	}
+}
+
+// imagePage 将图片等比缩放到整页并居中，无法解码的图片被跳过
+func (l *layout) imagePage(data []byte) {
+	img, ok := l.images[string(data)]
+	if !ok {
+		imageRef, width, height, err := writeImage(l.w, data)
+		if err != nil {
+			log.Printf("Skipping image in pdf: %v", err)
+			return
+		}
+		img = &xobject{name: fmt.Sprintf("Im%d", len(l.xobjects)+1), ref: imageRef, width: float64(width), height: float64(height)}
+		l.images[string(data)] = img
+		l.xobjects = append(l.xobjects, img)
+	}
+
+	pageWidth, pageHeight := l.opts.PageSize.Width, l.opts.PageSize.Height
+	scale := min(pageWidth/img.width, pageHeight/img.height)
+	width, height := img.width*scale, img.height*scale
+	l.pages = append(l.pages, &page{ref: l.w.alloc()})
+	fmt.Fprintf(&l.current().contents, "q %s 0 0 %s %s %s cm /%s Do Q\n",
+		num(width), num(height), num((pageWidth-width)/2), num((pageHeight-height)/2), img.name)
+}
+
+// paragraph 按版心宽度断行，空间不足时换页；\n 处强制换行
+func (l *layout) paragraph(text string, size float64, center bool) {
+	lineHeight := size * lineSpacing
+	width := l.opts.PageSize.Width - l.opts.Margins.Left - l.opts.Margins.Right
+	for _, segment := range strings.Split(text, "\n") {
+		for _, line := range l.breakLines(segment, size, width) {
+			if l.cursor-lineHeight < l.opts.Margins.Bottom {
+				l.newPage()
+			}
+			x := l.opts.Margins.Left
+			if center {
+				x += max(0, (width-l.font.width(line, size))/2)
+			}
+			baseline := l.cursor - (lineHeight-size)/2 - size*l.ascent
+			fmt.Fprintf(&l.current().contents, "BT /F1 %s Tf %s %s Td %s Tj ET\n", num(size), num(x), num(baseline), l.font.encode(line))
+			l.cursor -= lineHeight
+		}
+	}
+	l.cursor -= size * paragraphSpacing
+}
+
+// tokenize 将连续的半角字母、数字与符号作为一个单词，其余字符各自成为一个断行单位
+func tokenize(text string) []string {
+	var tokens []string
+	start := -1
+	for i, r := range text {
+		if r < utf8.RuneSelf && r != ' ' {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			tokens = append(tokens, text[start:i])
+			start = -1
+		}
+		tokens = append(tokens, string(r))
+	}
+	if start >= 0 {
+		tokens = append(tokens, text[start:])
+	}
+	return tokens
+}
+
+// breakLines 将一行文本按 width 断开：行首禁则标点悬挂在上一行末尾，
+// 行末禁则标点移到下一行，过长的单词按字符拆开
+func (l *layout) breakLines(text string, size float64, width float64) []string {
+	var lines []string
+	tokens := tokenize(text)
+	line, lineWidth := "", 0.0
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		tokenWidth := l.font.width(token, size)
+		if line == "" && token == " " {
+			continue
+		}
+		if lineWidth+tokenWidth <= width {
+			line += token
+			lineWidth += tokenWidth
+			continue
+		}
+		if token == " " {
+			lines = append(lines, line)
+			line, lineWidth = "", 0
+			continue
+		}
+		if line != "" && onlyRunesIn(token, noLineStart) {
+			for i+1 < len(tokens) && onlyRunesIn(tokens[i+1], noLineStart) {
+				i++
+				token += tokens[i]
+			}
+			lines = append(lines, line+token)
+			line, lineWidth = "", 0
+			continue
+		}
+		if tokenWidth > width && utf8.RuneCountInString(token) > 1 {
+			var runes []string
+			for _, r := range token {
+				runes = append(runes, string(r))
+			}
+			tokens = append(tokens[:i], append(runes, tokens[i+1:]...)...)
+			i--
+			continue
+		}
+		carry := ""
+		for line != "" {
+			last, n := utf8.DecodeLastRuneInString(line)
+			if !strings.ContainsRune(noLineEnd, last) || n == len(line) {
+				break
+			}
+			carry = string(last) + carry
+			line = line[:len(line)-n]
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+		line = carry + token
+		lineWidth = l.font.width(line, size)
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func onlyRunesIn(s string, set string) bool {
+	for _, r := range s {
+		if !strings.ContainsRune(set, r) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// finish 写出页面、书签、字体与文档信息
+func (l *layout) finish(volume *model.Volume) ([]byte, error) {
+	w := l.w
+	pagesRef, resourcesRef, fontRef := w.alloc(), w.alloc(), w.alloc()
+
+	kids := make([]string, 0, len(l.pages))
+	for i, p := range l.pages {
+		if p.numbered {
+			number := fmt.Sprint(i + 1)
+			size := l.opts.FontSize * 0.8
+			x := (l.opts.PageSize.Width - l.font.width(number, size)) / 2
+			y := max(l.opts.Margins.Bottom/2-size/2, size/2)
+			fmt.Fprintf(&p.contents, "BT /F1 %s Tf %s %s Td %s Tj ET\n", num(size), num(x), num(y), l.font.encode(number))
+		}
+		contentsRef := w.alloc()
+		if err := w.stream(contentsRef, "", p.contents.Bytes()); err != nil {
+			return nil, err
+		}
+		w.object(p.ref, fmt.Sprintf("<< /Type /Page /Parent %s /MediaBox [0 0 %s %s] /Resources %s /Contents %s >>",
+			ref(pagesRef), num(l.opts.PageSize.Width), num(l.opts.PageSize.Height), ref(resourcesRef), ref(contentsRef)))
+		kids = append(kids, ref(p.ref))
+	}
+	w.object(pagesRef, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids)))
+
+	var xobjects strings.Builder
+	for _, img := range l.xobjects {
+		fmt.Fprintf(&xobjects, "/%s %s ", img.name, ref(img.ref))
+	}
+	w.object(resourcesRef, fmt.Sprintf("<< /Font << /F1 %s >> /XObject << %s>> >>", ref(fontRef), xobjects.String()))
+	if err := l.font.write(w, fontRef); err != nil {
+		return nil, err
+	}
+
+	catalog := fmt.Sprintf("/Type /Catalog /Pages %s", ref(pagesRef))
+	if outlinesRef := l.writeOutlines(); outlinesRef > 0 {
+		catalog += fmt.Sprintf(" /Outlines %s /PageMode /UseOutlines", ref(outlinesRef))
+	}
+	rootRef := w.alloc()
+	w.object(rootRef, "<< "+catalog+" >>")
+
+	info := fmt.Sprintf("/Title %s /Creator (bilinovel-downloader) /Producer (bilinovel-downloader) /CreationDate (D:%s)",
+		textString(volume.Title), utils.BuildTime().Format("20060102150405Z"))
+	if authors := utils.Unique(volume.Authors); len(authors) > 0 {
+		info += " /Author " + textString(strings.Join(authors, ", "))
+	}
+	if volume.NovelTitle != "" {
+		info += " /Subject " + textString(volume.NovelTitle)
+	}
+	infoRef := w.alloc()
+	w.object(infoRef, "<< "+info+" >>")
+
+	return w.finish(rootRef, infoRef)
+}
+
+// writeOutlines 为每章写出一个指向其第一页的书签，没有章节时返回 0
+func (l *layout) writeOutlines() int {
+	var items []outline
+	for _, o := range l.outlines {
+		// 章节没有产生任何页面（例如插图全部无法解码）时指向最后一页
+		o.page = min(o.page, len(l.pages)-1)
+		items = append(items, o)
+	}
+	if len(items) == 0 {
+		return 0
+	}
+	rootRef := l.w.alloc()
+	refs := make([]int, len(items))
+	for i := range items {
+		refs[i] = l.w.alloc()
+	}
+	for i, item := range items {
+		dict := fmt.Sprintf("/Title %s /Parent %s /Dest [%s /XYZ 0 %s null]",
+			textString(item.title), ref(rootRef), ref(l.pages[item.page].ref), num(item.top))
+		if i > 0 {
+			dict += " /Prev " + ref(refs[i-1])
+		}
+		if i+1 < len(items) {
+			dict += " /Next " + ref(refs[i+1])
+		}
+		l.w.object(refs[i], "<< "+dict+" >>")
+	}
+	l.w.object(rootRef, fmt.Sprintf("<< /Type /Outlines /First %s /Last %s /Count %d >>", ref(refs[0]), ref(refs[len(refs)-1]), len(refs)))
+	return rootRef
+}
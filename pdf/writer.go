@@ -0,0 +1,99 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// writer 按对象编号记录偏移量，最后写出交叉引用表
+type writer struct {
+	buf     bytes.Buffer
+	offsets []int
+}
+
+func newWriter() *writer {
+	w := &writer{}
+	// 第二行的高位字节提示这是二进制文件
+	w.buf.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+	return w
+}
+
+// alloc 预留一个对象编号，对象可以在之后任意时刻写出
+func (w *writer) alloc() int {
+	w.offsets = append(w.offsets, -1)
+	return len(w.offsets)
+}
+
+func (w *writer) object(ref int, body string) {
+	w.offsets[ref-1] = w.buf.Len()
+	fmt.Fprintf(&w.buf, "%d 0 obj\n%s\nendobj\n", ref, body)
+}
+
+// stream 以 FlateDecode 压缩写出流对象，dict 为除 /Filter 与 /Length 以外的字典内容
+func (w *writer) stream(ref int, dict string, data []byte) error {
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	if _, err := zw.Write(data); err != nil {
+		return fmt.Errorf("failed to compress stream: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to compress stream: %v", err)
+	}
+	w.rawStream(ref, dict+" /Filter /FlateDecode", compressed.Bytes())
+	return nil
+}
+
+// rawStream 原样写出流对象，dict 需要自带 /Filter
+func (w *writer) rawStream(ref int, dict string, data []byte) {
+	w.offsets[ref-1] = w.buf.Len()
+	fmt.Fprintf(&w.buf, "%d 0 obj\n<< %s /Length %d >>\nstream\n", ref, strings.TrimSpace(dict), len(data))
+	w.buf.Write(data)
+	w.buf.WriteString("\nendstream\nendobj\n")
+}
+
+// finish 写出交叉引用表与 trailer，返回整个文件
+func (w *writer) finish(root int, info int) ([]byte, error) {
+	for i, offset := range w.offsets {
+		if offset < 0 {
+			return nil, fmt.Errorf("object %d was allocated but never written", i+1)
+		}
+	}
+	xref := w.buf.Len()
+	fmt.Fprintf(&w.buf, "xref\n0 %d\n0000000000 65535 f \n", len(w.offsets)+1)
+	for _, offset := range w.offsets {
+		fmt.Fprintf(&w.buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&w.buf, "trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(w.offsets)+1, root, info, xref)
+	return w.buf.Bytes(), nil
+}
+
+func ref(n int) string {
+	return strconv.Itoa(n) + " 0 R"
+}
+
+// num 格式化坐标等数值，保留两位小数并去掉多余的 0
+func num(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 2, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" || s == "" {
+		return "0"
+	}
+	return s
+}
+
+// textString 将文本编码为带 BOM 的 UTF-16BE 十六进制字符串，用于书签与文档信息
+func textString(s string) string {
+	return "<FEFF" + utf16Hex(s) + ">"
+}
+
+func utf16Hex(s string) string {
+	var b strings.Builder
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", u)
+	}
+	return b.String()
+}
@@ -0,0 +1,64 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+)
+
+// Config 描述一个可在配置文件中声明的处理器，例如：
+//
+//	[
+//	  {"type": "remove", "selectors": [".ad", "div.banner"]},
+//	  {"type": "replace", "pattern": "嗶哩輕小說", "replacement": ""},
+//	  {"type": "normalize-paragraphs"}
+//	]
+type Config struct {
+	Type        string   `json:"type"`
+	Selectors   []string `json:"selectors,omitempty"`
+	Pattern     string   `json:"pattern,omitempty"`
+	Replacement string   `json:"replacement,omitempty"`
+}
+
+// New 根据配置创建处理器
+func New(cfg Config) (Processor, error) {
+	switch cfg.Type {
+	case "remove":
+		if len(cfg.Selectors) == 0 {
+			return nil, fmt.Errorf("remove processor requires selectors")
+		}
+		return &RemoveElements{Selectors: cfg.Selectors}, nil
+	case "replace":
+		pattern, err := regexp.Compile(cfg.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile pattern %q: %v", cfg.Pattern, err)
+		}
+		return &RegexReplace{Pattern: pattern, Replacement: cfg.Replacement}, nil
+	case "normalize-paragraphs":
+		return &NormalizeParagraphs{}, nil
+	default:
+		return nil, fmt.Errorf("unknown processor type: %q", cfg.Type)
+	}
+}
+
+// LoadConfig 从 JSON 文件读取按顺序排列的处理器列表
+func LoadConfig(configPath string) ([]Processor, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read processor config: %v", err)
+	}
+	var configs []Config
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("failed to parse processor config: %v", err)
+	}
+	processors := make([]Processor, 0, len(configs))
+	for i, cfg := range configs {
+		p, err := New(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid processor #%d: %v", i+1, err)
+		}
+		processors = append(processors, p)
+	}
+	return processors, nil
+}
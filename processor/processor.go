@@ -0,0 +1,114 @@
+package processor
+
+import (
+	"bilinovel-downloader/model"
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// Page 是章节中的一页，处理器直接修改 Content
+type Page struct {
+	Chapter *model.Chapter
+	Number  int
+	// Html 为去乱序前的原始页面，可用于判断页面特征
+	Html    string
+	Content *goquery.Selection
+}
+
+// Processor 对章节的每一页内容做后处理，按流水线顺序执行。
+// 多个章节会并发处理，实现需保证并发安全
+type Processor interface {
+	Name() string
+	Process(ctx context.Context, page *Page) error
+}
+
+// Func 将普通函数包装为 Processor
+func Func(name string, fn func(ctx context.Context, page *Page) error) Processor {
+	return &funcProcessor{name: name, fn: fn}
+}
+
+type funcProcessor struct {
+	name string
+	fn   func(ctx context.Context, page *Page) error
+}
+
+func (p *funcProcessor) Name() string {
+	return p.name
+}
+
+func (p *funcProcessor) Process(ctx context.Context, page *Page) error {
+	return p.fn(ctx, page)
+}
+
+// RemoveElements 删除匹配任一选择器的元素，用于去除广告等
+type RemoveElements struct {
+	Selectors []string
+}
+
+func (p *RemoveElements) Name() string {
+	return "remove"
+}
+
+func (p *RemoveElements) Process(ctx context.Context, page *Page) error {
+	for _, selector := range p.Selectors {
+		page.Content.Find(selector).Remove()
+	}
+	return nil
+}
+
+// RegexReplace 对文本节点做正则替换，不会改动标签和属性
+type RegexReplace struct {
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+func (p *RegexReplace) Name() string {
+	return "replace"
+}
+
+func (p *RegexReplace) Process(ctx context.Context, page *Page) error {
+	for _, node := range page.Content.Nodes {
+		replaceText(node, p.Pattern, p.Replacement)
+	}
+	return nil
+}
+
+func replaceText(node *html.Node, pattern *regexp.Regexp, replacement string) {
+	if node.Type == html.TextNode {
+		node.Data = pattern.ReplaceAllString(node.Data, replacement)
+		return
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		replaceText(child, pattern, replacement)
+	}
+}
+
+// NormalizeParagraphs 删除空段落，并把连续的 <br> 合并为一个
+type NormalizeParagraphs struct{}
+
+func (p *NormalizeParagraphs) Name() string {
+	return "normalize-paragraphs"
+}
+
+func (p *NormalizeParagraphs) Process(ctx context.Context, page *Page) error {
+	page.Content.Find("p").Each(func(i int, s *goquery.Selection) {
+		if s.Find("img").Length() == 0 && strings.TrimSpace(strings.Trim(s.Text(), "　 ")) == "" {
+			s.Remove()
+		}
+	})
+	page.Content.Find("br").Each(func(i int, s *goquery.Selection) {
+		// 跳过两个 <br> 之间的空白文本
+		prev := s.Nodes[0].PrevSibling
+		for prev != nil && prev.Type == html.TextNode && strings.TrimSpace(prev.Data) == "" {
+			prev = prev.PrevSibling
+		}
+		if prev != nil && prev.Type == html.ElementNode && prev.Data == "br" {
+			s.Remove()
+		}
+	})
+	return nil
+}
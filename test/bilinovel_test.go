@@ -2,58 +2,323 @@ package test
 
 import (
 	"bilinovel-downloader/downloader/bilinovel"
+	"bilinovel-downloader/utils"
+	"bytes"
+	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
-func TestBilinovel_GetNovel(t *testing.T) {
-	bilinovel, err := bilinovel.New()
-	bilinovel.SetTextOnly(true)
+var (
+	record = flag.Bool("record", false, "record fixtures from the live site into testdata/bilinovel")
+	update = flag.Bool("update", false, "update golden files in testdata/golden")
+)
+
+const (
+	fixtureDir = "testdata/bilinovel"
+	goldenDir  = "testdata/golden"
+
+	// 夹具中的小说、卷、章节 ID
+	testNovelId   = 9999
+	testVolumeId  = 99901
+	testChapterId = 1002
+	// 段落被 chapterlog.js 打乱的章节
+	testShuffledChapterId = 1003
+
+	baseURLPlaceholder = "{{base}}"
+)
+
+// newTestBilinovel 默认从本地 httptest 服务器回放夹具；带 -record 时访问真实站点并录制
+func newTestBilinovel(t *testing.T, opts ...bilinovel.Option) (*bilinovel.Bilinovel, string) {
+	t.Helper()
+
+	client := utils.NewRestyClient(50)
+	baseURL := bilinovel.DefaultBaseURL
+	if *record {
+		client.Record(fixtureDir)
+	} else {
+		server := httptest.NewServer(utils.NewFixtureHandler(fixtureDir))
+		t.Cleanup(server.Close)
+		if err := client.Replay(server.URL); err != nil {
+			t.Fatalf("failed to enable replay: %v", err)
+		}
+		baseURL = server.URL
+	}
+
+	opts = append([]bilinovel.Option{bilinovel.WithRestyClient(client), bilinovel.WithBaseURL(baseURL)}, opts...)
+	b, err := bilinovel.New(opts...)
 	if err != nil {
 		t.Fatalf("failed to create bilinovel: %v", err)
 	}
-	novel, err := bilinovel.GetNovel(4519, false)
+	t.Cleanup(func() {
+		_ = b.Close()
+	})
+	return b, baseURL
+}
+
+// runDeobfuscateModes 分别用纯 Go 与 Chrome 还原章节，两者结果应与同一份 golden 一致
+func runDeobfuscateModes(t *testing.T, fn func(t *testing.T, opts ...bilinovel.Option)) {
+	t.Run("no-browser", func(t *testing.T) {
+		fn(t, bilinovel.WithNoBrowser(true))
+	})
+	t.Run("chrome", func(t *testing.T) {
+		requireBrowser(t)
+		fn(t, bilinovel.WithNoBrowser(false))
+	})
+}
+
+// hasBrowser 返回是否安装了 chromedp 能找到的 Chrome
+func hasBrowser() bool {
+	for _, name := range []string{"google-chrome", "google-chrome-stable", "chromium", "chromium-browser", "headless-shell", "chrome"} {
+		if _, err := exec.LookPath(name); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// requireBrowser 章节去乱序依赖 Chrome，未安装时跳过
+func requireBrowser(t *testing.T) {
+	t.Helper()
+	if !hasBrowser() {
+		t.Skip("chrome not found, skipping chapter de-shuffling")
+	}
+}
+
+// assertGolden 将 v 序列化为 JSON 后与 testdata/golden/<name>.json 比较，站点地址替换为占位符
+func assertGolden(t *testing.T, name string, v any, baseURL string) {
+	t.Helper()
+
+	got, err := json.MarshalIndent(v, "", "  ")
 	if err != nil {
-		t.Fatalf("failed to get novel: %v", err)
+		t.Fatalf("failed to marshal %v: %v", name, err)
+	}
+	got = []byte(strings.ReplaceAll(string(got), baseURL, baseURLPlaceholder))
+
+	goldenPath := filepath.Join(goldenDir, name+".json")
+	if *update || *record {
+		if err := os.MkdirAll(goldenDir, 0755); err != nil {
+			t.Fatalf("failed to create golden directory: %v", err)
+		}
+		if err := os.WriteFile(goldenPath, got, 0644); err != nil {
+			t.Fatalf("failed to write golden file: %v", err)
+		}
+		return
 	}
-	jsonBytes, err := json.Marshal(novel)
+
+	want, err := os.ReadFile(goldenPath)
 	if err != nil {
-		t.Fatalf("failed to marshal novel: %v", err)
+		t.Fatalf("failed to read golden file: %v", err)
+	}
+	if !bytes.Equal(bytes.TrimSpace(got), bytes.TrimSpace(want)) {
+		t.Errorf("%v does not match golden file %v\ngot:\n%s\nwant:\n%s", name, goldenPath, got, want)
 	}
-	fmt.Println(string(jsonBytes))
 }
 
-func TestBilinovel_GetVolume(t *testing.T) {
-	bilinovel, err := bilinovel.New()
-	bilinovel.SetTextOnly(true)
+func TestBilinovel_GetNovel(t *testing.T) {
+	b, baseURL := newTestBilinovel(t)
+	novel, err := b.GetNovel(testNovelId, true)
 	if err != nil {
-		t.Fatalf("failed to create bilinovel: %v", err)
+		t.Fatalf("failed to get novel: %v", err)
 	}
-	volume, err := bilinovel.GetVolume(1410, 52748, false)
+	assertGolden(t, "novel", novel, baseURL)
+}
+
+func TestBilinovel_GetVolume(t *testing.T) {
+	b, baseURL := newTestBilinovel(t)
+	volume, err := b.GetVolume(testNovelId, testVolumeId, true)
 	if err != nil {
 		t.Fatalf("failed to get volume: %v", err)
 	}
-	jsonBytes, err := json.Marshal(volume)
-	if err != nil {
-		t.Fatalf("failed to marshal volume: %v", err)
-	}
-	fmt.Println(string(jsonBytes))
+	assertGolden(t, "volume", volume, baseURL)
+}
+
+func TestBilinovel_GetVolumeWithChapters(t *testing.T) {
+	runDeobfuscateModes(t, func(t *testing.T, opts ...bilinovel.Option) {
+		b, baseURL := newTestBilinovel(t, opts...)
+		volume, err := b.GetVolume(testNovelId, testVolumeId, false)
+		if err != nil {
+			t.Fatalf("failed to get volume: %v", err)
+		}
+		assertGolden(t, "volume-chapters", volume, baseURL)
+	})
 }
 
 func TestBilinovel_GetChapter(t *testing.T) {
-	bilinovel, err := bilinovel.New()
-	bilinovel.SetTextOnly(true)
+	runDeobfuscateModes(t, func(t *testing.T, opts ...bilinovel.Option) {
+		b, baseURL := newTestBilinovel(t, opts...)
+		chapter, err := b.GetChapter(testNovelId, testVolumeId, testChapterId)
+		if err != nil {
+			t.Fatalf("failed to get chapter: %v", err)
+		}
+		assertGolden(t, "chapter", chapter, baseURL)
+	})
+}
+
+func TestBilinovel_GetShuffledChapter(t *testing.T) {
+	runDeobfuscateModes(t, func(t *testing.T, opts ...bilinovel.Option) {
+		b, baseURL := newTestBilinovel(t, opts...)
+		chapter, err := b.GetChapter(testNovelId, testVolumeId, testShuffledChapterId)
+		if err != nil {
+			t.Fatalf("failed to get chapter: %v", err)
+		}
+		assertGolden(t, "chapter-shuffled", chapter, baseURL)
+	})
+}
+
+func TestBilinovel_NoBrowserFallsBackToChrome(t *testing.T) {
+	if *record {
+		t.Skip("fallback is only tested against fixtures")
+	}
+
+	// 站点换成无法解析的脚本格式时，没有 Chrome 必须报错，不能输出仍被打乱的段落；
+	// 有 Chrome 时改由浏览器还原，结果与正常情况相同
+	packed := `eval(function(p,a,c,k,e,d){return p}('0 1',2,2,'ReadParams|chapterid'.split('|'),0,{}))`
+	for _, tc := range []struct {
+		name    string
+		handler func(fixtures http.Handler) http.HandlerFunc
+		wantErr string
+	}{
+		{
+			name:    "packed",
+			handler: serveChapterlog(packed),
+			wantErr: "unrecognized chapterlog script",
+		},
+		{
+			name:    "empty",
+			handler: serveChapterlog(""),
+			wantErr: "unrecognized chapterlog script",
+		},
+		{
+			name: "missing",
+			handler: func(fixtures http.Handler) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					if strings.HasSuffix(r.URL.Path, "/chapterlog.js") {
+						http.NotFound(w, r)
+						return
+					}
+					fixtures.ServeHTTP(w, r)
+				}
+			},
+			wantErr: "failed to get chapterlog script: 404",
+		},
+		{
+			// 脚本改名后页面仍被打乱，但找不到 chapterlog.js
+			name: "renamed",
+			handler: func(fixtures http.Handler) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					if strings.HasSuffix(r.URL.Path, "/chapterorder.js") {
+						r.URL.Path = strings.TrimSuffix(r.URL.Path, "chapterorder.js") + "chapterlog.js"
+						fixtures.ServeHTTP(w, r)
+						return
+					}
+					rec := httptest.NewRecorder()
+					fixtures.ServeHTTP(rec, r)
+					body := rec.Body.String()
+					if strings.HasSuffix(r.URL.Path, ".html") {
+						body = strings.ReplaceAll(body, "chapterlog.js", "chapterorder.js")
+					}
+					w.Header().Set("Content-Type", rec.Header().Get("Content-Type"))
+					w.WriteHeader(rec.Code)
+					_, _ = w.Write([]byte(body))
+				}
+			},
+			wantErr: "chapterlog script not found",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(tc.handler(utils.NewFixtureHandler(fixtureDir)))
+			t.Cleanup(server.Close)
+
+			client := utils.NewRestyClient(50)
+			if err := client.Replay(server.URL); err != nil {
+				t.Fatalf("failed to enable replay: %v", err)
+			}
+			b, err := bilinovel.New(bilinovel.WithRestyClient(client), bilinovel.WithBaseURL(server.URL), bilinovel.WithNoBrowser(true))
+			if err != nil {
+				t.Fatalf("failed to create bilinovel: %v", err)
+			}
+			t.Cleanup(func() {
+				_ = b.Close()
+			})
+
+			chapter, err := b.GetChapter(testNovelId, testVolumeId, testShuffledChapterId)
+			if !hasBrowser() {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected %q without chrome, got %v", tc.wantErr, err)
+				}
+				if chapter != nil {
+					t.Errorf("expected no chapter on error, got %+v", chapter)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to get chapter: %v", err)
+			}
+			assertGolden(t, "chapter-shuffled", chapter, server.URL)
+		})
+	}
+}
+
+// serveChapterlog 用 script 替换夹具中的 chapterlog.js
+func serveChapterlog(script string) func(fixtures http.Handler) http.HandlerFunc {
+	return func(fixtures http.Handler) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasSuffix(r.URL.Path, "/chapterlog.js") {
+				_, _ = w.Write([]byte(script))
+				return
+			}
+			fixtures.ServeHTTP(w, r)
+		}
+	}
+}
+
+func TestBilinovel_MirrorFailover(t *testing.T) {
+	if *record {
+		t.Skip("mirror failover is only tested against fixtures")
+	}
+
+	blocked := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "blocked", http.StatusForbidden)
+	}))
+	t.Cleanup(blocked.Close)
+	fixtures := httptest.NewServer(utils.NewFixtureHandler(fixtureDir))
+	t.Cleanup(fixtures.Close)
+
+	client := utils.NewRestyClient(50)
+	if err := client.Replay(fixtures.URL); err != nil {
+		t.Fatalf("failed to enable replay: %v", err)
+	}
+	b, err := bilinovel.New(bilinovel.WithRestyClient(client), bilinovel.WithMirrors(blocked.URL, fixtures.URL))
 	if err != nil {
 		t.Fatalf("failed to create bilinovel: %v", err)
 	}
-	chapter, err := bilinovel.GetChapter(3095, 154930, 154933)
+	t.Cleanup(func() {
+		_ = b.Close()
+	})
+
+	volume, err := b.GetVolume(testNovelId, testVolumeId, true)
 	if err != nil {
-		t.Fatalf("failed to get chapter: %v", err)
+		t.Fatalf("failed to get volume: %v", err)
 	}
-	jsonBytes, err := json.Marshal(chapter)
-	if err != nil {
-		t.Fatalf("failed to marshal chapter: %v", err)
+	assertGolden(t, "volume", volume, fixtures.URL)
+}
+
+func TestBilinovel_GetVolumeContextCanceled(t *testing.T) {
+	b, _ := newTestBilinovel(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := b.GetVolumeContext(ctx, testNovelId, testVolumeId, true)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
 	}
-	fmt.Println(string(jsonBytes))
 }
@@ -0,0 +1,192 @@
+package test
+
+import (
+	"bilinovel-downloader/cache"
+	"bilinovel-downloader/downloader/bilinovel"
+	"bilinovel-downloader/model"
+	"bilinovel-downloader/utils"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// pngData 是足以被识别为 PNG 的最短数据
+var pngData = []byte("\x89PNG\r\n\x1a\n")
+
+func TestCache_ChapterRoundTrip(t *testing.T) {
+	c := cache.New(t.TempDir(), false)
+	imageFilename := cache.ImageFilename("https://img3.readpai.com/3/9999/99901/1001.png")
+	chapter := &model.Chapter{
+		Id:       1001,
+		NovelId:  9999,
+		VolumeId: 99901,
+		Title:    "插圖",
+		Content: &model.ChaperContent{
+			Html:   `<img src="` + imageFilename + `"/>`,
+			Images: map[string][]byte{imageFilename: pngData},
+		},
+	}
+	if err := c.SaveChapter(chapter); err != nil {
+		t.Fatalf("failed to save chapter: %v", err)
+	}
+
+	got, ok := c.LoadChapter(9999, 99901, 1001)
+	if !ok {
+		t.Fatalf("expected cached chapter")
+	}
+	if got.Title != chapter.Title || got.Content.Html != chapter.Content.Html {
+		t.Errorf("cached chapter mismatch: %+v", got)
+	}
+	if !bytes.Equal(got.Content.Images[imageFilename], pngData) {
+		t.Errorf("cached image mismatch: %q", got.Content.Images[imageFilename])
+	}
+
+	if _, ok := cache.New(c.Dir(), true).LoadChapter(9999, 99901, 1001); ok {
+		t.Errorf("expected refresh to ignore cached chapter")
+	}
+}
+
+func TestCache_RejectsNonImage(t *testing.T) {
+	c := cache.New(t.TempDir(), false)
+	imageFilename := cache.ImageFilename("https://img3.readpai.com/3/9999/99901/1001.png")
+	chapter := &model.Chapter{
+		Id:       1001,
+		NovelId:  9999,
+		VolumeId: 99901,
+		Content: &model.ChaperContent{
+			Html:   `<img src="` + imageFilename + `"/>`,
+			Images: map[string][]byte{imageFilename: []byte("<html>404 Not Found</html>")},
+		},
+	}
+	if err := c.SaveChapter(chapter); err == nil {
+		t.Errorf("expected error for non-image data")
+	}
+	if _, ok := c.LoadImage(imageFilename); ok {
+		t.Errorf("expected non-image data not to be cached")
+	}
+
+	// 旧版本写入的错误页面视为未命中
+	if err := os.MkdirAll(filepath.Join(c.Dir(), "images"), 0755); err != nil {
+		t.Fatalf("failed to create images directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(c.Dir(), "images", imageFilename), []byte("<html></html>"), 0644); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+	if _, ok := c.LoadImage(imageFilename); ok {
+		t.Errorf("expected cached error page to be a miss")
+	}
+}
+
+func TestCache_MissingImageIsMiss(t *testing.T) {
+	c := cache.New(t.TempDir(), false)
+	chapter := &model.Chapter{
+		Id:       1002,
+		NovelId:  9999,
+		VolumeId: 99901,
+		Content: &model.ChaperContent{
+			// 图片下载失败时 HTML 中有引用但 Images 中没有
+			Html: `<p>text</p><img src="` + cache.ImageFilename("https://img3.readpai.com/missing.png") + `"/>`,
+		},
+	}
+	if err := c.SaveChapter(chapter); err != nil {
+		t.Fatalf("failed to save chapter: %v", err)
+	}
+	if _, ok := c.LoadChapter(9999, 99901, 1002); ok {
+		t.Errorf("expected chapter with missing image to be a cache miss")
+	}
+}
+
+func TestBilinovel_CachedImagesAreNotRefetched(t *testing.T) {
+	if *record {
+		t.Skip("cache is only tested against fixtures")
+	}
+
+	var coverRequests atomic.Int32
+	fixtures := utils.NewFixtureHandler(fixtureDir)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/cover/") {
+			coverRequests.Add(1)
+		}
+		fixtures.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	c := cache.New(t.TempDir(), false)
+	for i := 0; i < 2; i++ {
+		client := utils.NewRestyClient(50)
+		if err := client.Replay(server.URL); err != nil {
+			t.Fatalf("failed to enable replay: %v", err)
+		}
+		b, err := bilinovel.New(bilinovel.WithRestyClient(client), bilinovel.WithBaseURL(server.URL), bilinovel.WithCache(c))
+		if err != nil {
+			t.Fatalf("failed to create bilinovel: %v", err)
+		}
+		volume, err := b.GetVolume(testNovelId, testVolumeId, true)
+		_ = b.Close()
+		if err != nil {
+			t.Fatalf("failed to get volume: %v", err)
+		}
+		if len(volume.Cover) == 0 {
+			t.Fatalf("expected cover")
+		}
+	}
+	if n := coverRequests.Load(); n != 1 {
+		t.Errorf("expected cover to be fetched once, got %v", n)
+	}
+}
+
+func TestBilinovel_FailedImagesAreNotCached(t *testing.T) {
+	if *record {
+		t.Skip("cache is only tested against fixtures")
+	}
+
+	fixtures := utils.NewFixtureHandler(fixtureDir)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 章节插图返回 404
+		if strings.HasPrefix(r.URL.Path, "/3/") {
+			http.NotFound(w, r)
+			return
+		}
+		fixtures.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	c := cache.New(t.TempDir(), false)
+	client := utils.NewRestyClient(50)
+	if err := client.Replay(server.URL); err != nil {
+		t.Fatalf("failed to enable replay: %v", err)
+	}
+	b, err := bilinovel.New(bilinovel.WithRestyClient(client), bilinovel.WithBaseURL(server.URL), bilinovel.WithCache(c))
+	if err != nil {
+		t.Fatalf("failed to create bilinovel: %v", err)
+	}
+	volume, err := b.GetVolume(testNovelId, testVolumeId, true)
+	_ = b.Close()
+	if err != nil {
+		t.Fatalf("failed to get volume: %v", err)
+	}
+
+	imageFilename := cache.ImageFilename("https://img3.readpai.com/3/9999/99901/1001.png")
+	for _, chapter := range volume.Chapters {
+		if chapter.Content == nil {
+			continue
+		}
+		if _, ok := chapter.Content.Images[imageFilename]; ok {
+			t.Errorf("expected failed image to be skipped in %v", chapter.Title)
+		}
+	}
+	if _, ok := c.LoadImage(imageFilename); ok {
+		t.Errorf("expected failed image not to be cached")
+	}
+	entries, _ := os.ReadDir(filepath.Join(c.Dir(), "images"))
+	for _, entry := range entries {
+		if entry.Name() != cache.ImageFilename(volume.CoverUrl) {
+			t.Errorf("unexpected cached image %v", entry.Name())
+		}
+	}
+}
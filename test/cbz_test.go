@@ -0,0 +1,62 @@
+package test
+
+import (
+	"bilinovel-downloader/cbz"
+	"bilinovel-downloader/model"
+	"encoding/xml"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCBZ_PackVolume(t *testing.T) {
+	volume := loadGoldenVolume(t)
+	illustration := volume.Chapters[0].Content.Images
+	outputPath := t.TempDir()
+	if err := cbz.PackVolumeToCBZ(volume, outputPath); err != nil {
+		t.Fatalf("failed to pack volume: %v", err)
+	}
+
+	files, names := readZip(t, filepath.Join(outputPath, "測試輕小說 第一卷.cbz"))
+	if !reflect.DeepEqual(names, []string{"1.png", "2.png", "ComicInfo.xml"}) {
+		t.Fatalf("unexpected entries: %v", names)
+	}
+	if files["1.png"] != string(volume.Cover) {
+		t.Errorf("expected volume cover as first page")
+	}
+	for _, data := range illustration {
+		if files["2.png"] != string(data) {
+			t.Errorf("expected illustration as second page")
+		}
+	}
+
+	info := &cbz.ComicInfo{}
+	if err := xml.Unmarshal([]byte(files["ComicInfo.xml"]), info); err != nil {
+		t.Fatalf("failed to parse ComicInfo.xml: %v", err)
+	}
+	if info.Title != "測試輕小說 第一卷" || info.Series != "測試輕小說" || info.Number != "1" || info.Writer != "測試作者, 測試繪師" {
+		t.Errorf("unexpected ComicInfo: %+v", info)
+	}
+	if info.PageCount != 2 || len(info.Pages) != 2 || info.Pages[0].Type != "FrontCover" {
+		t.Errorf("unexpected pages: %+v", info.Pages)
+	}
+}
+
+func TestCBZ_PackNovel(t *testing.T) {
+	first, second := loadGoldenVolume(t), loadGoldenVolume(t)
+	novel := &model.Novel{Title: "測試輕小說", Volumes: []*model.Volume{first, second}}
+	outputPath := t.TempDir()
+	if err := cbz.PackNovelToCBZ(novel, outputPath); err != nil {
+		t.Fatalf("failed to pack novel: %v", err)
+	}
+	_, names := readZip(t, filepath.Join(outputPath, "測試輕小說.cbz"))
+	if !reflect.DeepEqual(names, []string{"1.png", "2.png", "3.png", "4.png", "ComicInfo.xml"}) {
+		t.Fatalf("unexpected entries: %v", names)
+	}
+
+	// 没有封面也没有插图时报错
+	empty := &model.Volume{Title: "空", Chapters: []*model.Chapter{second.Chapters[1]}}
+	if err := cbz.PackVolumeToCBZ(empty, outputPath); err == nil {
+		t.Errorf("expected error for volume without illustrations")
+	}
+}
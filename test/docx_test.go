@@ -0,0 +1,161 @@
+package test
+
+import (
+	"bilinovel-downloader/docx"
+	"bilinovel-downloader/imageopt"
+	"encoding/xml"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDOCX_PackVolume(t *testing.T) {
+	volume := loadGoldenVolume(t)
+	outputPath := t.TempDir()
+	if err := docx.PackVolumeToDOCX(volume, outputPath); err != nil {
+		t.Fatalf("failed to pack volume: %v", err)
+	}
+
+	files, _ := readZip(t, filepath.Join(outputPath, "測試輕小說 第一卷.docx"))
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "word/document.xml", "word/styles.xml",
+		"word/_rels/document.xml.rels", "docProps/core.xml", "docProps/app.xml", "word/media/image1.png", "word/media/image2.png"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("missing %v", name)
+		}
+	}
+	if files["word/media/image1.png"] != string(volume.Cover) {
+		t.Errorf("expected cover as first image")
+	}
+	// 所有 XML 部件都必须是合法的 XML
+	for name, data := range files {
+		if !strings.HasSuffix(name, ".xml") && !strings.HasSuffix(name, ".rels") {
+			continue
+		}
+		dec := xml.NewDecoder(strings.NewReader(data))
+		for {
+			_, err := dec.Token()
+			if err != nil {
+				if err != io.EOF {
+					t.Errorf("invalid xml in %v: %v", name, err)
+				}
+				break
+			}
+		}
+	}
+
+	document := files["word/document.xml"]
+	if n := strings.Count(document, `<w:pStyle w:val="Heading1"/>`); n != len(volume.Chapters) {
+		t.Errorf("expected %d headings, got %d", len(volume.Chapters), n)
+	}
+	for _, want := range []string{
+		`<w:pStyle w:val="Heading1"/></w:pPr><w:r><w:t xml:space="preserve">第一章 開端</w:t>`,
+		`<w:t xml:space="preserve">故事從一個平凡的早晨開始。</w:t>`,
+		`r:embed="rIdImage1"`,
+		`r:embed="rIdImage2"`,
+	} {
+		if !strings.Contains(document, want) {
+			t.Errorf("expected document to contain %q", want)
+		}
+	}
+	if !strings.Contains(files["word/_rels/document.xml.rels"], `Target="media/image2.png"`) {
+		t.Errorf("expected image relationship")
+	}
+	if !strings.Contains(files["[Content_Types].xml"], `<Default Extension="png" ContentType="image/png"/>`) {
+		t.Errorf("expected png content type")
+	}
+
+	core := files["docProps/core.xml"]
+	for _, want := range []string{
+		"<dc:title>測試輕小說 第一卷</dc:title>",
+		"<dc:subject>測試輕小說</dc:subject>",
+		"<dc:creator>測試作者; 測試繪師</dc:creator>",
+		"<dc:description>第一卷的簡介。</dc:description>",
+	} {
+		if !strings.Contains(core, want) {
+			t.Errorf("expected core properties to contain %q", want)
+		}
+	}
+}
+
+// ctPPrOrder 为 CT_PPr 中本项目用到的子元素，Word 要求按此顺序出现
+var ctPPrOrder = []string{"pStyle", "keepNext", "pageBreakBefore", "spacing", "ind", "jc", "outlineLvl"}
+
+func TestDOCX_ParagraphPropertiesOrder(t *testing.T) {
+	volume := loadGoldenVolume(t)
+	outputPath := t.TempDir()
+	if err := docx.PackVolumeToDOCX(volume, outputPath); err != nil {
+		t.Fatalf("failed to pack volume: %v", err)
+	}
+	files, _ := readZip(t, filepath.Join(outputPath, "測試輕小說 第一卷.docx"))
+
+	rank := make(map[string]int, len(ctPPrOrder))
+	for i, name := range ctPPrOrder {
+		rank[name] = i
+	}
+	for _, part := range []string{"word/styles.xml", "word/document.xml"} {
+		dec := xml.NewDecoder(strings.NewReader(files[part]))
+		depth, pPrDepth, last := 0, -1, -1
+		var children []string
+		for {
+			tok, err := dec.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("invalid xml in %v: %v", part, err)
+			}
+			switch tok := tok.(type) {
+			case xml.StartElement:
+				depth++
+				if tok.Name.Local == "pPr" {
+					pPrDepth, last, children = depth, -1, nil
+				} else if depth == pPrDepth+1 && pPrDepth >= 0 {
+					children = append(children, tok.Name.Local)
+					r, ok := rank[tok.Name.Local]
+					if !ok {
+						t.Errorf("%v: unexpected pPr child %v", part, tok.Name.Local)
+					} else if r < last {
+						t.Errorf("%v: pPr children out of order: %v", part, children)
+					} else {
+						last = r
+					}
+				}
+			case xml.EndElement:
+				if depth == pPrDepth {
+					pPrDepth = -1
+				}
+				depth--
+			}
+		}
+	}
+}
+
+func TestDOCX_WebPConverted(t *testing.T) {
+	volume := loadGoldenVolume(t)
+	volume.Cover = loadWebP(t)
+	outputPath := t.TempDir()
+	if err := docx.PackVolumeToDOCX(volume, outputPath); err != nil {
+		t.Fatalf("failed to pack volume: %v", err)
+	}
+
+	files, _ := readZip(t, filepath.Join(outputPath, "測試輕小說 第一卷.docx"))
+	var cover string
+	for _, name := range []string{"word/media/image1.jpg", "word/media/image1.png"} {
+		if data, ok := files[name]; ok {
+			cover = data
+		}
+	}
+	if cover == "" {
+		t.Fatalf("expected webp cover to be embedded as jpg or png")
+	}
+	if format := imageopt.Sniff([]byte(cover)); format != "jpeg" && format != "png" {
+		t.Errorf("expected converted cover, got %q", format)
+	}
+	if strings.Contains(files["[Content_Types].xml"], "webp") {
+		t.Errorf("expected no webp content type")
+	}
+	if strings.Contains(files["word/document.xml"], `cx="5731200" cy="7641600"`) {
+		t.Errorf("expected cover size from the image, not the fallback")
+	}
+}
@@ -0,0 +1,266 @@
+package test
+
+import (
+	"archive/zip"
+	"bilinovel-downloader/epub"
+	"bilinovel-downloader/model"
+	"bilinovel-downloader/utils"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// loadGoldenVolume 读取 golden 中带章节内容的卷，作为打包测试的输入
+func loadGoldenVolume(t *testing.T) *model.Volume {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(goldenDir, "volume-chapters.json"))
+	if err != nil {
+		t.Fatalf("failed to read golden volume: %v", err)
+	}
+	volume := &model.Volume{}
+	if err := json.Unmarshal(data, volume); err != nil {
+		t.Fatalf("failed to decode golden volume: %v", err)
+	}
+	return volume
+}
+
+// readZip 返回 zip 中各文件的内容，以及条目顺序
+func readZip(t *testing.T, zipPath string) (map[string]string, []string) {
+	t.Helper()
+	r, err := zip.OpenReader(zipPath)
+	if err != nil {
+		t.Fatalf("failed to open %v: %v", zipPath, err)
+	}
+	defer r.Close()
+	files := make(map[string]string)
+	names := make([]string, 0, len(r.File))
+	for _, f := range r.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("failed to open %v: %v", f.Name, err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("failed to read %v: %v", f.Name, err)
+		}
+		files[f.Name] = string(data)
+		names = append(names, f.Name)
+	}
+	return files, names
+}
+
+func TestPackNovelToEpub(t *testing.T) {
+	first := loadGoldenVolume(t)
+	second := loadGoldenVolume(t)
+	second.Id, second.Title, second.SeriesIdx = 99902, "測試輕小說 第二卷", 2
+	novel := &model.Novel{
+		Id:          testNovelId,
+		Title:       "測試輕小說",
+		Description: "這是一部用於離線測試的輕小說。",
+		Authors:     []string{"測試作者", "測試繪師"},
+		Volumes:     []*model.Volume{first, second},
+	}
+
+	outputPath := t.TempDir()
+	if err := epub.PackNovelToEpub(novel, outputPath, "body{}", nil, epub.Options{}); err != nil {
+		t.Fatalf("failed to pack novel: %v", err)
+	}
+	files, _ := readZip(t, filepath.Join(outputPath, "測試輕小說.epub"))
+
+	opf := files["content.opf"]
+	if !strings.Contains(opf, "<dc:title>測試輕小說</dc:title>") || !strings.Contains(opf, "這是一部用於離線測試的輕小說。") {
+		t.Errorf("expected novel metadata in content.opf:\n%v", opf)
+	}
+	if strings.Count(opf, "<dc:creator>") != 2 {
+		t.Errorf("expected 2 creators in content.opf:\n%v", opf)
+	}
+
+	// 阅读顺序：封面、目录，每卷封面页后接该卷章节
+	var spine []string
+	for _, m := range regexp.MustCompile(`<itemref idref="([^"]+)"`).FindAllStringSubmatch(opf, -1) {
+		spine = append(spine, m[1])
+	}
+	wantSpine := []string{
+		"cover.xhtml", "contents.xhtml",
+		"volume-000.xhtml", "volume-000-chapter-000.xhtml", "volume-000-chapter-001.xhtml", "volume-000-chapter-002.xhtml",
+		"volume-001.xhtml", "volume-001-chapter-000.xhtml", "volume-001-chapter-001.xhtml", "volume-001-chapter-002.xhtml",
+	}
+	if strings.Join(spine, ",") != strings.Join(wantSpine, ",") {
+		t.Errorf("unexpected spine:\ngot  %v\nwant %v", spine, wantSpine)
+	}
+
+	nav := files["OEBPS/Text/contents.xhtml"]
+	if !strings.Contains(nav, `<li><a href="volume-001.xhtml">測試輕小說 第二卷</a><ol><li><a href="volume-001-chapter-000.xhtml">插圖</a></li>`) {
+		t.Errorf("expected nested volume/chapter nav:\n%v", nav)
+	}
+	for _, name := range []string{"OEBPS/Text/volume-000.xhtml", "OEBPS/Text/volume-001.xhtml"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("missing volume cover page %v", name)
+		}
+	}
+}
+
+func TestPackVolumeToEpub_EPUB2Compat(t *testing.T) {
+	outputPath := t.TempDir()
+	if err := epub.PackVolumeToEpub(loadGoldenVolume(t), outputPath, "", nil, epub.Options{}); err != nil {
+		t.Fatalf("failed to pack volume: %v", err)
+	}
+	files, _ := readZip(t, filepath.Join(outputPath, "測試輕小說 第一卷.epub"))
+	if _, ok := files["toc.ncx"]; ok {
+		t.Errorf("expected no toc.ncx without compatibility mode")
+	}
+	if strings.Contains(files["content.opf"], "<guide>") {
+		t.Errorf("expected no guide without compatibility mode")
+	}
+
+	if err := epub.PackVolumeToEpub(loadGoldenVolume(t), outputPath, "", nil, epub.Options{EPUB2Compat: true}); err != nil {
+		t.Fatalf("failed to pack volume: %v", err)
+	}
+	files, _ = readZip(t, filepath.Join(outputPath, "測試輕小說 第一卷.epub"))
+	opf := files["content.opf"]
+	for _, want := range []string{
+		`<item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"></item>`,
+		`<spine toc="ncx">`,
+		`<reference title="封面" type="cover" href="OEBPS/Text/cover.xhtml"></reference>`,
+		`<reference title="目录" type="toc" href="OEBPS/Text/contents.xhtml"></reference>`,
+		`<reference title="正文" type="text" href="OEBPS/Text/chapter-000.xhtml"></reference>`,
+	} {
+		if !strings.Contains(opf, want) {
+			t.Errorf("expected content.opf to contain %q:\n%v", want, opf)
+		}
+	}
+
+	ncx := &model.NCX{}
+	if err := xml.Unmarshal([]byte(files["toc.ncx"]), ncx); err != nil {
+		t.Fatalf("failed to parse toc.ncx: %v", err)
+	}
+	if ncx.DocTitle != "測試輕小說 第一卷" || len(ncx.NavPoints) != 3 {
+		t.Fatalf("unexpected toc.ncx: %+v", ncx)
+	}
+	for i, point := range ncx.NavPoints {
+		if point.PlayOrder != i+1 || point.Content.Src != fmt.Sprintf("OEBPS/Text/chapter-%03d.xhtml", i) {
+			t.Errorf("unexpected nav point %d: %+v", i, point)
+		}
+	}
+	if ncx.NavPoints[1].Label != "第一章 開端" {
+		t.Errorf("unexpected nav label: %v", ncx.NavPoints[1].Label)
+	}
+}
+
+func TestPackNovelToEpub_EPUB2Compat(t *testing.T) {
+	first, second := loadGoldenVolume(t), loadGoldenVolume(t)
+	second.Title = "測試輕小說 第二卷"
+	novel := &model.Novel{Title: "測試輕小說", Volumes: []*model.Volume{first, second}}
+	outputPath := t.TempDir()
+	if err := epub.PackNovelToEpub(novel, outputPath, "", nil, epub.Options{EPUB2Compat: true}); err != nil {
+		t.Fatalf("failed to pack novel: %v", err)
+	}
+	files, _ := readZip(t, filepath.Join(outputPath, "測試輕小說.epub"))
+
+	ncx := &model.NCX{}
+	if err := xml.Unmarshal([]byte(files["toc.ncx"]), ncx); err != nil {
+		t.Fatalf("failed to parse toc.ncx: %v", err)
+	}
+	if len(ncx.NavPoints) != 2 || len(ncx.NavPoints[1].Children) != 3 {
+		t.Fatalf("expected two volumes with three chapters each: %+v", ncx.NavPoints)
+	}
+	// playOrder 按阅读顺序连续编号：卷、卷内章节、下一卷
+	if ncx.NavPoints[1].PlayOrder != 5 || ncx.NavPoints[1].Children[2].PlayOrder != 8 {
+		t.Errorf("unexpected play order: %+v", ncx.NavPoints[1])
+	}
+	if !strings.Contains(files["toc.ncx"], `<meta name="dtb:depth" content="2"></meta>`) {
+		t.Errorf("expected depth 2 in toc.ncx")
+	}
+}
+
+func TestPackVolumeToEpub_Reproducible(t *testing.T) {
+	pack := func() []byte {
+		outputPath := t.TempDir()
+		// 同一章中有多张图片时，清单顺序也不能随 map 遍历变化
+		volume := loadGoldenVolume(t)
+		for _, name := range []string{"a.png", "b.png", "c.png", "d.png"} {
+			volume.Chapters[0].Content.Images[name] = volume.Cover
+		}
+		if err := epub.PackVolumeToEpub(volume, outputPath, "body{}", nil, epub.Options{EPUB2Compat: true}); err != nil {
+			t.Fatalf("failed to pack volume: %v", err)
+		}
+		data, err := os.ReadFile(filepath.Join(outputPath, "測試輕小說 第一卷.epub"))
+		if err != nil {
+			t.Fatalf("failed to read epub: %v", err)
+		}
+		return data
+	}
+	first := pack()
+	if second := pack(); string(first) != string(second) {
+		t.Fatalf("packing the same volume twice produced different files")
+	}
+
+	outputPath := t.TempDir()
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	volume := loadGoldenVolume(t)
+	if err := epub.PackVolumeToEpub(volume, outputPath, "body{}", nil, epub.Options{}); err != nil {
+		t.Fatalf("failed to pack volume: %v", err)
+	}
+	epubPath := filepath.Join(outputPath, "測試輕小說 第一卷.epub")
+	files, names := readZip(t, epubPath)
+	opf := files["content.opf"]
+	if want := fmt.Sprintf("urn:uuid:%s", utils.VolumeUUID(volume.NovelId, volume.Id)); !strings.Contains(opf, want) {
+		t.Errorf("expected identifier %v in content.opf", want)
+	}
+	if !strings.Contains(opf, "2023-11-14T22:13:20Z") {
+		t.Errorf("expected dcterms:modified from SOURCE_DATE_EPOCH")
+	}
+	if names[0] != "mimetype" || !sort.StringsAreSorted(names[1:]) {
+		t.Errorf("expected mimetype first and sorted entries, got %v", names)
+	}
+	r, err := zip.OpenReader(epubPath)
+	if err != nil {
+		t.Fatalf("failed to open epub: %v", err)
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		if !f.Modified.Equal(time.Unix(1700000000, 0)) {
+			t.Errorf("unexpected modified time for %v: %v", f.Name, f.Modified)
+		}
+	}
+}
+
+func TestPackVolumeToEpub_Vertical(t *testing.T) {
+	outputPath := t.TempDir()
+	volume := loadGoldenVolume(t)
+	volume.Chapters[1].Content.Html += `<p>第12話 2025年的早晨！？真的吗?<ruby>頁<rt>1</rt></ruby></p>`
+	if err := epub.PackVolumeToEpub(volume, outputPath, "body{}", nil, epub.Options{Vertical: true}); err != nil {
+		t.Fatalf("failed to pack volume: %v", err)
+	}
+	epubPath := filepath.Join(outputPath, "測試輕小說 第一卷.epub")
+	files, _ := readZip(t, epubPath)
+
+	if !strings.Contains(files["content.opf"], `<spine page-progression-direction="rtl">`) {
+		t.Errorf("expected rtl page progression in spine")
+	}
+	if !strings.HasPrefix(files["style.css"], "body{}") || !strings.Contains(files["style.css"], "writing-mode: vertical-rl;") {
+		t.Errorf("expected vertical rules appended to style.css")
+	}
+	chapter := files["OEBPS/Text/chapter-001.xhtml"]
+	want := `<p>第<span class="tcy">12</span>話 2025年的早晨<span class="tcy">！？</span>真的吗<span class="tcy">?</span><ruby>頁<rt>1</rt></ruby></p>`
+	if !strings.Contains(chapter, want) {
+		t.Errorf("expected tate-chu-yoko in chapter, got %v", chapter)
+	}
+
+	issues, err := epub.Validate(epubPath)
+	if err != nil {
+		t.Fatalf("failed to validate: %v", err)
+	}
+	for _, issue := range issues {
+		t.Errorf("unexpected issue: %v", issue)
+	}
+}
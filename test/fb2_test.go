@@ -0,0 +1,110 @@
+package test
+
+import (
+	"archive/zip"
+	"bilinovel-downloader/fb2"
+	"encoding/xml"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fb2Document 只解析测试关心的部分
+type fb2Document struct {
+	TitleInfo struct {
+		Authors  []string `xml:"author>nickname"`
+		Title    string   `xml:"book-title"`
+		Sequence struct {
+			Name   string `xml:"name,attr"`
+			Number string `xml:"number,attr"`
+		} `xml:"sequence"`
+		Cover struct {
+			Href string `xml:"http://www.w3.org/1999/xlink href,attr"`
+		} `xml:"coverpage>image"`
+	} `xml:"description>title-info"`
+	Sections []struct {
+		Title      string   `xml:"title>p"`
+		Paragraphs []string `xml:"p"`
+		Images     []struct {
+			Href string `xml:"http://www.w3.org/1999/xlink href,attr"`
+		} `xml:"image"`
+	} `xml:"body>section"`
+	Binaries []struct {
+		Id          string `xml:"id,attr"`
+		ContentType string `xml:"content-type,attr"`
+	} `xml:"binary"`
+}
+
+func TestFB2_Marshal(t *testing.T) {
+	volume := loadGoldenVolume(t)
+	data, err := fb2.Marshal(volume)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	doc := &fb2Document{}
+	if err := xml.Unmarshal(data, doc); err != nil {
+		t.Fatalf("failed to parse fb2: %v\n%s", err, data)
+	}
+
+	info := doc.TitleInfo
+	if info.Title != volume.Title || strings.Join(info.Authors, ",") != "測試作者,測試繪師" {
+		t.Errorf("unexpected title-info: %+v", info)
+	}
+	if info.Sequence.Name != "測試輕小說" || info.Sequence.Number != "1" {
+		t.Errorf("unexpected sequence: %+v", info.Sequence)
+	}
+
+	if len(doc.Sections) != len(volume.Chapters) {
+		t.Fatalf("expected %d sections, got %d", len(volume.Chapters), len(doc.Sections))
+	}
+	if doc.Sections[1].Title != "第一章 開端" || doc.Sections[1].Paragraphs[0] != "故事從一個平凡的早晨開始。" {
+		t.Errorf("unexpected section: %+v", doc.Sections[1])
+	}
+
+	// 封面和插图都要有对应的 binary
+	binaries := make(map[string]string)
+	for _, b := range doc.Binaries {
+		binaries["#"+b.Id] = b.ContentType
+	}
+	hrefs := []string{info.Cover.Href}
+	for _, image := range doc.Sections[0].Images {
+		hrefs = append(hrefs, image.Href)
+	}
+	if len(hrefs) != 2 {
+		t.Fatalf("expected cover and one illustration, got %v", hrefs)
+	}
+	for _, href := range hrefs {
+		if binaries[href] != "image/png" {
+			t.Errorf("missing png binary for %v", href)
+		}
+	}
+}
+
+func TestFB2_PackZip(t *testing.T) {
+	volume := loadGoldenVolume(t)
+	outputPath := t.TempDir()
+	if err := fb2.PackVolumeToFB2(volume, outputPath, true); err != nil {
+		t.Fatalf("failed to pack volume: %v", err)
+	}
+	r, err := zip.OpenReader(filepath.Join(outputPath, "測試輕小說 第一卷.fb2.zip"))
+	if err != nil {
+		t.Fatalf("failed to open zip: %v", err)
+	}
+	defer r.Close()
+	if len(r.File) != 1 || r.File[0].Name != "測試輕小說 第一卷.fb2" {
+		t.Fatalf("unexpected zip entries: %v", r.File)
+	}
+	f, err := r.File[0].Open()
+	if err != nil {
+		t.Fatalf("failed to open entry: %v", err)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatalf("failed to read entry: %v", err)
+	}
+	if err := xml.Unmarshal(data, &fb2Document{}); err != nil {
+		t.Errorf("invalid fb2 in zip: %v", err)
+	}
+}
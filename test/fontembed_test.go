@@ -0,0 +1,120 @@
+package test
+
+import (
+	"bilinovel-downloader/epub"
+	"bilinovel-downloader/fontembed"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+func TestFontEmbed_Subset(t *testing.T) {
+	fontData, err := os.ReadFile(filepath.Join("..", "fontembed", "MI LANTING.ttf"))
+	if err != nil {
+		t.Fatalf("failed to read font: %v", err)
+	}
+	runes := fontembed.VolumeRunes(loadGoldenVolume(t))
+	for _, r := range "測試平凡的早晨目录A" {
+		if !strings.ContainsRune(string(runes), r) {
+			t.Errorf("expected %q in volume runes", r)
+		}
+	}
+	subset, err := fontembed.Subset(fontData, runes)
+	if err != nil {
+		t.Fatalf("failed to subset font: %v", err)
+	}
+	if len(subset) > len(fontData)/10 {
+		t.Errorf("subset is too large: %d of %d bytes", len(subset), len(fontData))
+	}
+
+	// 整个字体的校验和应为 0xB1B0AFBA
+	var sum uint32
+	for i := 0; i < len(subset); i += 4 {
+		sum += binary.BigEndian.Uint32(subset[i:])
+	}
+	if sum != 0xB1B0AFBA {
+		t.Errorf("unexpected font checksum %#x", sum)
+	}
+
+	original, err := sfnt.Parse(fontData)
+	if err != nil {
+		t.Fatalf("failed to parse font: %v", err)
+	}
+	parsed, err := sfnt.Parse(subset)
+	if err != nil {
+		t.Fatalf("failed to parse subset: %v", err)
+	}
+	if original.NumGlyphs() != parsed.NumGlyphs() {
+		t.Errorf("glyph count changed: %d -> %d", original.NumGlyphs(), parsed.NumGlyphs())
+	}
+	var buf sfnt.Buffer
+	ppem := fixed.I(16)
+	for _, r := range runes {
+		want, _ := original.GlyphIndex(&buf, r)
+		got, err := parsed.GlyphIndex(&buf, r)
+		if err != nil || got != want {
+			t.Errorf("glyph index of %q: got %v, want %v (%v)", r, got, want, err)
+			continue
+		}
+		if want == 0 {
+			continue
+		}
+		wantSegments, _ := original.LoadGlyph(&buf, want, ppem, nil)
+		wantSegments = append(sfnt.Segments(nil), wantSegments...)
+		gotSegments, err := parsed.LoadGlyph(&buf, got, ppem, nil)
+		if err != nil || (len(wantSegments) > 0 && !reflect.DeepEqual(gotSegments, wantSegments)) || len(gotSegments) != len(wantSegments) {
+			t.Errorf("outline of %q differs (%v)", r, err)
+		}
+		wantAdvance, _ := original.GlyphAdvance(&buf, want, ppem, font.HintingNone)
+		if gotAdvance, _ := parsed.GlyphAdvance(&buf, got, ppem, font.HintingNone); gotAdvance != wantAdvance {
+			t.Errorf("advance of %q differs", r)
+		}
+	}
+	if gid, _ := original.GlyphIndex(&buf, '龍'); gid == 0 {
+		t.Fatalf("expected the bundled font to contain 龍")
+	}
+	if gid, _ := parsed.GlyphIndex(&buf, '龍'); gid != 0 {
+		t.Errorf("expected unused character to be dropped from cmap")
+	}
+}
+
+func TestFontEmbed_PackVolume(t *testing.T) {
+	fontData, err := os.ReadFile(filepath.Join("..", "fontembed", "MI LANTING.ttf"))
+	if err != nil {
+		t.Fatalf("failed to read font: %v", err)
+	}
+	volume := loadGoldenVolume(t)
+	fontCSS, extraFiles, err := fontembed.Embed(fontData, volume)
+	if err != nil {
+		t.Fatalf("failed to embed font: %v", err)
+	}
+	outputPath := t.TempDir()
+	if err := epub.PackVolumeToEpub(volume, outputPath, fontCSS+"body{}", extraFiles, epub.Options{}); err != nil {
+		t.Fatalf("failed to pack volume: %v", err)
+	}
+	epubPath := filepath.Join(outputPath, "測試輕小說 第一卷.epub")
+	files, _ := readZip(t, epubPath)
+	if files["Fonts/embedded.ttf"] == "" {
+		t.Fatalf("expected embedded font in epub")
+	}
+	if !strings.Contains(files["style.css"], `src: url(Fonts/embedded.ttf);`) {
+		t.Errorf("expected @font-face in style.css, got %v", files["style.css"])
+	}
+	if !strings.Contains(files["content.opf"], `href="Fonts/embedded.ttf" media-type="font/ttf"`) {
+		t.Errorf("expected font in manifest")
+	}
+	issues, err := epub.Validate(epubPath)
+	if err != nil {
+		t.Fatalf("failed to validate: %v", err)
+	}
+	for _, issue := range issues {
+		t.Errorf("unexpected issue: %v", issue)
+	}
+}
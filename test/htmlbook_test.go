@@ -0,0 +1,54 @@
+package test
+
+import (
+	"bilinovel-downloader/htmlbook"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestHTMLBook_PackVolume(t *testing.T) {
+	volume := loadGoldenVolume(t)
+	outputPath := t.TempDir()
+	if err := htmlbook.PackVolumeToHTML(volume, outputPath, ".chapter{color:red}"); err != nil {
+		t.Fatalf("failed to pack volume: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(outputPath, "測試輕小說 第一卷.html"))
+	if err != nil {
+		t.Fatalf("failed to read html: %v", err)
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatalf("failed to parse html: %v", err)
+	}
+
+	if !strings.Contains(doc.Find("style").First().Text(), ".chapter{color:red}") {
+		t.Errorf("expected embedded style.css")
+	}
+	if !strings.Contains(string(data), `xlink:href="data:image/png;base64,`) {
+		t.Errorf("expected cover data uri")
+	}
+
+	links := doc.Find("#toc a")
+	if links.Length() != len(volume.Chapters) {
+		t.Fatalf("expected %d toc entries, got %d", len(volume.Chapters), links.Length())
+	}
+	links.Each(func(i int, s *goquery.Selection) {
+		target := doc.Find(s.AttrOr("href", ""))
+		if target.Length() != 1 || target.Find("h1").Text() != volume.Chapters[i].Title {
+			t.Errorf("toc entry %d does not point to chapter %v", i, volume.Chapters[i].Title)
+		}
+	})
+
+	doc.Find("img").Each(func(i int, s *goquery.Selection) {
+		if src := s.AttrOr("src", ""); !strings.HasPrefix(src, "data:image/png;base64,") {
+			t.Errorf("expected inlined image, got %q", src)
+		}
+	})
+	if doc.Find("img").Length() == 0 {
+		t.Errorf("expected illustration image")
+	}
+}
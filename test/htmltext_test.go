@@ -0,0 +1,56 @@
+package test
+
+import (
+	"bilinovel-downloader/htmltext"
+	"bilinovel-downloader/text"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// eventRecorder 把 htmltext 的事件记录为字符串
+type eventRecorder struct {
+	events []string
+}
+
+func (r *eventRecorder) Text(text string)      { r.events = append(r.events, "text "+text) }
+func (r *eventRecorder) LineBreak()            { r.events = append(r.events, "br") }
+func (r *eventRecorder) Image(src, alt string) { r.events = append(r.events, "img "+src+" "+alt) }
+func (r *eventRecorder) StartBlock(tag string) { r.events = append(r.events, "<"+tag+">") }
+func (r *eventRecorder) EndBlock(tag string)   { r.events = append(r.events, "</"+tag+">") }
+func (r *eventRecorder) StartStyle(s htmltext.Style) {
+	r.events = append(r.events, fmt.Sprintf("style %v", s))
+}
+func (r *eventRecorder) EndStyle(s htmltext.Style) {
+	r.events = append(r.events, fmt.Sprintf("/style %v", s))
+}
+
+func TestHTMLText_Walk(t *testing.T) {
+	input := "<h2>標題</h2><p>第一行\n  <b>粗<i>斜</i></b><br/><ruby>魔法<rp>(</rp><rt>まほう</rt><rp>)</rp></ruby></p>" +
+		`<script>var s = 1;</script><style>p{}</style><hr/><span>行內</span><img src="a.png" alt="圖"/>`
+	r := &eventRecorder{}
+	if err := htmltext.Walk(input, r); err != nil {
+		t.Fatalf("failed to walk: %v", err)
+	}
+	want := []string{
+		"<h2>", "text 標題", "</h2>",
+		"<p>", "text 第一行 ", "style 1", "text 粗", "style 0", "text 斜", "/style 0", "/style 1", "br",
+		"text 魔法", "text （", "text まほう", "text ）", "</p>",
+		"<hr>", "</hr>", "text 行內", "img a.png 圖",
+	}
+	if got := strings.Join(r.events, "\n"); got != strings.Join(want, "\n") {
+		t.Errorf("unexpected events:\ngot:\n%v\nwant:\n%v", got, strings.Join(want, "\n"))
+	}
+	if htmltext.HeadingLevel("h3") != 3 || htmltext.HeadingLevel("p") != 0 {
+		t.Errorf("unexpected heading levels")
+	}
+
+	// 各格式的注音显示一致
+	got, err := text.HTMLToText(`<p><ruby>魔法<rp>(</rp><rt>まほう</rt><rp>)</rp></ruby>是危險的</p>`, text.Options{})
+	if err != nil {
+		t.Fatalf("failed to convert: %v", err)
+	}
+	if got != "魔法（まほう）是危險的" {
+		t.Errorf("unexpected text %q", got)
+	}
+}
@@ -0,0 +1,227 @@
+package test
+
+import (
+	"bilinovel-downloader/cbz"
+	"bilinovel-downloader/epub"
+	"bilinovel-downloader/imageopt"
+	"bilinovel-downloader/markdown"
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func loadWebP(t *testing.T) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "images", "video-001.webp"))
+	if err != nil {
+		t.Fatalf("failed to read webp: %v", err)
+	}
+	return data
+}
+
+func decodeImage(t *testing.T, data []byte) image.Image {
+	t.Helper()
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to decode image: %v", err)
+	}
+	return img
+}
+
+func TestImageOpt_Process(t *testing.T) {
+	webp := loadWebP(t)
+	if imageopt.Sniff(webp) != "webp" {
+		t.Fatalf("expected webp, got %q", imageopt.Sniff(webp))
+	}
+
+	// WebP 转为 JPEG 并缩小
+	out, err := imageopt.Process(webp, imageopt.Options{Format: "auto"})
+	if err != nil {
+		t.Fatalf("failed to process: %v", err)
+	}
+	if imageopt.Sniff(out) != "jpeg" || decodeImage(t, out).Bounds().Dx() != 150 {
+		t.Errorf("expected 150px jpeg, got %q", imageopt.Sniff(out))
+	}
+	out, err = imageopt.Process(webp, imageopt.Options{MaxWidth: 75, Grayscale: true})
+	if err != nil {
+		t.Fatalf("failed to process: %v", err)
+	}
+	img := decodeImage(t, out)
+	if imageopt.Sniff(out) != "jpeg" || img.Bounds().Dx() != 75 || img.Bounds().Dy() != 52 {
+		t.Errorf("expected 75x52 jpeg, got %q %v", imageopt.Sniff(out), img.Bounds())
+	}
+	if img.ColorModel() != color.GrayModel {
+		t.Errorf("expected grayscale jpeg, got %T", img)
+	}
+
+	// 带透明度的 PNG：auto 保留 PNG，jpeg 时透明部分填充为白色
+	transparent := image.NewNRGBA(image.Rect(0, 0, 40, 20))
+	for x := 0; x < 20; x++ {
+		for y := 0; y < 20; y++ {
+			transparent.SetNRGBA(x, y, color.NRGBA{R: 0xff, A: 0xff})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, transparent); err != nil {
+		t.Fatalf("failed to encode png: %v", err)
+	}
+	pngData := buf.Bytes()
+	if out, err := imageopt.Process(pngData, imageopt.Options{Format: "auto"}); err != nil || !bytes.Equal(out, pngData) {
+		t.Errorf("expected png to be kept as is (%v)", err)
+	}
+	out, err = imageopt.Process(pngData, imageopt.Options{Format: "jpeg"})
+	if err != nil {
+		t.Fatalf("failed to process: %v", err)
+	}
+	if r, g, b, _ := decodeImage(t, out).At(30, 10).RGBA(); r < 0xf000 || g < 0xf000 || b < 0xf000 {
+		t.Errorf("expected transparent area to become white, got %v %v %v", r, g, b)
+	}
+	out, err = imageopt.Process(pngData, imageopt.Options{Grayscale: true})
+	if err != nil {
+		t.Fatalf("failed to process: %v", err)
+	}
+	if imageopt.Sniff(out) != "png" {
+		t.Errorf("expected png, got %q", imageopt.Sniff(out))
+	}
+	if c := color.NRGBAModel.Convert(decodeImage(t, out).At(10, 10)).(color.NRGBA); c.R != c.G || c.G != c.B || c.A != 0xff {
+		t.Errorf("expected opaque gray pixel, got %v", c)
+	}
+	if _, _, _, a := decodeImage(t, out).At(30, 10).RGBA(); a != 0 {
+		t.Errorf("expected transparency to be kept")
+	}
+
+	// 重新压缩 JPEG，结果更大时保留原图
+	buf.Reset()
+	if err := jpeg.Encode(&buf, decodeImage(t, webp), &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatalf("failed to encode jpeg: %v", err)
+	}
+	jpegData := buf.Bytes()
+	out, err = imageopt.Process(jpegData, imageopt.Options{Quality: 50})
+	if err != nil || len(out) >= len(jpegData) {
+		t.Errorf("expected smaller jpeg, got %d of %d bytes (%v)", len(out), len(jpegData), err)
+	}
+	if again, err := imageopt.Process(out, imageopt.Options{Quality: 100}); err != nil || !bytes.Equal(again, out) {
+		t.Errorf("expected jpeg to be kept when recompressing makes it larger (%v)", err)
+	}
+
+	// 无法识别的格式原样保留
+	svg := []byte(`<svg xmlns="http://www.w3.org/2000/svg"/>`)
+	if out, err := imageopt.Process(svg, imageopt.Options{Format: "jpeg"}); err != nil || !bytes.Equal(out, svg) {
+		t.Errorf("expected svg to be kept as is (%v)", err)
+	}
+	if err := (imageopt.Options{Format: "bmp"}).Validate(); err == nil {
+		t.Errorf("expected error for unknown format")
+	}
+	if w, h, err := imageopt.ParseSize("1264x1680"); err != nil || w != 1264 || h != 1680 {
+		t.Errorf("unexpected size %v %v %v", w, h, err)
+	}
+}
+
+func TestImageOpt_PackVolume(t *testing.T) {
+	webp := loadWebP(t)
+	volume := loadGoldenVolume(t)
+	var name string
+	for n := range volume.Chapters[0].Content.Images {
+		name = n
+	}
+	if !strings.HasSuffix(name, ".png") {
+		t.Fatalf("expected png image in golden volume, got %v", name)
+	}
+	// 站点返回的 WebP 图片使用 .png 地址
+	volume.Chapters[0].Content.Images[name] = webp
+	volume.Cover = webp
+	webpName := strings.TrimSuffix(name, ".png") + ".webp"
+	jpgName := strings.TrimSuffix(name, ".png") + ".jpg"
+
+	outputPath := t.TempDir()
+	processed := imageopt.ProcessVolume(volume, imageopt.Options{Format: "auto", MaxHeight: 80})
+	if _, ok := processed.Chapters[0].Content.Images[jpgName]; !ok {
+		t.Fatalf("expected image to be renamed to %v", jpgName)
+	}
+	if !strings.Contains(processed.Chapters[0].Content.Html, jpgName) {
+		t.Errorf("expected chapter html to reference %v", jpgName)
+	}
+	if _, ok := volume.Chapters[0].Content.Images[name]; !ok || !strings.Contains(volume.Chapters[0].Content.Html, name) {
+		t.Errorf("original volume was modified")
+	}
+	if img := decodeImage(t, processed.Cover); img.Bounds().Dy() != 80 || imageopt.Sniff(processed.Cover) != "jpeg" {
+		t.Errorf("expected 80px jpeg cover, got %v", img.Bounds())
+	}
+
+	for _, v := range []struct {
+		dir        string
+		image      string
+		cover      string
+		mediaType  string
+		coverMedia string
+	}{
+		// 未处理时文件名与 media-type 按实际内容修正
+		{"raw", webpName, "cover.webp", "image/webp", "image/webp"},
+		{"processed", jpgName, "cover.jpg", "image/jpeg", "image/jpeg"},
+	} {
+		source := volume
+		if v.dir == "processed" {
+			source = processed
+		}
+		if err := epub.PackVolumeToEpub(source, filepath.Join(outputPath, v.dir), "body{}", nil, epub.Options{}); err != nil {
+			t.Fatalf("failed to pack volume: %v", err)
+		}
+		epubPath := filepath.Join(outputPath, v.dir, "測試輕小說 第一卷.epub")
+		files, _ := readZip(t, epubPath)
+		if !strings.Contains(files["content.opf"], v.image+`" media-type="`+v.mediaType+`"`) {
+			t.Errorf("%v: expected %v with %v in manifest", v.dir, v.image, v.mediaType)
+		}
+		if !strings.Contains(files["content.opf"], `href="`+v.cover+`" media-type="`+v.coverMedia+`"`) {
+			t.Errorf("%v: expected %v with %v in manifest", v.dir, v.cover, v.coverMedia)
+		}
+		issues, err := epub.Validate(epubPath)
+		if err != nil {
+			t.Fatalf("failed to validate: %v", err)
+		}
+		for _, issue := range issues {
+			t.Errorf("%v: unexpected issue: %v", v.dir, issue)
+		}
+	}
+}
+
+func TestImageOpt_WebPCover(t *testing.T) {
+	webp := loadWebP(t)
+	volume := loadGoldenVolume(t)
+	// 站点返回的 WebP 封面与插图使用 .jpg、.png 地址
+	volume.Cover = webp
+	volume.CoverUrl = "https://img3.readpai.com/cover/99901.jpg"
+	for name := range volume.Chapters[0].Content.Images {
+		volume.Chapters[0].Content.Images[name] = webp
+	}
+	outputPath := t.TempDir()
+
+	if err := cbz.PackVolumeToCBZ(volume, outputPath); err != nil {
+		t.Fatalf("failed to pack cbz: %v", err)
+	}
+	_, names := readZip(t, filepath.Join(outputPath, "測試輕小說 第一卷.cbz"))
+	if !reflect.DeepEqual(names, []string{"1.webp", "2.webp", "ComicInfo.xml"}) {
+		t.Errorf("unexpected cbz entries: %v", names)
+	}
+
+	if err := markdown.PackVolumeToMarkdown(volume, outputPath); err != nil {
+		t.Fatalf("failed to pack markdown: %v", err)
+	}
+	volumeDir := filepath.Join(outputPath, "測試輕小說 第一卷")
+	content, err := os.ReadFile(filepath.Join(volumeDir, "測試輕小說 第一卷.md"))
+	if err != nil {
+		t.Fatalf("failed to read markdown: %v", err)
+	}
+	if !strings.Contains(string(content), `cover: "assets/cover.webp"`) {
+		t.Errorf("expected webp cover in front matter:\n%s", content)
+	}
+	if _, err := os.Stat(filepath.Join(volumeDir, "assets", "cover.webp")); err != nil {
+		t.Errorf("missing cover: %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+package test
+
+import (
+	"archive/zip"
+	"bilinovel-downloader/epub"
+	"bilinovel-downloader/kepub"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestKepub_AddKoboSpans(t *testing.T) {
+	input := `<?xml version='1.0' encoding='utf-8'?>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="zh-CN"><head><title>第一章</title><link href="../../style.css" rel="stylesheet" type="text/css"/></head><body><div class="chapter"><h1>第一章</h1><hr/><div class="content"><p>　　「早安。」少年說。貓沒有回答！</p><p>他<em>笑了</em>…</p><div class="divimage"><img src="a.png"/></div></div></div></body></html>`
+
+	got, err := kepub.AddKoboSpans([]byte(input))
+	if err != nil {
+		t.Fatalf("failed to add spans: %v", err)
+	}
+	if !strings.HasPrefix(string(got), "<?xml version='1.0' encoding='utf-8'?>") {
+		t.Errorf("expected xml declaration to be kept:\n%s", got)
+	}
+	if !strings.Contains(string(got), `<img src="a.png"/>`) || !strings.Contains(string(got), `<hr/>`) {
+		t.Errorf("expected void elements to stay self-closed:\n%s", got)
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(got)))
+	if err != nil {
+		t.Fatalf("failed to parse output: %v", err)
+	}
+	if doc.Find("body > #book-columns > #book-inner > .chapter").Length() != 1 {
+		t.Errorf("expected body content inside book-columns/book-inner:\n%s", got)
+	}
+
+	want := map[string]string{
+		"kobo.1.1": "第一章",
+		"kobo.2.1": "　　「早安。」",
+		"kobo.2.2": "少年說。",
+		"kobo.2.3": "貓沒有回答！",
+		"kobo.3.1": "他",
+		"kobo.3.2": "笑了",
+		"kobo.3.3": "…",
+		"kobo.4.1": "",
+	}
+	spans := doc.Find("span.koboSpan")
+	if spans.Length() != len(want) {
+		t.Fatalf("expected %d spans, got %d:\n%s", len(want), spans.Length(), got)
+	}
+	spans.Each(func(i int, s *goquery.Selection) {
+		id := s.AttrOr("id", "")
+		text, ok := want[id]
+		if !ok || s.Text() != text {
+			t.Errorf("unexpected span %v: %q", id, s.Text())
+		}
+	})
+	if doc.Find("#kobo\\.4\\.1 > img").Length() != 1 {
+		t.Errorf("expected image wrapped in its own span")
+	}
+
+	again, err := kepub.AddKoboSpans([]byte(input))
+	if err != nil || string(again) != string(got) {
+		t.Errorf("expected stable output")
+	}
+}
+
+func TestKepub_PackVolume(t *testing.T) {
+	volume := loadGoldenVolume(t)
+	outputPath := t.TempDir()
+	if err := kepub.PackVolumeToKepub(volume, outputPath, "", nil, epub.Options{}); err != nil {
+		t.Fatalf("failed to pack volume: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputPath, "測試輕小說 第一卷.epub")); !os.IsNotExist(err) {
+		t.Errorf("expected intermediate epub to be removed")
+	}
+	files, names := readZip(t, filepath.Join(outputPath, "測試輕小說 第一卷.kepub.epub"))
+	if names[0] != "mimetype" || files["mimetype"] != "application/epub+zip" {
+		t.Errorf("expected mimetype as first entry, got %v", names[0])
+	}
+	if !strings.Contains(files["OEBPS/Text/chapter-001.xhtml"], `<span class="koboSpan" id="kobo.2.1">　　故事從一個平凡的早晨開始。</span>`) {
+		t.Errorf("expected kobo spans in chapter:\n%v", files["OEBPS/Text/chapter-001.xhtml"])
+	}
+	if strings.Contains(files["OEBPS/Text/contents.xhtml"], "koboSpan") {
+		t.Errorf("expected nav document to be left unchanged")
+	}
+}
+
+func TestKepub_Reproducible(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	pack := func() []byte {
+		outputPath := t.TempDir()
+		if err := kepub.PackVolumeToKepub(loadGoldenVolume(t), outputPath, "", nil, epub.Options{}); err != nil {
+			t.Fatalf("failed to pack volume: %v", err)
+		}
+		data, err := os.ReadFile(filepath.Join(outputPath, "測試輕小說 第一卷.kepub.epub"))
+		if err != nil {
+			t.Fatalf("failed to read kepub: %v", err)
+		}
+		return data
+	}
+	data := pack()
+	if second := pack(); !bytes.Equal(data, second) {
+		t.Fatalf("packing the same volume twice produced different files")
+	}
+
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("failed to open kepub: %v", err)
+	}
+	for _, f := range r.File {
+		if !f.Modified.Equal(time.Unix(1700000000, 0)) {
+			t.Errorf("unexpected modified time for %v: %v", f.Name, f.Modified)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package test
+
+import (
+	"bilinovel-downloader/library"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLibrary_Diff(t *testing.T) {
+	b, _ := newTestBilinovel(t)
+	novel, err := b.GetNovel(testNovelId, true)
+	if err != nil {
+		t.Fatalf("failed to get novel: %v", err)
+	}
+	current := library.NewSnapshot(novel)
+
+	if report := library.Diff(library.NewSnapshot(novel), current); len(report.Changes) != 0 {
+		t.Errorf("expected no changes, got %+v", report.Changes)
+	}
+
+	// 上次下载时只有第一卷的前两章，且第二章标题不同
+	old := library.NewSnapshot(novel)
+	old.Volumes = old.Volumes[:1]
+	first := old.Volumes[0]
+	first.Chapters = first.Chapters[:2]
+	first.Chapters[1].Title = "第一章"
+
+	report := library.Diff(old, current)
+	want := []library.Change{
+		{Kind: library.ChapterTitle, VolumeId: 99901, VolumeTitle: "測試輕小說 第一卷", Old: "第一章", New: "第一章 開端"},
+		{Kind: library.NewChapter, VolumeId: 99901, VolumeTitle: "測試輕小說 第一卷", New: "第二章 謎題"},
+		{Kind: library.NewVolume, VolumeId: 99902, VolumeTitle: "測試輕小說 第二卷", New: "測試輕小說 第二卷", Chapters: len(current.Volumes[1].Chapters)},
+	}
+	if !reflect.DeepEqual(report.Changes, want) {
+		t.Errorf("unexpected changes:\ngot  %+v\nwant %+v", report.Changes, want)
+	}
+	if got := report.UpdatedVolumes(); !reflect.DeepEqual(got, []int{99901, 99902}) {
+		t.Errorf("unexpected updated volumes: %v", got)
+	}
+}
+
+func TestLibrary_SnapshotRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	if snapshot, err := library.LoadSnapshot(dir, testNovelId); err != nil || snapshot != nil {
+		t.Fatalf("expected no snapshot, got %v, %v", snapshot, err)
+	}
+
+	// 没有快照时由已下载的卷重建
+	data, err := os.ReadFile(filepath.Join(goldenDir, "volume-chapters.json"))
+	if err != nil {
+		t.Fatalf("failed to read golden volume: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "volume-9999-99901.json"), data, 0644); err != nil {
+		t.Fatalf("failed to write volume: %v", err)
+	}
+	snapshot, err := library.SnapshotFromVolumes(dir, testNovelId)
+	if err != nil {
+		t.Fatalf("failed to rebuild snapshot: %v", err)
+	}
+	if snapshot.Title != "測試輕小說" || len(snapshot.Volumes) != 1 || len(snapshot.Volumes[0].Chapters) != 3 {
+		t.Fatalf("unexpected snapshot: %+v", snapshot)
+	}
+
+	if err := library.SaveSnapshot(dir, snapshot); err != nil {
+		t.Fatalf("failed to save snapshot: %v", err)
+	}
+	loaded, err := library.LoadSnapshot(dir, testNovelId)
+	if err != nil {
+		t.Fatalf("failed to load snapshot: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, snapshot) {
+		t.Errorf("snapshot changed after round trip:\ngot  %+v\nwant %+v", loaded, snapshot)
+	}
+}
+
+func TestLibrary_ReadList(t *testing.T) {
+	listPath := filepath.Join(t.TempDir(), "library.txt")
+	list := strings.Join([]string{"# 关注列表", "2388  # 某部小说", "", "  9999", "1234 备注"}, "\n")
+	if err := os.WriteFile(listPath, []byte(list), 0644); err != nil {
+		t.Fatalf("failed to write list: %v", err)
+	}
+	ids, err := library.ReadList(listPath)
+	if err != nil {
+		t.Fatalf("failed to read list: %v", err)
+	}
+	if !reflect.DeepEqual(ids, []int{2388, 9999, 1234}) {
+		t.Errorf("unexpected ids: %v", ids)
+	}
+
+	if err := os.WriteFile(listPath, []byte("2388\nabc\n"), 0644); err != nil {
+		t.Fatalf("failed to write list: %v", err)
+	}
+	if _, err := library.ReadList(listPath); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected error on line 2, got %v", err)
+	}
+}
+
+func TestLibrary_CheckUpdatesExitStatus(t *testing.T) {
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "bilinovel-downloader")
+	if out, err := exec.Command("go", "build", "-o", binary, "bilinovel-downloader").CombinedOutput(); err != nil {
+		t.Fatalf("failed to build: %v\n%s", err, out)
+	}
+
+	// 参数错误在访问网络前就会失败
+	out, err := exec.Command(binary, "check-updates", "not-a-number").CombinedOutput()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+		t.Errorf("expected exit status 1 for invalid novel id, got %v\n%s", err, out)
+	}
+	if !strings.Contains(string(out), `invalid novel id: "not-a-number"`) {
+		t.Errorf("expected error in output:\n%s", out)
+	}
+	if strings.Contains(string(out), "Usage:") {
+		t.Errorf("expected no usage on failure:\n%s", out)
+	}
+}
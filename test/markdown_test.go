@@ -0,0 +1,86 @@
+package test
+
+import (
+	"bilinovel-downloader/markdown"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMarkdown_HTMLToMarkdown(t *testing.T) {
+	input := `<h1>幕間</h1>
+	<p>　　她說：<em>等等</em>，然後<b>停下</b>了。</p>
+	<p>第一行<br>第二行<br></p>
+	<p><ruby>魔法<rp>(</rp><rt>まほう</rt><rp>)</rp></ruby>是*危險*的</p>
+	<p>1. 不是列表</p>
+	<hr/>
+	<div class="divimage"><img src="a.png" alt="插圖"/></div>`
+
+	got, err := markdown.HTMLToMarkdown(input, "assets", 2)
+	if err != nil {
+		t.Fatalf("failed to convert: %v", err)
+	}
+	want := strings.Join([]string{
+		"### 幕間",
+		"她說：*等等*，然後**停下**了。",
+		"第一行\\\n第二行",
+		"魔法（まほう）是\\*危險\\*的",
+		"\\1. 不是列表",
+		"* * *",
+		"![插圖](assets/a.png)",
+	}, "\n\n")
+	if got != want {
+		t.Errorf("unexpected markdown:\ngot\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestMarkdown_PackVolume(t *testing.T) {
+	volume := loadGoldenVolume(t)
+	outputPath := t.TempDir()
+	if err := markdown.PackVolumeToMarkdown(volume, outputPath); err != nil {
+		t.Fatalf("failed to pack volume: %v", err)
+	}
+	volumeDir := filepath.Join(outputPath, "測試輕小說 第一卷")
+	data, err := os.ReadFile(filepath.Join(volumeDir, "測試輕小說 第一卷.md"))
+	if err != nil {
+		t.Fatalf("failed to read markdown: %v", err)
+	}
+	content := string(data)
+
+	frontMatter := strings.Join([]string{
+		"---",
+		`title: "測試輕小說 第一卷"`,
+		"authors:",
+		`  - "測試作者"`,
+		`  - "測試繪師"`,
+		`series: "測試輕小說"`,
+		"series_index: 1",
+		`description: "第一卷的簡介。"`,
+		`cover: "assets/cover.png"`,
+		"---",
+		"",
+		"# 測試輕小說 第一卷",
+		"",
+		"## 插圖",
+		"",
+	}, "\n")
+	if !strings.HasPrefix(content, frontMatter) {
+		t.Errorf("unexpected front matter:\n%v", content)
+	}
+	if !strings.Contains(content, "## 第一章 開端\n\n故事從一個平凡的早晨開始。\n\n少年推開窗") {
+		t.Errorf("expected chapter paragraphs:\n%v", content)
+	}
+
+	for filename := range volume.Chapters[0].Content.Images {
+		if !strings.Contains(content, "](assets/"+filename+")") {
+			t.Errorf("expected relative link to %v", filename)
+		}
+		if _, err := os.Stat(filepath.Join(volumeDir, "assets", filename)); err != nil {
+			t.Errorf("missing asset: %v", err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(volumeDir, "assets", "cover.png")); err != nil {
+		t.Errorf("missing cover: %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package test
+
+import (
+	"bilinovel-downloader/pdf"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+func pdfTextString(s string) string {
+	var b strings.Builder
+	b.WriteString("<FEFF")
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", u)
+	}
+	b.WriteString(">")
+	return b.String()
+}
+
+func TestPDF_PackVolume(t *testing.T) {
+	font, err := os.ReadFile(filepath.Join("..", "fontembed", "MI LANTING.ttf"))
+	if err != nil {
+		t.Fatalf("failed to read font: %v", err)
+	}
+	volume := loadGoldenVolume(t)
+	outputPath := t.TempDir()
+	if err := pdf.PackVolumeToPDF(volume, outputPath, font, pdf.DefaultOptions()); err != nil {
+		t.Fatalf("failed to pack volume: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(outputPath, "測試輕小說 第一卷.pdf"))
+	if err != nil {
+		t.Fatalf("failed to read pdf: %v", err)
+	}
+	content := string(data)
+	if !strings.HasPrefix(content, "%PDF-1.7\n") || !strings.HasSuffix(content, "%%EOF\n") {
+		t.Fatalf("unexpected pdf header or trailer")
+	}
+
+	// 交叉引用表中的每个偏移量都必须指向对应的对象
+	startxref := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindStringSubmatch(content)
+	if startxref == nil {
+		t.Fatalf("missing startxref")
+	}
+	xref, _ := strconv.Atoi(startxref[1])
+	lines := strings.Split(content[xref:], "\n")
+	count, _ := strconv.Atoi(strings.Fields(lines[1])[1])
+	for i := 1; i < count; i++ {
+		offset, _ := strconv.Atoi(strings.Fields(lines[2+i])[0])
+		if want := fmt.Sprintf("%d 0 obj\n", i); !strings.HasPrefix(content[offset:], want) {
+			t.Errorf("xref entry %d does not point to its object", i)
+		}
+	}
+
+	// 封面、插图各占一页，第一章一页，第二章的 24 段在 A5 上排成两页
+	if pages := regexp.MustCompile(`/Type /Pages /Kids \[[^\]]*\] /Count (\d+) `).FindStringSubmatch(content); pages == nil || pages[1] != "5" {
+		t.Errorf("expected 5 pages, got %v", pages)
+	}
+	if n := strings.Count(content, "/Subtype /Image"); n != 2 {
+		t.Errorf("expected cover and illustration images, got %d", n)
+	}
+	for _, chapter := range volume.Chapters {
+		if !strings.Contains(content, "/Title "+pdfTextString(chapter.Title)+" /Parent") {
+			t.Errorf("missing bookmark for %v", chapter.Title)
+		}
+	}
+	for _, want := range []string{"/Outlines", "/Subtype /CIDFontType2", "/FontFile2", "/ToUnicode",
+		"/Author " + pdfTextString("測試作者, 測試繪師"), "/Subject " + pdfTextString("測試輕小說")} {
+		if !strings.Contains(content, want) {
+			t.Errorf("expected pdf to contain %q", want)
+		}
+	}
+}
+
+func TestPDF_ParseOptions(t *testing.T) {
+	size, err := pdf.ParsePageSize("A4")
+	if err != nil || size != pdf.PageSizes["a4"] {
+		t.Errorf("unexpected a4 size: %v, %v", size, err)
+	}
+	size, err = pdf.ParsePageSize("127x188")
+	if err != nil || fmt.Sprintf("%.0f %.0f", size.Width, size.Height) != "360 533" {
+		t.Errorf("unexpected custom size: %v, %v", size, err)
+	}
+	if _, err := pdf.ParsePageSize("huge"); err == nil {
+		t.Errorf("expected error for unknown page size")
+	}
+
+	margins, err := pdf.ParseMargins("25.4")
+	if err != nil || margins != (pdf.Margins{Top: 72, Right: 72, Bottom: 72, Left: 72}) {
+		t.Errorf("unexpected margins: %v, %v", margins, err)
+	}
+	margins, err = pdf.ParseMargins("25.4, 0, 0, 12.7")
+	if err != nil || margins != (pdf.Margins{Top: 72, Left: 36}) {
+		t.Errorf("unexpected margins: %v, %v", margins, err)
+	}
+	if _, err := pdf.ParseMargins("1,2"); err == nil {
+		t.Errorf("expected error for two margins")
+	}
+}
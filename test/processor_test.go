@@ -0,0 +1,119 @@
+package test
+
+import (
+	"bilinovel-downloader/downloader/bilinovel"
+	"bilinovel-downloader/processor"
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func processHtml(t *testing.T, html string, processors ...processor.Processor) string {
+	t.Helper()
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<div id="acontent">` + html + `</div>`))
+	if err != nil {
+		t.Fatalf("failed to parse html: %v", err)
+	}
+	page := &processor.Page{Content: doc.Find("#acontent")}
+	for _, p := range processors {
+		if err := p.Process(context.Background(), page); err != nil {
+			t.Fatalf("processor %v failed: %v", p.Name(), err)
+		}
+	}
+	got, err := page.Content.Html()
+	if err != nil {
+		t.Fatalf("failed to get html: %v", err)
+	}
+	return got
+}
+
+func TestProcessor_Builtins(t *testing.T) {
+	tests := []struct {
+		name      string
+		processor processor.Processor
+		html      string
+		want      string
+	}{
+		{
+			name:      "remove",
+			processor: &processor.RemoveElements{Selectors: []string{".ad", "center"}},
+			html:      `<p>a</p><div class="ad">x</div><center>y</center><p>b</p>`,
+			want:      `<p>a</p><p>b</p>`,
+		},
+		{
+			name:      "replace only touches text",
+			processor: &processor.RegexReplace{Pattern: regexp.MustCompile(`p(\d)`), Replacement: "q$1"},
+			html:      `<p class="p1">p1</p><img src="p2.png"/>`,
+			want:      `<p class="p1">q1</p><img src="p2.png"/>`,
+		},
+		{
+			name:      "normalize paragraphs",
+			processor: &processor.NormalizeParagraphs{},
+			html:      `<p>a</p><p>　 </p><p><img src="1.png"/></p>b<br/><br/> <br/>c`,
+			want:      `<p>a</p><p><img src="1.png"/></p>b<br/> c`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := processHtml(t, tt.html, tt.processor); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessor_LoadConfig(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "processors.json")
+	config := `[
+		{"type": "remove", "selectors": [".ad"]},
+		{"type": "replace", "pattern": "貓", "replacement": "狗"},
+		{"type": "normalize-paragraphs"}
+	]`
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	processors, err := processor.LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	got := processHtml(t, `<p>白貓</p><p class="ad">廣告</p><p></p>`, processors...)
+	if want := `<p>白狗</p>`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if err := os.WriteFile(configPath, []byte(`[{"type": "unknown"}]`), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	if _, err := processor.LoadConfig(configPath); err == nil {
+		t.Errorf("expected error for unknown processor type")
+	}
+}
+
+func TestBilinovel_CustomProcessorsRunAfterDefaults(t *testing.T) {
+	var sawAd bool
+	inspect := processor.Func("inspect", func(ctx context.Context, page *processor.Page) error {
+		sawAd = sawAd || page.Content.Find(".cgo, .google-auto-placed").Length() > 0
+		return nil
+	})
+	replace := &processor.RegexReplace{Pattern: regexp.MustCompile("少年"), Replacement: "青年"}
+
+	b, _ := newTestBilinovel(t, bilinovel.WithNoBrowser(true), bilinovel.WithProcessors(inspect, replace))
+	if n := len(b.Processors()); n != len(b.DefaultProcessors())+2 {
+		t.Fatalf("expected default processors plus 2, got %v", n)
+	}
+	chapter, err := b.GetChapter(testNovelId, testVolumeId, testChapterId)
+	if err != nil {
+		t.Fatalf("failed to get chapter: %v", err)
+	}
+	if sawAd {
+		t.Errorf("expected ads to be removed before custom processors run")
+	}
+	if strings.Contains(chapter.Content.Html, "少年") || !strings.Contains(chapter.Content.Html, "青年") {
+		t.Errorf("expected replacement to be applied: %v", chapter.Content.Html)
+	}
+}
@@ -0,0 +1 @@
+!function(){var _0x4e1a=document.getElementById('acontent');if(_0x4e1a){var _0x2b7c=parseInt(ReadParams.chapterid,0xa),_0x5d03=Array.prototype.slice.call(_0x4e1a.getElementsByTagName('p'));if(!(_0x5d03.length<=0x14)){for(var _0x1f9e=_0x2b7c*0x7f+0xeb,_0x3c42=[],_0x17a8=0x0;_0x17a8<_0x5d03.length;_0x17a8++)_0x3c42.push(_0x17a8);var _0x41d6=_0x3c42.slice(0x0,0x14),_0x22e5=_0x3c42.slice(0x14);for(_0x17a8=_0x22e5.length-0x1;_0x17a8>0x0;_0x17a8--){_0x1f9e=(_0x1f9e*0x2456+0xc0f5)%0x38f40;var _0x58b1=Math.floor(_0x1f9e/0x38f40*(_0x17a8+0x1)),_0x6a0f=_0x22e5[_0x17a8];_0x22e5[_0x17a8]=_0x22e5[_0x58b1],_0x22e5[_0x58b1]=_0x6a0f}_0x3c42=_0x41d6.concat(_0x22e5);var _0x2f71=[];for(_0x17a8=0x0;_0x17a8<_0x5d03.length;_0x17a8++)_0x2f71[_0x3c42[_0x17a8]]=_0x5d03[_0x17a8];for(_0x17a8=0x0;_0x17a8<_0x2f71.length;_0x17a8++)_0x4e1a.appendChild(_0x2f71[_0x17a8])}}}();
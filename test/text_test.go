@@ -0,0 +1,114 @@
+package test
+
+import (
+	"bilinovel-downloader/model"
+	"bilinovel-downloader/text"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestText_HTMLToText(t *testing.T) {
+	input := `<div class="divimage"><img src="a.png"/></div>
+	<p>　　第一段。</p>
+	<p>第二段第一行<br/>第二段第二行</p>
+
+	<p>　</p>
+	<p>後記<br><br><br>完</p><script>alert(1)</script>`
+
+	got, err := text.HTMLToText(input, text.DefaultOptions())
+	if err != nil {
+		t.Fatalf("failed to convert: %v", err)
+	}
+	want := strings.Join([]string{
+		"[插图]",
+		"　　第一段。",
+		"　　第二段第一行",
+		"　　第二段第二行",
+		"　　後記",
+		"",
+		"　　完",
+	}, "\n")
+	if got != want {
+		t.Errorf("unexpected text:\ngot\n%v\nwant\n%v", got, want)
+	}
+
+	got, err = text.HTMLToText(input, text.Options{Indent: "  "})
+	if err != nil {
+		t.Fatalf("failed to convert: %v", err)
+	}
+	if !strings.HasPrefix(got, "  第一段。\n") {
+		t.Errorf("expected custom indent and no image placeholder, got\n%v", got)
+	}
+}
+
+func TestText_PackSingleFile(t *testing.T) {
+	volume := loadGoldenVolume(t)
+	outputPath := t.TempDir()
+	if err := text.PackVolumeToSingleText(volume, outputPath, text.DefaultOptions()); err != nil {
+		t.Fatalf("failed to pack volume: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(outputPath, "測試輕小說 第一卷.txt"))
+	if err != nil {
+		t.Fatalf("failed to read text: %v", err)
+	}
+	want := strings.Join([]string{
+		"測試輕小說 第一卷",
+		"",
+		"插圖",
+		"",
+		"[插图]",
+		"",
+		"＊　＊　＊",
+		"",
+		"第一章 開端",
+		"",
+		"　　故事從一個平凡的早晨開始。",
+		"　　少年推開窗，看見了那隻白色的貓。",
+		"　　貓沒有逃走，只是靜靜地看著他。",
+		"　　「早安。」少年說。",
+		"",
+		"＊　＊　＊",
+		"",
+		"第二章 謎題",
+		"",
+	}, "\n")
+	if !strings.HasPrefix(string(data), want) {
+		t.Errorf("unexpected text:\n%v", string(data))
+	}
+
+	novel := &model.Novel{Title: "測試輕小說", Volumes: []*model.Volume{volume, volume}}
+	if err := text.PackNovelToText(novel, outputPath, text.DefaultOptions()); err != nil {
+		t.Fatalf("failed to pack novel: %v", err)
+	}
+	data, err = os.ReadFile(filepath.Join(outputPath, "測試輕小說.txt"))
+	if err != nil {
+		t.Fatalf("failed to read text: %v", err)
+	}
+	if n := strings.Count(string(data), "\n測試輕小說 第一卷\n"); n != 2 {
+		t.Errorf("expected 2 volume headings, got %d", n)
+	}
+}
+
+func TestText_PackPerChapter(t *testing.T) {
+	volume := loadGoldenVolume(t)
+	outputPath := t.TempDir()
+	if err := text.PackVolumeToText(volume, outputPath, text.DefaultOptions()); err != nil {
+		t.Fatalf("failed to pack volume: %v", err)
+	}
+	entries, err := os.ReadDir(filepath.Join(outputPath, "測試輕小說 第一卷"))
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	if len(entries) != len(volume.Chapters) {
+		t.Fatalf("expected %d chapter files, got %d", len(volume.Chapters), len(entries))
+	}
+	data, err := os.ReadFile(filepath.Join(outputPath, "測試輕小說 第一卷", "001-第一章 開端.txt"))
+	if err != nil {
+		t.Fatalf("failed to read chapter: %v", err)
+	}
+	if !strings.HasPrefix(string(data), "　　故事從一個平凡的早晨開始。\n　　少年推開窗") {
+		t.Errorf("unexpected chapter text:\n%v", string(data))
+	}
+}
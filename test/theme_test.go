@@ -0,0 +1,95 @@
+package test
+
+import (
+	"bilinovel-downloader/epub"
+	"bilinovel-downloader/theme"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTheme_Builtin(t *testing.T) {
+	seen := make(map[string]string)
+	for _, name := range theme.Names {
+		th, err := theme.Load(name, "body{}")
+		if err != nil {
+			t.Fatalf("failed to load theme %v: %v", name, err)
+		}
+		if th.CSS == "" || len(th.ExtraFiles) != 0 {
+			t.Errorf("unexpected theme %v: %+v", name, th)
+		}
+		if other, ok := seen[th.CSS]; ok {
+			t.Errorf("themes %v and %v have the same css", name, other)
+		}
+		seen[th.CSS] = name
+	}
+	if th, _ := theme.Load("default", "body{}"); th.CSS != "body{}" {
+		t.Errorf("expected default theme to use the downloader css, got %q", th.CSS)
+	}
+	if _, err := theme.Load("no-such-theme", "body{}"); err == nil {
+		t.Errorf("expected error for unknown theme")
+	}
+}
+
+func TestTheme_UserDirectory(t *testing.T) {
+	dir := t.TempDir()
+	cssPath := filepath.Join(dir, "custom.css")
+	if err := os.WriteFile(cssPath, []byte("p{color:red}"), 0644); err != nil {
+		t.Fatalf("failed to write css: %v", err)
+	}
+	if th, err := theme.Load(cssPath, "body{}"); err != nil || th.CSS != "p{color:red}" {
+		t.Errorf("unexpected css file theme: %+v, %v", th, err)
+	}
+
+	themeDir := filepath.Join(dir, "mytheme")
+	font, err := os.ReadFile(filepath.Join("..", "fontembed", "MI LANTING.ttf"))
+	if err != nil {
+		t.Fatalf("failed to read font: %v", err)
+	}
+	files := map[string]string{
+		"style.css":          `@font-face{font-family:"Body";src:url(fonts/body.ttf)}p{font-family:"Body"}`,
+		"fonts/body.ttf":     string(font),
+		"fonts/LICENSE.txt":  "license",
+		"Heading Font.woff2": "wOF2",
+	}
+	for name, content := range files {
+		p := filepath.Join(themeDir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatalf("failed to create theme directory: %v", err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %v: %v", name, err)
+		}
+	}
+	th, err := theme.Load(themeDir, "body{}")
+	if err != nil {
+		t.Fatalf("failed to load theme directory: %v", err)
+	}
+	if len(th.ExtraFiles) != 2 {
+		t.Fatalf("expected two fonts, got %+v", th.ExtraFiles)
+	}
+	if item := th.ExtraFiles[1].ManifestItem; item.ID != "theme-fonts-body.ttf" || item.Link != "fonts/body.ttf" || item.Media != "font/ttf" {
+		t.Errorf("unexpected font manifest item: %+v", item)
+	}
+
+	outputPath := t.TempDir()
+	if err := epub.PackVolumeToEpub(loadGoldenVolume(t), outputPath, th.CSS, th.ExtraFiles, epub.Options{}); err != nil {
+		t.Fatalf("failed to pack volume: %v", err)
+	}
+	epubPath := filepath.Join(outputPath, "測試輕小說 第一卷.epub")
+	zipFiles, _ := readZip(t, epubPath)
+	if zipFiles["style.css"] != files["style.css"] || zipFiles["fonts/body.ttf"] != string(font) {
+		t.Errorf("expected theme css and font in epub")
+	}
+	if !strings.Contains(zipFiles["content.opf"], `href="fonts/body.ttf"`) {
+		t.Errorf("expected font in manifest")
+	}
+	issues, err := epub.Validate(epubPath)
+	if err != nil {
+		t.Fatalf("failed to validate: %v", err)
+	}
+	for _, issue := range issues {
+		t.Errorf("unexpected issue: %v", issue)
+	}
+}
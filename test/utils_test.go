@@ -0,0 +1,60 @@
+package test
+
+import (
+	"bilinovel-downloader/utils"
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParallelFor_OrderAndLimit(t *testing.T) {
+	const n, limit = 32, 4
+	results := make([]int, n)
+	var running, peak atomic.Int32
+
+	err := utils.ParallelFor(context.Background(), limit, n, func(ctx context.Context, i int) error {
+		cur := running.Add(1)
+		defer running.Add(-1)
+		for {
+			old := peak.Load()
+			if cur <= old || peak.CompareAndSwap(old, cur) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		results[i] = i * i
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if peak.Load() > limit {
+		t.Errorf("expected at most %v concurrent tasks, got %v", limit, peak.Load())
+	}
+	for i, v := range results {
+		if v != i*i {
+			t.Fatalf("results[%v] = %v, want %v", i, v, i*i)
+		}
+	}
+}
+
+func TestParallelFor_StopsOnError(t *testing.T) {
+	wantErr := errors.New("boom")
+	var started atomic.Int32
+
+	err := utils.ParallelFor(context.Background(), 1, 10, func(ctx context.Context, i int) error {
+		started.Add(1)
+		if i == 2 {
+			return wantErr
+		}
+		return nil
+	})
+	if !errors.Is(err, wantErr) {
+		t.Fatalf("expected %v, got %v", wantErr, err)
+	}
+	if started.Load() > 4 {
+		t.Errorf("expected remaining tasks to be skipped, %v started", started.Load())
+	}
+}
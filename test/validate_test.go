@@ -0,0 +1,141 @@
+package test
+
+import (
+	"archive/zip"
+	"bilinovel-downloader/epub"
+	"bilinovel-downloader/kepub"
+	"bilinovel-downloader/model"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestValidate_PackedOutput(t *testing.T) {
+	outputPath := t.TempDir()
+	volume := loadGoldenVolume(t)
+	if err := epub.PackVolumeToEpub(volume, outputPath, "body{}", nil, epub.Options{EPUB2Compat: true}); err != nil {
+		t.Fatalf("failed to pack volume: %v", err)
+	}
+	if err := kepub.PackVolumeToKepub(loadGoldenVolume(t), filepath.Join(outputPath, "kepub"), "body{}", nil, epub.Options{}); err != nil {
+		t.Fatalf("failed to pack kepub: %v", err)
+	}
+	novel := &model.Novel{Title: "測試輕小說", Volumes: []*model.Volume{loadGoldenVolume(t), loadGoldenVolume(t)}}
+	if err := epub.PackNovelToEpub(novel, outputPath, "body{}", nil, epub.Options{}); err != nil {
+		t.Fatalf("failed to pack novel: %v", err)
+	}
+
+	for _, name := range []string{"測試輕小說 第一卷.epub", "kepub/測試輕小說 第一卷.kepub.epub", "測試輕小說.epub"} {
+		issues, err := epub.Validate(filepath.Join(outputPath, name))
+		if err != nil {
+			t.Fatalf("failed to validate %v: %v", name, err)
+		}
+		for _, issue := range issues {
+			t.Errorf("%v: %v", name, issue)
+		}
+	}
+}
+
+// writeBrokenEpub 写入一个违反多条规则的 EPUB
+func writeBrokenEpub(t *testing.T, epubPath string) {
+	t.Helper()
+	f, err := os.Create(epubPath)
+	if err != nil {
+		t.Fatalf("failed to create epub: %v", err)
+	}
+	w := zip.NewWriter(f)
+	entries := []struct {
+		name    string
+		content string
+	}{
+		// mimetype 不是第一个条目，且被压缩
+		{"META-INF/container.xml", `<?xml version="1.0"?><container xmlns="urn:oasis:names:tc:opendocument:xmlns:container" version="1.0"><rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/></rootfiles></container>`},
+		{"mimetype", "application/epub+zip"},
+		{"OEBPS/content.opf", `<?xml version="1.0"?><package xmlns="http://www.idpf.org/2007/opf" xmlns:dc="http://purl.org/dc/elements/1.1/" version="3.0" unique-identifier="id">` +
+			`<metadata><dc:identifier id="id">urn:uuid:1</dc:identifier><dc:title>t</dc:title><dc:language>zh</dc:language><meta property="dcterms:modified">2025-01-01T00:00:00Z</meta></metadata>` +
+			`<manifest>` +
+			`<item id="a" href="a.xhtml" media-type="application/xhtml+xml"/>` +
+			`<item id="a" href="b.xhtml" media-type="application/xhtml+xml"/>` +
+			`<item id="missing" href="missing.xhtml" media-type="application/xhtml+xml"/>` +
+			`<item id="img" href="img.png" media-type="image/jpeg"/>` +
+			`<item id="css" href="style.css" media-type="text/plain"/>` +
+			`</manifest><spine><itemref idref="a"/><itemref idref="nope"/><itemref idref="css"/></spine></package>`},
+		{"OEBPS/a.xhtml", `<html xmlns="http://www.w3.org/1999/xhtml"><body><p id="x">1</p><p id="x">2</p><img src="img.png"/><img src="other.png"/><img src="gone.png"/></body></html>`},
+		{"OEBPS/b.xhtml", `<html xmlns="http://www.w3.org/1999/xhtml"><body><p>unclosed</body></html>`},
+		{"OEBPS/img.png", "\x89PNG\r\n\x1a\n0000"},
+		{"OEBPS/other.png", "\x89PNG\r\n\x1a\n0000"},
+		{"OEBPS/style.css", "body{}"},
+	}
+	for _, entry := range entries {
+		writer, err := w.Create(entry.name)
+		if err != nil {
+			t.Fatalf("failed to create %v: %v", entry.name, err)
+		}
+		writer.Write([]byte(entry.content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("failed to close zip: %v", err)
+	}
+	f.Close()
+}
+
+func TestValidate_BrokenEpub(t *testing.T) {
+	epubPath := filepath.Join(t.TempDir(), "broken.epub")
+	writeBrokenEpub(t, epubPath)
+
+	issues, err := epub.Validate(epubPath)
+	if err != nil {
+		t.Fatalf("failed to validate: %v", err)
+	}
+	var got []string
+	for _, issue := range issues {
+		got = append(got, issue.Rule+" "+issue.Path)
+	}
+	sort.Strings(got)
+	want := []string{
+		"duplicate-id OEBPS/a.xhtml",
+		"duplicate-id OEBPS/content.opf",
+		"media-type OEBPS/img.png",
+		"media-type OEBPS/style.css",
+		"mimetype mimetype",
+		"mimetype mimetype",
+		"missing-resource OEBPS/content.opf",
+		"nav OEBPS/content.opf",
+		"reference OEBPS/a.xhtml",
+		"reference OEBPS/a.xhtml",
+		"spine OEBPS/content.opf",
+		"spine OEBPS/content.opf",
+		"well-formed OEBPS/b.xhtml",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected issues:\ngot:\n%v\nwant:\n%v\ndetails: %v", strings.Join(got, "\n"), strings.Join(want, "\n"), issues)
+	}
+}
+
+func TestValidate_ExitStatus(t *testing.T) {
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "bilinovel-downloader")
+	if out, err := exec.Command("go", "build", "-o", binary, "bilinovel-downloader").CombinedOutput(); err != nil {
+		t.Fatalf("failed to build: %v\n%s", err, out)
+	}
+	if err := epub.PackVolumeToEpub(loadGoldenVolume(t), dir, "body{}", nil, epub.Options{}); err != nil {
+		t.Fatalf("failed to pack volume: %v", err)
+	}
+	brokenPath := filepath.Join(dir, "broken.epub")
+	writeBrokenEpub(t, brokenPath)
+
+	if out, err := exec.Command(binary, "validate", filepath.Join(dir, "測試輕小說 第一卷.epub")).CombinedOutput(); err != nil {
+		t.Errorf("expected valid epub to pass: %v\n%s", err, out)
+	}
+	out, err := exec.Command(binary, "validate", brokenPath).CombinedOutput()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+		t.Errorf("expected exit status 1 for broken epub, got %v\n%s", err, out)
+	}
+	if !strings.Contains(string(out), "duplicate-id") {
+		t.Errorf("expected report in output:\n%s", out)
+	}
+}
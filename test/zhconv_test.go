@@ -0,0 +1,256 @@
+package test
+
+import (
+	"bilinovel-downloader/cbz"
+	"bilinovel-downloader/epub"
+	"bilinovel-downloader/fb2"
+	"bilinovel-downloader/htmlbook"
+	"bilinovel-downloader/zhconv"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestZhconv_Convert(t *testing.T) {
+	toTraditional, err := zhconv.New("traditional")
+	if err != nil {
+		t.Fatalf("failed to create converter: %v", err)
+	}
+	toSimplified, err := zhconv.New("zh-CN")
+	if err != nil {
+		t.Fatalf("failed to create converter: %v", err)
+	}
+	if toTraditional.Language() != "zh-TW" || toSimplified.Language() != "zh-CN" {
+		t.Errorf("unexpected languages %v and %v", toTraditional.Language(), toSimplified.Language())
+	}
+
+	tests := []struct {
+		converter *zhconv.Converter
+		input     string
+		want      string
+	}{
+		{toTraditional, "她的头发很长，出发前去理发。", "她的頭髮很長，出發前去理髮。"},
+		{toTraditional, "皇后在房间里，以后再说。", "皇后在房間裡，以後再說。"},
+		{toTraditional, "干净的饼干，干扰他干活。", "乾淨的餅乾，干擾他幹活。"},
+		{toTraditional, "一只猫吃了面包，只是看着他。", "一隻貓吃了麵包，只是看著他。"},
+		{toTraditional, "关系很复杂，联系一下。", "關係很複雜，聯繫一下。"},
+		{toTraditional, "这是唯一的游戏。", "這是唯一的遊戲。"},
+		{toTraditional, "Chapter 1: 启程", "Chapter 1: 啟程"},
+		{toSimplified, "故事從一個平凡的早晨開始。", "故事从一个平凡的早晨开始。"},
+		{toSimplified, "這是著名的著作，他看著窗外。", "这是著名的著作，他看着窗外。"},
+		{toSimplified, "乾隆說房間很乾淨，妳知道甚麼？", "乾隆说房间很干净，你知道什么？"},
+	}
+	for _, tt := range tests {
+		if got := tt.converter.Convert(tt.input); got != tt.want {
+			t.Errorf("Convert(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+
+	if _, err := zhconv.New("klingon"); err == nil {
+		t.Errorf("expected error for unknown variant")
+	}
+}
+
+// 内置词典只覆盖常见词组，这里逐个检查最常见的一简对多繁与繁体保留字的两种读法
+func TestZhconv_AmbiguousCharacters(t *testing.T) {
+	toTraditional, err := zhconv.New("traditional")
+	if err != nil {
+		t.Fatalf("failed to create converter: %v", err)
+	}
+	toSimplified, err := zhconv.New("simplified")
+	if err != nil {
+		t.Fatalf("failed to create converter: %v", err)
+	}
+
+	traditional := map[string]string{
+		"发": "發現 頭髮 金髮 出發 髮型",
+		"后": "後來 之後 皇后 王后",
+		"里": "這裡 房間裡 公里 故里",
+		"干": "乾淨 餅乾 幹活 能幹 干擾 若干",
+		"只": "一隻 兩隻 只是 只有 只要",
+		"面": "麵包 拉麵 麵糰 面前 表面",
+		"系": "關係 聯繫 繫上 系統",
+		"台": "颱風 櫃檯 檯燈",
+		"复": "複雜 重複 恢復 回覆 反覆",
+		"了": "瞭解 明瞭 走了",
+		"着": "看著 睡著 著急",
+		"么": "什麼 怎麼 那麼",
+		"历": "歷史 經歷 日曆 農曆",
+		"钟": "鐘錶 一見鍾情",
+		"冲": "衝突 衝過去 沖澡",
+		"松": "放鬆 輕鬆 松樹",
+		"斗": "戰鬥 鬥爭 北斗 斗篷",
+		"谷": "山谷 穀物",
+		"云": "白雲 人云亦云",
+		"余": "其餘 多餘",
+		"准": "準備 標準 批准",
+		"丑": "醜陋 小丑",
+		"卷": "捲入 試卷",
+		"制": "製造 制度 制服",
+		"征": "特徵 征服",
+		"尽": "儘管 盡力",
+		"签": "簽名 標籤",
+		"脏": "骯髒 心臟",
+		"须": "必須 鬍鬚",
+		"表": "手錶 表示",
+		"采": "採取 風采",
+		"划": "計劃 划船",
+		"团": "團體 飯糰",
+		"致": "精緻 導致",
+		"几": "幾個 茶几",
+		"困": "困難 很困難 犯睏",
+		"恶": "噁心 惡魔",
+		"游": "遊戲 旅遊 游泳",
+		"周": "周圍 一週 週末",
+		"借": "藉口 借書",
+		"伙": "傢伙 夥伴",
+		"迹": "奇蹟 痕跡",
+		"布": "宣佈 分佈 布料 桌布下面",
+		"御": "防禦 御姐",
+		"沉": "沉默 沉澱",
+	}
+	// 简转繁：按期望的繁体生成简体输入，每个字都检查两种读法
+	for char, words := range traditional {
+		for _, want := range strings.Fields(words) {
+			input := toSimplified.Convert(want)
+			if got := toTraditional.Convert(input); got != want {
+				t.Errorf("%v: Convert(%q) = %q, want %q", char, input, got, want)
+			}
+		}
+	}
+
+	// 繁转简：乾、著、覆、藉等字只在部分词中保留
+	simplified := []struct{ input, want string }{
+		{"乾淨", "干净"}, {"乾隆", "乾隆"}, {"扭轉乾坤", "扭转乾坤"},
+		{"看著", "看着"}, {"著名", "著名"}, {"顯著", "显著"},
+		{"回覆", "回复"}, {"覆蓋", "覆盖"}, {"顛覆", "颠覆"},
+		{"藉口", "借口"}, {"慰藉", "慰藉"},
+		{"甚麼", "什么"}, {"妳", "你"},
+		{"特徵", "特征"}, {"宮商角徵羽", "宫商角徵羽"},
+		{"瀋陽", "沈阳"},
+	}
+	for _, tt := range simplified {
+		if got := toSimplified.Convert(tt.input); got != tt.want {
+			t.Errorf("Convert(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestZhconv_ConvertHTML(t *testing.T) {
+	converter, err := zhconv.New("simplified")
+	if err != nil {
+		t.Fatalf("failed to create converter: %v", err)
+	}
+	input := `<p class="說明" title="頭髮">「頭髮」&amp;開始</p><img src="貓.png" alt="貓"/><script>var s = "開始";</script><br/>結束`
+	want := `<p class="說明" title="頭髮">「头发」&amp;开始</p><img src="貓.png" alt="貓"/><script>var s = "開始";</script><br/>结束`
+	if got := converter.ConvertHTML(input); got != want {
+		t.Errorf("ConvertHTML:\ngot:  %v\nwant: %v", got, want)
+	}
+}
+
+func TestZhconv_PackVolume(t *testing.T) {
+	simplified, err := zhconv.New("simplified")
+	if err != nil {
+		t.Fatalf("failed to create converter: %v", err)
+	}
+	original := loadGoldenVolume(t)
+	volume := simplified.ConvertVolume(original)
+	if volume.Title != "测试轻小说 第一卷" || volume.NovelTitle != "测试轻小说" || volume.Authors[0] != "测试作者" || volume.Description != "第一卷的简介。" {
+		t.Errorf("unexpected metadata: %v %v %v %v", volume.Title, volume.NovelTitle, volume.Authors, volume.Description)
+	}
+	if volume.Chapters[1].Title != "第一章 开端" {
+		t.Errorf("unexpected chapter title %v", volume.Chapters[1].Title)
+	}
+	// 原卷保持不变，缓存与快照仍使用原文
+	if original.Title != "測試輕小說 第一卷" || original.Chapters[1].Title != "第一章 開端" || !strings.Contains(original.Chapters[1].Content.Html, "那隻白色的貓") {
+		t.Errorf("original volume was modified")
+	}
+
+	traditional, err := zhconv.New("traditional")
+	if err != nil {
+		t.Fatalf("failed to create converter: %v", err)
+	}
+	outputPath := t.TempDir()
+	for _, v := range []struct {
+		converter *zhconv.Converter
+		file      string
+		text      string
+	}{
+		{simplified, "测试轻小说 第一卷.epub", "看见了那只白色的猫"},
+		{traditional, "測試輕小說 第一卷.epub", "看見了那隻白色的貓"},
+	} {
+		if err := epub.PackVolumeToEpub(v.converter.ConvertVolume(loadGoldenVolume(t)), outputPath, "body{}", nil, epub.Options{}); err != nil {
+			t.Fatalf("failed to pack volume: %v", err)
+		}
+		epubPath := filepath.Join(outputPath, v.file)
+		files, _ := readZip(t, epubPath)
+		if !strings.Contains(files["content.opf"], "<dc:language>"+v.converter.Language()+"</dc:language>") {
+			t.Errorf("%v: expected dc:language %v", v.file, v.converter.Language())
+		}
+		found := false
+		for name, content := range files {
+			if strings.HasSuffix(name, ".xhtml") && strings.Contains(content, v.text) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("%v: expected converted chapter text %q", v.file, v.text)
+		}
+		issues, err := epub.Validate(epubPath)
+		if err != nil {
+			t.Fatalf("failed to validate: %v", err)
+		}
+		for _, issue := range issues {
+			t.Errorf("%v: unexpected issue: %v", v.file, issue)
+		}
+	}
+}
+
+func TestZhconv_OutputLanguage(t *testing.T) {
+	simplified, err := zhconv.New("simplified")
+	if err != nil {
+		t.Fatalf("failed to create converter: %v", err)
+	}
+	traditional, err := zhconv.New("traditional")
+	if err != nil {
+		t.Fatalf("failed to create converter: %v", err)
+	}
+	for _, converter := range []*zhconv.Converter{simplified, traditional} {
+		lang := converter.Language()
+		volume := converter.ConvertVolume(loadGoldenVolume(t))
+		outputPath := t.TempDir()
+
+		if err := fb2.PackVolumeToFB2(volume, outputPath, false); err != nil {
+			t.Fatalf("failed to pack fb2: %v", err)
+		}
+		if err := htmlbook.PackVolumeToHTML(volume, outputPath, "body{}"); err != nil {
+			t.Fatalf("failed to pack html: %v", err)
+		}
+		if err := cbz.PackVolumeToCBZ(volume, outputPath); err != nil {
+			t.Fatalf("failed to pack cbz: %v", err)
+		}
+		if err := epub.PackVolumeToEpub(volume, outputPath, "body{}", nil, epub.Options{}); err != nil {
+			t.Fatalf("failed to pack epub: %v", err)
+		}
+
+		fb2Data, _ := os.ReadFile(filepath.Join(outputPath, volume.Title+".fb2"))
+		if !strings.Contains(string(fb2Data), "<lang>"+lang+"</lang>") {
+			t.Errorf("%v: expected fb2 lang", lang)
+		}
+		htmlData, _ := os.ReadFile(filepath.Join(outputPath, volume.Title+".html"))
+		if !strings.Contains(string(htmlData), `<html lang="`+lang+`">`) {
+			t.Errorf("%v: expected html lang", lang)
+		}
+		files, _ := readZip(t, filepath.Join(outputPath, volume.Title+".cbz"))
+		if !strings.Contains(files["ComicInfo.xml"], "<LanguageISO>"+lang+"</LanguageISO>") {
+			t.Errorf("%v: expected ComicInfo LanguageISO", lang)
+		}
+		files, _ = readZip(t, filepath.Join(outputPath, volume.Title+".epub"))
+		for name, content := range files {
+			if strings.HasSuffix(name, ".xhtml") && !strings.Contains(content, `xml:lang="`+lang+`"`) {
+				t.Errorf("%v: expected xml:lang in %v", lang, name)
+			}
+		}
+	}
+}
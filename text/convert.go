@@ -0,0 +1,102 @@
+package text
+
+import (
+	"bilinovel-downloader/htmltext"
+	"strings"
+)
+
+// Options 控制章节 HTML 转换为纯文本的方式
+type Options struct {
+	// Indent 为每段开头的缩进，原文自带的缩进会先去掉
+	Indent string
+	// ImagePlaceholder 替换图片所在的位置，为空时直接删除图片
+	ImagePlaceholder string
+	// Separator 单文件模式下插在章节之间的一行，为空时只用空行分隔
+	Separator string
+}
+
+// DefaultOptions 返回中文排版习惯的默认选项：两个全角空格缩进
+func DefaultOptions() Options {
+	return Options{
+		Indent:           "　　",
+		ImagePlaceholder: "[插图]",
+		Separator:        "＊　＊　＊",
+	}
+}
+
+type line struct {
+	text  string
+	image bool
+}
+
+// converter 把 HTML 拆成行：块级元素与 <br> 结束一行，图片单独成行
+type converter struct {
+	lines   []line
+	current strings.Builder
+}
+
+// flush 结束当前行，空行直接丢弃
+func (c *converter) flush() {
+	if text := trimLine(c.current.String()); text != "" {
+		c.lines = append(c.lines, line{text: text})
+	}
+	c.current.Reset()
+}
+
+func (c *converter) Text(text string) {
+	c.current.WriteString(text)
+}
+
+// LineBreak 对应 <br>，保留空行
+func (c *converter) LineBreak() {
+	c.lines = append(c.lines, line{text: trimLine(c.current.String())})
+	c.current.Reset()
+}
+
+func (c *converter) Image(src, alt string) {
+	c.flush()
+	c.lines = append(c.lines, line{image: true})
+}
+
+func (c *converter) StartBlock(tag string) { c.flush() }
+func (c *converter) EndBlock(tag string)   { c.flush() }
+
+func (c *converter) StartStyle(style htmltext.Style) {}
+func (c *converter) EndStyle(style htmltext.Style)   {}
+
+func trimLine(s string) string {
+	return strings.Trim(s, htmltext.SpaceChars)
+}
+
+// HTMLToText 将章节 HTML 转为纯文本：每段一行并加上缩进，<br> 换行，
+// 连续空行合并为一行，图片替换为占位文本
+func HTMLToText(htmlContent string, opts Options) (string, error) {
+	c := &converter{}
+	if err := htmltext.Walk(htmlContent, c); err != nil {
+		return "", err
+	}
+	c.flush()
+
+	var sb strings.Builder
+	blank := true
+	for _, l := range c.lines {
+		text := opts.Indent + l.text
+		if l.image {
+			if opts.ImagePlaceholder == "" {
+				continue
+			}
+			text = opts.ImagePlaceholder
+		}
+		if l.text == "" && !l.image {
+			if !blank {
+				sb.WriteString("\n")
+			}
+			blank = true
+			continue
+		}
+		sb.WriteString(text)
+		sb.WriteString("\n")
+		blank = false
+	}
+	return strings.TrimRight(sb.String(), "\n"), nil
+}
@@ -7,49 +7,105 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
-
-	"github.com/PuerkitoBio/goquery"
 )
 
-func PackVolumeToText(volume *model.Volume, outputPath string) error {
+// PackVolumeToText 每章输出一个 txt 文件，放在以卷名命名的目录中
+func PackVolumeToText(volume *model.Volume, outputPath string, opts Options) error {
 	outputPath = filepath.Join(outputPath, utils.CleanDirName(volume.Title))
-	_, err := os.Stat(outputPath)
+	err := os.RemoveAll(outputPath)
 	if err != nil {
-		if os.IsNotExist(err) {
-			err = os.MkdirAll(outputPath, 0755)
-			if err != nil {
-				return fmt.Errorf("failed to create output directory: %v", err)
-			}
-		} else {
-			return fmt.Errorf("failed to get output directory: %v", err)
-		}
-	} else {
-		err = os.RemoveAll(outputPath)
+		return fmt.Errorf("failed to remove output directory: %v", err)
+	}
+	err = os.MkdirAll(outputPath, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create output directory: %v", err)
+	}
+	for i, chapter := range volume.Chapters {
+		text, err := chapterText(chapter, opts)
 		if err != nil {
-			return fmt.Errorf("failed to remove output directory: %v", err)
+			return err
 		}
-		err = os.MkdirAll(outputPath, 0755)
+		chapterPath := filepath.Join(outputPath, fmt.Sprintf("%03d-%s.txt", i, utils.CleanDirName(chapter.Title)))
+		err = os.WriteFile(chapterPath, []byte(text+"\n"), 0644)
 		if err != nil {
-			return fmt.Errorf("failed to create output directory: %v", err)
+			return fmt.Errorf("failed to write chapter file: %v", err)
 		}
 	}
-	for i, chapter := range volume.Chapters {
-		chapterPath := filepath.Join(outputPath, fmt.Sprintf("%03d-%s.txt", i, chapter.Title))
-		chapterFile, err := os.Create(chapterPath)
-		if err != nil {
-			return fmt.Errorf("failed to create chapter file: %v", err)
+	return nil
+}
+
+// PackVolumeToSingleText 将整卷输出为 <卷名>.txt
+func PackVolumeToSingleText(volume *model.Volume, outputPath string, opts Options) error {
+	var sb strings.Builder
+	sb.WriteString(volume.Title)
+	sb.WriteString("\n\n")
+	if err := writeChapters(&sb, volume.Chapters, opts); err != nil {
+		return err
+	}
+	return writeTextFile(filepath.Join(outputPath, utils.CleanDirName(volume.Title)+".txt"), sb.String())
+}
+
+// PackNovelToText 将整部小说输出为 <书名>.txt，卷与卷之间同样用分隔行隔开
+func PackNovelToText(novel *model.Novel, outputPath string, opts Options) error {
+	var sb strings.Builder
+	sb.WriteString(novel.Title)
+	sb.WriteString("\n")
+	for _, volume := range novel.Volumes {
+		sb.WriteString("\n")
+		writeSeparator(&sb, opts)
+		sb.WriteString(volume.Title)
+		sb.WriteString("\n\n")
+		if err := writeChapters(&sb, volume.Chapters, opts); err != nil {
+			return err
 		}
-		defer chapterFile.Close()
-		doc, err := goquery.NewDocumentFromReader(strings.NewReader(chapter.Content.Html))
-		if err != nil {
-			return fmt.Errorf("failed to create chapter file: %v", err)
+	}
+	return writeTextFile(filepath.Join(outputPath, utils.CleanDirName(novel.Title)+".txt"), sb.String())
+}
+
+func writeChapters(sb *strings.Builder, chapters []*model.Chapter, opts Options) error {
+	for i, chapter := range chapters {
+		if i > 0 {
+			sb.WriteString("\n")
+			writeSeparator(sb, opts)
 		}
-		doc.Find("img").Remove()
-		text := doc.Text()
-		_, err = chapterFile.WriteString(strings.TrimSpace(text))
+		text, err := chapterText(chapter, opts)
 		if err != nil {
-			return fmt.Errorf("failed to write chapter file: %v", err)
+			return err
 		}
+		sb.WriteString(chapter.Title)
+		sb.WriteString("\n\n")
+		sb.WriteString(text)
+		sb.WriteString("\n")
+	}
+	return nil
+}
+
+func writeSeparator(sb *strings.Builder, opts Options) {
+	if opts.Separator != "" {
+		sb.WriteString(opts.Separator)
+		sb.WriteString("\n\n")
+	}
+}
+
+func chapterText(chapter *model.Chapter, opts Options) (string, error) {
+	if chapter.Content == nil {
+		return "", fmt.Errorf("chapter %v has no content", chapter.Title)
+	}
+	text, err := HTMLToText(chapter.Content.Html, opts)
+	if err != nil {
+		return "", fmt.Errorf("failed to convert chapter %v: %v", chapter.Title, err)
+	}
+	return text, nil
+}
+
+func writeTextFile(filePath string, text string) error {
+	err := os.MkdirAll(filepath.Dir(filePath), 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create output directory: %v", err)
+	}
+	err = os.WriteFile(filePath, []byte(text), 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write text file: %v", err)
 	}
 	return nil
 }
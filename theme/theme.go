@@ -0,0 +1,102 @@
+package theme
+
+import (
+	"bilinovel-downloader/model"
+	"embed"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+//go:embed themes/*.css
+var builtin embed.FS
+
+// Names 是内置主题的名称，default 使用下载器自带的样式
+var Names = []string{"default", "compact", "eink", "dark"}
+
+// 主题目录中随样式一起打包的字体
+var fontMediaTypes = map[string]string{
+	".ttf":   "font/ttf",
+	".otf":   "font/otf",
+	".woff":  "font/woff",
+	".woff2": "font/woff2",
+}
+
+var idRegexp = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+// Theme 是写入 EPUB 的样式以及它引用的字体
+type Theme struct {
+	CSS        string
+	ExtraFiles []model.ExtraFile
+}
+
+// Load 按名称加载内置主题，或者从 CSS 文件、主题目录加载用户主题。
+// 主题目录中必须有 style.css，目录下的字体按相对路径打包，样式中以相对路径引用即可
+func Load(name string, defaultCSS string) (*Theme, error) {
+	switch name {
+	case "", "default":
+		return &Theme{CSS: defaultCSS}, nil
+	}
+	if data, err := builtin.ReadFile(path.Join("themes", name+".css")); err == nil {
+		return &Theme{CSS: string(data)}, nil
+	}
+
+	st, err := os.Stat(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("unknown theme %q, use %v, a css file or a theme directory", name, strings.Join(Names, ", "))
+		}
+		return nil, fmt.Errorf("failed to stat theme: %v", err)
+	}
+	if !st.IsDir() {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read theme: %v", err)
+		}
+		return &Theme{CSS: string(data)}, nil
+	}
+	return loadDir(name)
+}
+
+func loadDir(dirPath string) (*Theme, error) {
+	data, err := os.ReadFile(filepath.Join(dirPath, "style.css"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read theme style.css: %v", err)
+	}
+	theme := &Theme{CSS: string(data)}
+	err = filepath.Walk(dirPath, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		media, ok := fontMediaTypes[strings.ToLower(filepath.Ext(filePath))]
+		if info.IsDir() || !ok {
+			return nil
+		}
+		relPath, err := filepath.Rel(dirPath, filePath)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+		theme.ExtraFiles = append(theme.ExtraFiles, model.ExtraFile{
+			Data: data,
+			Path: relPath,
+			ManifestItem: model.ManifestItem{
+				ID:    "theme-" + idRegexp.ReplaceAllString(relPath, "-"),
+				Link:  relPath,
+				Media: media,
+			},
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read theme fonts: %v", err)
+	}
+	return theme, nil
+}
@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 未设置 SOURCE_DATE_EPOCH 时使用的固定时间，也是 zip 条目能表示的最早时间
+var defaultBuildTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// BuildTime 返回写入输出文件的时间。设置了 SOURCE_DATE_EPOCH 时使用它，否则使用固定时间，
+// 保证相同的内容每次生成完全相同的文件
+func BuildTime() time.Time {
+	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
+		if sec, err := strconv.ParseInt(epoch, 10, 64); err == nil && sec >= defaultBuildTime.Unix() {
+			return time.Unix(sec, 0).UTC()
+		}
+	}
+	return defaultBuildTime
+}
+
+// VolumeUUID 由小说与卷的 ID 得到固定的标识符
+func VolumeUUID(novelId int, volumeId int) string {
+	return uuid.NewSHA1(uuid.NameSpaceURL, fmt.Appendf(nil, "bilinovel-downloader:novel/%d/volume/%d", novelId, volumeId)).String()
+}
+
+// NovelUUID 由小说 ID 得到整部小说（合集）固定的标识符
+func NovelUUID(novelId int) string {
+	return uuid.NewSHA1(uuid.NameSpaceURL, fmt.Appendf(nil, "bilinovel-downloader:novel/%d", novelId)).String()
+}
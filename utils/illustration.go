@@ -0,0 +1,15 @@
+package utils
+
+import "strings"
+
+var illustrationKeywords = []string{"插图", "插圖", "插畫", "插画", "口絵", "口绘"}
+
+// IsIllustration 根据章节标题判断是否为插图章节
+func IsIllustration(title string) bool {
+	for _, keyword := range illustrationKeywords {
+		if strings.Contains(title, keyword) {
+			return true
+		}
+	}
+	return false
+}
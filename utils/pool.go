@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"context"
+	"sync"
+)
+
+// ParallelFor 以最多 limit 个 goroutine 并发执行 fn(ctx, i)，i 取 0..n-1。
+// 结果应由 fn 按下标写入预先分配的切片以保证顺序；任一任务出错时取消其余任务并返回第一个错误。
+func ParallelFor(ctx context.Context, limit, n int, fn func(ctx context.Context, i int) error) error {
+	if limit < 1 {
+		limit = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+		sem      = make(chan struct{}, limit)
+	)
+loop:
+	for i := 0; i < n; i++ {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			break loop
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := fn(ctx, i); err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
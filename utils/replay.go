@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// FixturePath 将请求 URL 映射为夹具目录内的相对路径，忽略 host 与 query
+func FixturePath(u *url.URL) string {
+	p := strings.TrimPrefix(path.Clean("/"+u.Path), "/")
+	if p == "" || strings.HasSuffix(u.Path, "/") {
+		p = path.Join(p, "index.html")
+	}
+	return filepath.FromSlash(p)
+}
+
+// RecordTransport 把成功的响应按 URL 路径写入 Dir，用于录制离线测试夹具
+type RecordTransport struct {
+	Dir  string
+	Next http.RoundTripper
+}
+
+func (t *RecordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.Next.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	fixturePath := filepath.Join(t.Dir, FixturePath(req.URL))
+	if err := os.MkdirAll(filepath.Dir(fixturePath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create fixture directory: %w", err)
+	}
+	if err := os.WriteFile(fixturePath, body, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write fixture: %w", err)
+	}
+	return resp, nil
+}
+
+// ReplayTransport 把所有非本机请求（包括图片 CDN 等其他 host）改写到 Target，通常是本地 httptest 服务器
+type ReplayTransport struct {
+	Target *url.URL
+	Next   http.RoundTripper
+}
+
+func (t *ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if ip := net.ParseIP(req.URL.Hostname()); req.URL.Hostname() == "localhost" || (ip != nil && ip.IsLoopback()) {
+		return t.Next.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	req.URL.Scheme = t.Target.Scheme
+	req.URL.Host = t.Target.Host
+	req.Host = t.Target.Host
+	return t.Next.RoundTrip(req)
+}
+
+// NewFixtureHandler 返回按 FixturePath 规则从 dir 读取夹具的 http.Handler
+func NewFixtureHandler(dir string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := os.ReadFile(filepath.Join(dir, FixturePath(r.URL)))
+		if err != nil {
+			if os.IsNotExist(err) {
+				http.NotFound(w, r)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		contentType := mime.TypeByExtension(path.Ext(r.URL.Path))
+		if contentType == "" {
+			contentType = http.DetectContentType(data)
+		}
+		w.Header().Set("Content-Type", contentType)
+		_, _ = w.Write(data)
+	})
+}
@@ -2,17 +2,22 @@ package utils
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"net/url"
+	"sync"
 	"time"
 
 	"github.com/go-resty/resty/v2"
 )
 
 type RestyClient struct {
-	client      *resty.Client
-	concurrency int
-	sem         chan struct{}
+	client        *resty.Client
+	concurrency   int
+	sem           chan struct{}
+	hostOverrides map[string]string
 }
 
 func NewRestyClient(concurrency int) *RestyClient {
@@ -21,26 +26,18 @@ func NewRestyClient(concurrency int) *RestyClient {
 		concurrency: concurrency,
 		sem:         make(chan struct{}, concurrency),
 	}
-	client.client.SetTransport(&http.Transport{
-		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-			if addr == "www.bilinovel.com:443" {
-				addr = "64.140.161.52:443"
-			}
-			return (&net.Dialer{
-				Timeout: 10 * time.Second,
-			}).DialContext(ctx, network, addr)
+	client.client.SetTransport(&semTransport{
+		sem: client.sem,
+		next: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				addr = client.overrideAddr(addr)
+				return (&net.Dialer{
+					Timeout: 10 * time.Second,
+				}).DialContext(ctx, network, addr)
+			},
+			TLSHandshakeTimeout: 10 * time.Second,
 		},
-		TLSHandshakeTimeout: 10 * time.Second,
 	})
-	client.client.
-		OnBeforeRequest(func(c *resty.Client, req *resty.Request) error {
-			client.sem <- struct{}{}
-			return nil
-		}).
-		OnAfterResponse(func(c *resty.Client, resp *resty.Response) error {
-			<-client.sem
-			return nil
-		})
 	client.client.SetRetryCount(10).
 		SetRetryWaitTime(3 * time.Second).
 		SetRetryAfter(func(client *resty.Client, resp *resty.Response) (time.Duration, error) {
@@ -69,6 +66,79 @@ func (c *RestyClient) R() *resty.Request {
 	return c.client.R()
 }
 
+// SetHostOverrides 设置域名到 IP（可带端口）的固定映射，绕过 DNS 直连，需在发起请求前调用
+func (c *RestyClient) SetHostOverrides(overrides map[string]string) {
+	c.hostOverrides = overrides
+}
+
+func (c *RestyClient) overrideAddr(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	target, ok := c.hostOverrides[host]
+	if !ok || target == "" {
+		return addr
+	}
+	if _, _, err := net.SplitHostPort(target); err == nil {
+		return target
+	}
+	return net.JoinHostPort(target, port)
+}
+
+// Record 将之后所有成功的响应保存到 dir，供 Replay 离线回放
+func (c *RestyClient) Record(dir string) {
+	c.client.SetTransport(&RecordTransport{
+		Dir:  dir,
+		Next: c.client.GetClient().Transport,
+	})
+}
+
+// Replay 将之后所有请求改写到 target（如 httptest 服务器地址）
+func (c *RestyClient) Replay(target string) error {
+	u, err := url.Parse(target)
+	if err != nil {
+		return fmt.Errorf("failed to parse replay target: %w", err)
+	}
+	c.client.SetTransport(&ReplayTransport{
+		Target: u,
+		Next:   c.client.GetClient().Transport,
+	})
+	return nil
+}
+
+// semTransport 限制同时进行的请求数，等待名额时响应请求的 context 取消
+type semTransport struct {
+	sem  chan struct{}
+	next http.RoundTripper
+}
+
+func (t *semTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	select {
+	case t.sem <- struct{}{}:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		<-t.sem
+		return nil, err
+	}
+	// 读完响应体后才释放名额
+	resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: sync.OnceFunc(func() { <-t.sem })}
+	return resp, nil
+}
+
+type releaseOnClose struct {
+	io.ReadCloser
+	release func()
+}
+
+func (r *releaseOnClose) Close() error {
+	defer r.release()
+	return r.ReadCloser.Close()
+}
+
 type disableLogger struct{}
 
 func (d disableLogger) Errorf(string, ...interface{}) {}
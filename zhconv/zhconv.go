@@ -0,0 +1,234 @@
+// Package zhconv 在简体与繁体中文之间转换文本。
+// 词典采用 OpenCC 的格式，字表由 ICU 的 Hans-Hant/Hant-Hans 规则导出，
+// 词组用于处理一简对多繁（如 发→發/髮、里→裡/里）以及繁体中不应转换的用字。
+// 内置词组只有约一千二百条，覆盖轻小说中最常见的多义字，远少于 OpenCC 的完整词典，
+// 未收录的词按字表的默认字转换，少见词、专有名词与台湾用词（如 软件→軟體）不会处理
+package zhconv
+
+import (
+	"bilinovel-downloader/model"
+	"bufio"
+	"bytes"
+	"embed"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+)
+
+//go:embed dict/*.txt
+var dicts embed.FS
+
+// Variants 是支持的目标变体
+var Variants = []string{"simplified", "traditional"}
+
+// dictionary 是一个方向的词组与单字转换表
+type dictionary struct {
+	phrases map[string]string
+	chars   map[rune]string
+	// 词组首字，用于快速跳过不可能匹配词组的位置
+	starts map[rune]bool
+	maxLen int
+}
+
+var (
+	toTraditional = sync.OnceValues(func() (*dictionary, error) {
+		return loadDictionary("STPhrases.txt", "STCharacters.txt")
+	})
+	toSimplified = sync.OnceValues(func() (*dictionary, error) {
+		return loadDictionary("TSPhrases.txt", "TSCharacters.txt")
+	})
+)
+
+// Converter 将文本转换为简体或繁体
+type Converter struct {
+	language string
+	dict     *dictionary
+}
+
+// New 按目标变体创建转换器，variant 为 simplified（zh-CN）或 traditional（zh-TW）
+func New(variant string) (*Converter, error) {
+	var language string
+	var dict *dictionary
+	var err error
+	switch strings.ToLower(variant) {
+	case "simplified", "zh-cn", "hans":
+		language = "zh-CN"
+		dict, err = toSimplified()
+	case "traditional", "zh-tw", "hant":
+		language = "zh-TW"
+		dict, err = toTraditional()
+	default:
+		return nil, fmt.Errorf("unknown chinese variant %q, use %v", variant, strings.Join(Variants, " or "))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Converter{language: language, dict: dict}, nil
+}
+
+// Language 返回转换结果的语言标签，zh-CN 或 zh-TW
+func (c *Converter) Language() string {
+	return c.language
+}
+
+// Convert 按最长词组优先转换文本，未命中词组的字按字表转换
+func (c *Converter) Convert(s string) string {
+	runes := []rune(s)
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(runes); {
+		if c.dict.starts[runes[i]] {
+			matched := false
+			for n := min(c.dict.maxLen, len(runes)-i); n > 1; n-- {
+				if v, ok := c.dict.phrases[string(runes[i:i+n])]; ok {
+					b.WriteString(v)
+					i += n
+					matched = true
+					break
+				}
+			}
+			if matched {
+				continue
+			}
+		}
+		if v, ok := c.dict.chars[runes[i]]; ok {
+			b.WriteString(v)
+		} else {
+			b.WriteRune(runes[i])
+		}
+		i++
+	}
+	return b.String()
+}
+
+// ConvertHTML 只转换 HTML 的文本节点，标签、属性与 script/style 内容原样保留
+func (c *Converter) ConvertHTML(s string) string {
+	tokenizer := html.NewTokenizer(strings.NewReader(s))
+	var b strings.Builder
+	b.Grow(len(s))
+	rawText := ""
+	for {
+		tt := tokenizer.Next()
+		if tt == html.ErrorToken {
+			// io.EOF 之外的错误不会出现在 strings.Reader 上
+			if tokenizer.Err() != io.EOF {
+				return s
+			}
+			return b.String()
+		}
+		raw := string(tokenizer.Raw())
+		switch tt {
+		case html.TextToken:
+			if rawText == "" {
+				raw = c.Convert(raw)
+			}
+		case html.StartTagToken:
+			name, _ := tokenizer.TagName()
+			if tag := string(name); tag == "script" || tag == "style" {
+				rawText = tag
+			}
+		case html.EndTagToken:
+			rawText = ""
+		}
+		b.WriteString(raw)
+	}
+}
+
+// ConvertVolume 返回转换后的卷副本，原卷（以及它的缓存与快照）不受影响
+func (c *Converter) ConvertVolume(volume *model.Volume) *model.Volume {
+	converted := *volume
+	converted.Title = c.Convert(volume.Title)
+	converted.Description = c.Convert(volume.Description)
+	converted.NovelTitle = c.Convert(volume.NovelTitle)
+	converted.Authors = c.convertAll(volume.Authors)
+	converted.Language = c.language
+	converted.Chapters = make([]*model.Chapter, 0, len(volume.Chapters))
+	for _, chapter := range volume.Chapters {
+		ch := *chapter
+		ch.Title = c.Convert(chapter.Title)
+		if chapter.Content != nil {
+			content := *chapter.Content
+			content.Html = c.ConvertHTML(chapter.Content.Html)
+			ch.Content = &content
+		}
+		converted.Chapters = append(converted.Chapters, &ch)
+	}
+	return &converted
+}
+
+// ConvertNovel 返回转换后的小说副本，各卷按 ConvertVolume 转换
+func (c *Converter) ConvertNovel(novel *model.Novel) *model.Novel {
+	converted := *novel
+	converted.Title = c.Convert(novel.Title)
+	converted.Description = c.Convert(novel.Description)
+	converted.Authors = c.convertAll(novel.Authors)
+	converted.Volumes = make([]*model.Volume, 0, len(novel.Volumes))
+	for _, volume := range novel.Volumes {
+		converted.Volumes = append(converted.Volumes, c.ConvertVolume(volume))
+	}
+	return &converted
+}
+
+func (c *Converter) convertAll(values []string) []string {
+	if values == nil {
+		return nil
+	}
+	converted := make([]string, 0, len(values))
+	for _, v := range values {
+		converted = append(converted, c.Convert(v))
+	}
+	return converted
+}
+
+func loadDictionary(phrasesFile, charsFile string) (*dictionary, error) {
+	dict := &dictionary{
+		phrases: make(map[string]string),
+		chars:   make(map[rune]string),
+		starts:  make(map[rune]bool),
+	}
+	err := readDict(phrasesFile, func(key, value string) {
+		runes := []rune(key)
+		dict.phrases[key] = value
+		dict.starts[runes[0]] = true
+		dict.maxLen = max(dict.maxLen, len(runes))
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = readDict(charsFile, func(key, value string) {
+		runes := []rune(key)
+		if len(runes) == 1 {
+			dict.chars[runes[0]] = value
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dict, nil
+}
+
+// readDict 读取 OpenCC 格式的词典：每行为“键<Tab>候选1 候选2 ...”，取第一个候选
+func readDict(name string, add func(key, value string)) error {
+	data, err := dicts.ReadFile(path.Join("dict", name))
+	if err != nil {
+		return fmt.Errorf("failed to read dictionary %v: %v", name, err)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, values, ok := strings.Cut(line, "\t")
+		fields := strings.Fields(values)
+		if !ok || key == "" || len(fields) == 0 {
+			return fmt.Errorf("invalid line in dictionary %v: %q", name, line)
+		}
+		add(key, fields[0])
+	}
+	return scanner.Err()
+}